@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	UserLedgerLimit  = 10
+	AdminLedgerLimit = 20
+)
+
+// addBalanceLedger 记录积分流水 需与积分变更在同一事务中调用
+// chatGroupUser 为变更后的用户信息,ledger 可携带关联ID、期号、操作人等信息
+func addBalanceLedger(tx *gorm.DB, chatGroupUser *model.ChatGroupUser, balanceBefore decimal.Decimal, ledgerType enums.BalanceLedgerType, ledger *model.BalanceLedger) error {
+	if ledger == nil {
+		ledger = &model.BalanceLedger{}
+	}
+	ledger.ChatGroupUserId = chatGroupUser.Id
+	ledger.ChatGroupId = chatGroupUser.ChatGroupId
+	ledger.TgUserId = chatGroupUser.TgUserId
+	ledger.Delta = chatGroupUser.Balance.Sub(balanceBefore)
+	ledger.BalanceBefore = balanceBefore
+	ledger.BalanceAfter = chatGroupUser.Balance
+	ledger.ReasonType = ledgerType.Value
+	ledger.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+
+	err := ledger.Create(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"reasonType":      ledgerType.Value,
+			"err":             err,
+		}).Error("保存积分流水异常")
+		return err
+	}
+	return nil
+}
+
+// handleLedgerCommand 查询本人在该群的积分流水
+func handleLedgerCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	fromUser := message.From
+	messageId := message.MessageID
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    fromUser.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		msgConfig := tgbotapi.NewMessage(tgChatGroupId, "您还未注册，使用 /register 进行注册。")
+		msgConfig.ReplyToMessageID = messageId
+		_, err := sendMessage(bot, &msgConfig)
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    fromUser.ID,
+			"ChatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("群用户查询异常")
+		return
+	}
+
+	text, err := buildBalanceLedgerText(chatGroupUser, UserLedgerLimit)
+	if err != nil {
+		return
+	}
+
+	msgConfig := tgbotapi.NewMessage(tgChatGroupId, text)
+	msgConfig.ReplyToMessageID = messageId
+	sentMsg, err := sendMessage(bot, &msgConfig)
+	if err != nil {
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	}
+	go func(messageID int) {
+		time.Sleep(1 * time.Minute)
+		deleteMsg := tgbotapi.NewDeleteMessage(tgChatGroupId, messageID)
+		_, err := bot.Request(deleteMsg)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"err": err,
+			}).Error("删除消息异常")
+		}
+	}(sentMsg.MessageID)
+}
+
+// chatGroupUserLedgerCallBack 管理员查看群用户的积分流水
+func chatGroupUserLedgerCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatId := query.Message.Chat.ID
+	fromUser := query.From
+
+	callBackData, err := queryCallBackData(query, enums.CallbackChatGroupUserLedger)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+	chatGroupUserId := callBackData["chatGroupUserId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		Id:          chatGroupUserId,
+		ChatGroupId: chatGroupId,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByIdAndChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUserId,
+			"chatGroupId":     chatGroupId,
+			"err":             err,
+		}).Error("查询用户信息异常")
+		return
+	}
+
+	text, err := buildBalanceLedgerText(chatGroupUser, AdminLedgerLimit)
+	if err != nil {
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("用户【@%s】%s", chatGroupUser.Username, text))
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatId)
+}
+
+func buildBalanceLedgerText(chatGroupUser *model.ChatGroupUser, limit int) (string, error) {
+	balanceLedgerQuery := &model.BalanceLedger{ChatGroupUserId: chatGroupUser.Id}
+	balanceLedgers, err := balanceLedgerQuery.ListByChatGroupUserId(db, limit)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("查询积分流水异常")
+		return "", err
+	}
+
+	if len(balanceLedgers) == 0 {
+		return "暂无积分流水", nil
+	}
+
+	text := fmt.Sprintf("近%d条积分流水如下:\n", limit)
+	for _, ledger := range balanceLedgers {
+		reasonTypeName := ledger.ReasonType
+		if ledgerType, b := enums.GetBalanceLedgerType(ledger.ReasonType); b {
+			reasonTypeName = ledgerType.Name
+		}
+		text += fmt.Sprintf("%s %s %s 余额%s",
+			ledger.CreateTime,
+			reasonTypeName,
+			utils.FormatSignedAmount(ledger.Delta),
+			utils.FormatAmount(ledger.BalanceAfter),
+		)
+		if ledger.IssueNumber != "" {
+			text += fmt.Sprintf(" 期号%s", ledger.IssueNumber)
+		}
+		text += "\n"
+	}
+	return text, nil
+}
@@ -0,0 +1,411 @@
+package bot
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	ReconcileHour           = 3 // 每日对账时间(点)
+	ReconcileNotifyMaxLines = 20
+)
+
+// initReconcileTask 每日定时对账
+func initReconcileTask(bot *tgbotapi.BotAPI) {
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day(), ReconcileHour, 0, 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.Add(24 * time.Hour)
+			}
+			time.Sleep(next.Sub(now))
+			reconcileBalances(bot)
+		}
+	}()
+}
+
+// reconcileBalances 按业务记录重算每个群用户的余额并与当前余额比对
+// 应有余额 = 期初余额 + 下注、派彩、签到、转让、红包、管理员调整等业务记录的变动合计,不依赖积分流水,
+// 未写流水的余额变更同样能被发现;积分流水另做连续性及与下注记录的逐条核对,异常写入对账报告并私聊通知群管理员。
+func reconcileBalances(bot *tgbotapi.BotAPI) {
+	reconcileDate := time.Now().Format("2006-01-02")
+	logrus.WithField("reconcileDate", reconcileDate).Info("开始积分对账")
+
+	chatGroups, err := model.ListChatGroupByChatGroupStatus(db, enums.GroupNormal.Value)
+	if err != nil {
+		logrus.WithField("err", err).Error("对账查询群列表异常")
+		return
+	}
+
+	for _, chatGroup := range chatGroups {
+		chatGroupUserQuery := &model.ChatGroupUser{ChatGroupId: chatGroup.Id}
+		chatGroupUsers, err := chatGroupUserQuery.ListByChatGroupId(db)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroup.Id,
+				"err":         err,
+			}).Error("对账查询群用户异常")
+			continue
+		}
+		if len(chatGroupUsers) == 0 {
+			continue
+		}
+
+		reports := reconcileChatGroupSources(chatGroup, chatGroupUsers, reconcileDate)
+		for _, chatGroupUser := range chatGroupUsers {
+			reports = append(reports, reconcileChatGroupUserLedger(chatGroup, chatGroupUser, reconcileDate)...)
+		}
+
+		for _, report := range reports {
+			err = report.Create(db)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"chatGroupUserId": report.ChatGroupUserId,
+					"err":             err,
+				}).Error("保存对账报告异常")
+			}
+		}
+
+		if len(reports) > 0 {
+			notifyReconcileReports(bot, chatGroup, reports)
+		}
+	}
+
+	logrus.WithField("reconcileDate", reconcileDate).Info("积分对账完成")
+}
+
+// balanceSource 一类业务记录按群用户汇总的积分变动
+type balanceSource struct {
+	name    string
+	amounts map[string]decimal.Decimal
+}
+
+// loadBalanceSources 汇总群内各类业务记录对每个群用户余额的变动
+func loadBalanceSources(chatGroupId string) ([]*balanceSource, error) {
+	betQuery := &model.QuickThereBetRecord{ChatGroupId: chatGroupId}
+	betResultTypeWin := 1
+	winQuery := &model.QuickThereBetRecord{ChatGroupId: chatGroupId, SettleStatus: enums.Settled.Value, BetResultType: &betResultTypeWin}
+	transferQuery := &model.TransferRecord{ChatGroupId: chatGroupId, Status: enums.TransferCompleted.Value}
+	hongbaoQuery := &model.Hongbao{ChatGroupId: chatGroupId}
+	hongbaoExpiredQuery := &model.Hongbao{ChatGroupId: chatGroupId, Status: enums.HongbaoExpired.Value}
+
+	sourceQueries := []struct {
+		name     string
+		negative bool // 记录为扣除的积分
+		sum      func(db *gorm.DB) ([]*model.ChatGroupUserAmount, error)
+	}{
+		{enums.AdminAdjustLedger.Name, false, (&model.BalanceAdjustRecord{ChatGroupId: chatGroupId}).SumDeltaGroupByChatGroupUserId},
+		{enums.SignInLedger.Name, false, (&model.SignInRecord{ChatGroupId: chatGroupId}).SumRewardGroupByChatGroupUserId},
+		{enums.BetLedger.Name, true, betQuery.SumBetAmountGroupByChatGroupUserId},
+		{enums.BetSettleLedger.Name, false, winQuery.SumBetResultAmountGroupByChatGroupUserIdAndBetResultType},
+		{enums.TransferOutLedger.Name + "(含手续费)", true, transferQuery.SumSentGroupBySenderChatGroupUserId},
+		{enums.TransferInLedger.Name, false, transferQuery.SumAmountGroupByReceiverChatGroupUserId},
+		{enums.TransferFeeLedger.Name + "收入", false, transferQuery.SumFeeGroupByHouseChatGroupUserId},
+		{enums.HongbaoSendLedger.Name, true, hongbaoQuery.SumTotalAmountGroupBySenderChatGroupUserId},
+		{enums.HongbaoClaimLedger.Name, false, func(db *gorm.DB) ([]*model.ChatGroupUserAmount, error) {
+			return model.SumHongbaoClaimAmountGroupByChatGroupUserIdAndChatGroupId(db, chatGroupId)
+		}},
+		{enums.HongbaoRefundLedger.Name, false, hongbaoExpiredQuery.SumRemainAmountGroupBySenderChatGroupUserIdAndStatus},
+		{enums.ReliefLedger.Name, false, (&model.ReliefRecord{ChatGroupId: chatGroupId}).SumAmountGroupByChatGroupUserId},
+		{enums.RebateLedger.Name, false, (&model.RebateRecord{ChatGroupId: chatGroupId}).SumAmountGroupByChatGroupUserId},
+		{enums.ReferralLedger.Name, false, (&model.ReferralRecord{ChatGroupId: chatGroupId, Status: enums.ReferralRewarded.Value}).SumBonusGroupByInviterChatGroupUserId},
+	}
+
+	var sources []*balanceSource
+	for _, sourceQuery := range sourceQueries {
+		amounts, err := sourceQuery.sum(db)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroupId,
+				"source":      sourceQuery.name,
+				"err":         err,
+			}).Error("对账汇总业务记录异常")
+			return nil, err
+		}
+		source := &balanceSource{name: sourceQuery.name, amounts: make(map[string]decimal.Decimal)}
+		for _, amount := range amounts {
+			if sourceQuery.negative {
+				source.amounts[amount.ChatGroupUserId] = amount.Amount.Neg()
+			} else {
+				source.amounts[amount.ChatGroupUserId] = amount.Amount
+			}
+		}
+		sources = append(sources, source)
+	}
+
+	liarsDiceSources, err := loadLiarsDiceBalanceSources(chatGroupId)
+	if err != nil {
+		return nil, err
+	}
+	return append(sources, liarsDiceSources...), nil
+}
+
+// loadLiarsDiceBalanceSources 按牌桌状态汇总吹牛骰子的入场、退还及奖池积分 玩家只记录在牌桌状态中
+func loadLiarsDiceBalanceSources(chatGroupId string) ([]*balanceSource, error) {
+	liarsDiceTableQuery := &model.LiarsDiceTable{ChatGroupId: chatGroupId}
+	liarsDiceTables, err := liarsDiceTableQuery.ListByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("对账查询吹牛骰子牌桌异常")
+		return nil, err
+	}
+
+	ante := &balanceSource{name: enums.LiarsDiceAnteLedger.Name, amounts: make(map[string]decimal.Decimal)}
+	refund := &balanceSource{name: enums.LiarsDiceRefundLedger.Name, amounts: make(map[string]decimal.Decimal)}
+	win := &balanceSource{name: enums.LiarsDiceWinLedger.Name, amounts: make(map[string]decimal.Decimal)}
+	for _, table := range liarsDiceTables {
+		state, err := unmarshalLiarsDiceState(table)
+		if err != nil {
+			return nil, err
+		}
+		for _, player := range state.Players {
+			ante.amounts[player.ChatGroupUserId] = ante.amounts[player.ChatGroupUserId].Sub(table.Ante)
+			if table.Status == enums.LiarsDiceCancelled.Value {
+				refund.amounts[player.ChatGroupUserId] = refund.amounts[player.ChatGroupUserId].Add(table.Ante)
+			} else if table.Status == enums.LiarsDiceFinished.Value && player.TgUserId == table.WinnerTgUserId {
+				win.amounts[player.ChatGroupUserId] = win.amounts[player.ChatGroupUserId].Add(table.Pot)
+			}
+		}
+	}
+	return []*balanceSource{ante, refund, win}, nil
+}
+
+// reconcileChatGroupSources 以期初余额累加各业务记录得到应有余额,与当前余额比对
+// 先不加锁汇总全群 汇总期间有余额变更的用户可能出现误差,仅对不一致的用户加锁后重新汇总复核,避免长时间阻塞群内下注
+func reconcileChatGroupSources(chatGroup *model.ChatGroup, chatGroupUsers []*model.ChatGroupUser, reconcileDate string) []*model.BalanceReconcileReport {
+	sources, err := loadBalanceSources(chatGroup.Id)
+	if err != nil {
+		return nil
+	}
+
+	var reports []*model.BalanceReconcileReport
+	var mismatchedTgUserIds []int64
+	var mismatchedChatGroupUserIds []string
+	for _, chatGroupUser := range chatGroupUsers {
+		sourceTotal, details := sumBalanceSources(sources, chatGroupUser.Id)
+		if !chatGroupUser.InitialBalance.Valid {
+			// 启用对账前注册的用户未记录期初余额 无法判断已有余额是否正确,只报告不自动补齐
+			reports = append(reports, newBalanceReconcileReport(chatGroup, chatGroupUser, reconcileDate, enums.InitialBalanceMissing, chatGroupUser.Balance,
+				fmt.Sprintf("业务记录合计%s %s", utils.FormatSignedAmount(sourceTotal), strings.Join(details, " "))))
+			continue
+		}
+		if !chatGroupUser.InitialBalance.Decimal.Add(sourceTotal).Equal(chatGroupUser.Balance) {
+			mismatchedTgUserIds = append(mismatchedTgUserIds, chatGroupUser.TgUserId)
+			mismatchedChatGroupUserIds = append(mismatchedChatGroupUserIds, chatGroupUser.Id)
+		}
+	}
+	if len(mismatchedChatGroupUserIds) == 0 {
+		return reports
+	}
+
+	// 锁定不一致的用户后重新查询余额并重新汇总 保证余额与业务记录处于同一时点
+	unlock := lockChatGroupUsers(chatGroup.TgChatGroupId, mismatchedTgUserIds...)
+	defer unlock()
+
+	mismatchedUsers, err := model.ListChatGroupUserByIds(db, mismatchedChatGroupUserIds)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("对账查询群用户异常")
+		return reports
+	}
+	sources, err = loadBalanceSources(chatGroup.Id)
+	if err != nil {
+		return reports
+	}
+
+	for _, chatGroupUser := range mismatchedUsers {
+		sourceTotal, details := sumBalanceSources(sources, chatGroupUser.Id)
+		expectedBalance := chatGroupUser.InitialBalance.Decimal.Add(sourceTotal)
+		if !expectedBalance.Equal(chatGroupUser.Balance) {
+			reports = append(reports, newBalanceReconcileReport(chatGroup, chatGroupUser, reconcileDate, enums.SourceBalanceMismatch, expectedBalance,
+				fmt.Sprintf("差额%s 期初%s %s",
+					utils.FormatSignedAmount(chatGroupUser.Balance.Sub(expectedBalance)),
+					utils.FormatAmount(chatGroupUser.InitialBalance.Decimal),
+					strings.Join(details, " "))))
+		}
+	}
+	return reports
+}
+
+// sumBalanceSources 合计某个群用户在各类业务记录中的积分变动 并返回各类变动明细
+func sumBalanceSources(sources []*balanceSource, chatGroupUserId string) (decimal.Decimal, []string) {
+	sourceTotal := decimal.Zero
+	var details []string
+	for _, source := range sources {
+		amount := source.amounts[chatGroupUserId]
+		if amount.IsZero() {
+			continue
+		}
+		sourceTotal = sourceTotal.Add(amount)
+		details = append(details, fmt.Sprintf("%s%s", source.name, utils.FormatSignedAmount(amount)))
+	}
+	return sourceTotal, details
+}
+
+func newBalanceReconcileReport(chatGroup *model.ChatGroup, chatGroupUser *model.ChatGroupUser, reconcileDate string, discrepancyType enums.ReconcileDiscrepancyType, expectedBalance decimal.Decimal, detail string) *model.BalanceReconcileReport {
+	return &model.BalanceReconcileReport{
+		ReconcileDate:   reconcileDate,
+		ChatGroupId:     chatGroup.Id,
+		ChatGroupUserId: chatGroupUser.Id,
+		TgUserId:        chatGroupUser.TgUserId,
+		Username:        chatGroupUser.Username,
+		DiscrepancyType: discrepancyType.Value,
+		ExpectedBalance: expectedBalance,
+		ActualBalance:   chatGroupUser.Balance,
+		Detail:          detail,
+		CreateTime:      time.Now().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// reconcileChatGroupUserLedger 核对积分流水前后余额的连续性,以及流水与下注、派彩记录是否逐条对应
+func reconcileChatGroupUserLedger(chatGroup *model.ChatGroup, chatGroupUser *model.ChatGroupUser, reconcileDate string) []*model.BalanceReconcileReport {
+	// 对账期间锁定用户 避免读取到变更中的余额
+	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, chatGroup.TgChatGroupId, chatGroupUser.TgUserId)
+	userLock := getUserLock(userLockKey)
+	userLock.Lock()
+	defer userLock.Unlock()
+
+	chatGroupUser, err := chatGroupUser.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("对账查询群用户异常")
+		return nil
+	}
+
+	balanceLedgerQuery := &model.BalanceLedger{ChatGroupUserId: chatGroupUser.Id}
+	balanceLedgers, err := balanceLedgerQuery.ListAllByChatGroupUserId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("对账查询积分流水异常")
+		return nil
+	}
+	// 启用流水前且此后无变更的用户无流水可对 余额已由业务记录核对
+	if len(balanceLedgers) == 0 {
+		return nil
+	}
+
+	var reports []*model.BalanceReconcileReport
+	newReport := func(discrepancyType enums.ReconcileDiscrepancyType, expectedBalance decimal.Decimal, detail string) {
+		reports = append(reports, newBalanceReconcileReport(chatGroup, chatGroupUser, reconcileDate, discrepancyType, expectedBalance, detail))
+	}
+
+	// 以首条流水的变动前余额为期初 累加全部变动
+	expectedBalance := balanceLedgers[0].BalanceBefore
+	betLedgers := make(map[string]decimal.Decimal)
+	settleLedgers := make(map[string]decimal.Decimal)
+	for i, ledger := range balanceLedgers {
+		if !ledger.BalanceBefore.Add(ledger.Delta).Equal(ledger.BalanceAfter) ||
+			(i > 0 && !ledger.BalanceBefore.Equal(balanceLedgers[i-1].BalanceAfter)) {
+			newReport(enums.LedgerChainBroken, expectedBalance, fmt.Sprintf("流水ID:%s 变动前%s 变动%s 变动后%s",
+				ledger.Id,
+				utils.FormatAmount(ledger.BalanceBefore),
+				utils.FormatSignedAmount(ledger.Delta),
+				utils.FormatAmount(ledger.BalanceAfter)))
+		}
+		expectedBalance = expectedBalance.Add(ledger.Delta)
+
+		switch ledger.ReasonType {
+		case enums.BetLedger.Value:
+			betLedgers[ledger.RefId] = betLedgers[ledger.RefId].Add(ledger.Delta)
+		case enums.BetSettleLedger.Value:
+			settleLedgers[ledger.RefId] = settleLedgers[ledger.RefId].Add(ledger.Delta)
+		}
+	}
+
+	if !expectedBalance.Equal(chatGroupUser.Balance) {
+		newReport(enums.BalanceMismatch, expectedBalance, fmt.Sprintf("差额%s", utils.FormatSignedAmount(chatGroupUser.Balance.Sub(expectedBalance))))
+	}
+
+	// 启用流水后的下注记录逐条核对
+	quickThereBetRecordQuery := &model.QuickThereBetRecord{ChatGroupUserId: chatGroupUser.Id}
+	quickThereBetRecords, err := quickThereBetRecordQuery.ListByChatGroupUserIdAndCreateTimeFrom(db, balanceLedgers[0].CreateTime)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("对账查询下注记录异常")
+		return reports
+	}
+	for _, betRecord := range quickThereBetRecords {
+		if betDelta, ok := betLedgers[betRecord.Id]; !ok || !betDelta.Neg().Equal(betRecord.BetAmount) {
+			newReport(enums.BetLedgerMismatch, expectedBalance, fmt.Sprintf("下注ID:%s 期号%s 下注%s 流水%s",
+				betRecord.Id,
+				betRecord.IssueNumber,
+				utils.FormatAmount(betRecord.BetAmount),
+				utils.FormatSignedAmount(betDelta)))
+		}
+
+		if betRecord.SettleStatus != enums.Settled.Value || betRecord.BetResultType == nil {
+			continue
+		}
+		settleDelta, ok := settleLedgers[betRecord.Id]
+		if *betRecord.BetResultType == 1 && (!ok || !settleDelta.IsPositive()) {
+			newReport(enums.SettleLedgerMismatch, expectedBalance, fmt.Sprintf("下注ID:%s 期号%s 中奖但无派彩流水", betRecord.Id, betRecord.IssueNumber))
+		} else if *betRecord.BetResultType == 1 && betRecord.BetResultAmount.Valid && !settleDelta.Equal(betRecord.BetResultAmount.Decimal) {
+			newReport(enums.SettleLedgerMismatch, expectedBalance, fmt.Sprintf("下注ID:%s 期号%s 派彩%s 流水%s",
+				betRecord.Id,
+				betRecord.IssueNumber,
+				utils.FormatAmount(betRecord.BetResultAmount.Decimal),
+				utils.FormatSignedAmount(settleDelta)))
+		} else if *betRecord.BetResultType == 0 && ok {
+			newReport(enums.SettleLedgerMismatch, expectedBalance, fmt.Sprintf("下注ID:%s 期号%s 未中奖但有派彩流水%s", betRecord.Id, betRecord.IssueNumber, utils.FormatSignedAmount(settleDelta)))
+		}
+	}
+
+	return reports
+}
+
+// notifyReconcileReports 私聊通知群管理员对账异常
+func notifyReconcileReports(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, reports []*model.BalanceReconcileReport) {
+	chatGroupAdmins, err := model.ListChatGroupAdminByChatGroupId(db, chatGroup.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("查询群管理员异常")
+		return
+	}
+
+	text := fmt.Sprintf("【%s】%s积分对账发现%d处异常:\n", chatGroup.TgChatGroupTitle, reports[0].ReconcileDate, len(reports))
+	for i, report := range reports {
+		if i >= ReconcileNotifyMaxLines {
+			text += fmt.Sprintf("...其余%d处请查看对账报告", len(reports)-ReconcileNotifyMaxLines)
+			break
+		}
+		discrepancyTypeName := report.DiscrepancyType
+		if discrepancyType, b := enums.GetReconcileDiscrepancyType(report.DiscrepancyType); b {
+			discrepancyTypeName = discrepancyType.Name
+		}
+		text += fmt.Sprintf("@%s(ID:%s) %s 应有余额%s 当前余额%s %s\n",
+			report.Username,
+			report.ChatGroupUserId,
+			discrepancyTypeName,
+			utils.FormatAmount(report.ExpectedBalance),
+			utils.FormatAmount(report.ActualBalance),
+			report.Detail,
+		)
+	}
+
+	for _, chatGroupAdmin := range chatGroupAdmins {
+		sendMsg := tgbotapi.NewMessage(chatGroupAdmin.AdminTgUserId, text)
+		_, err := sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatGroupAdmin.AdminTgUserId)
+	}
+}
@@ -0,0 +1,246 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	// BetBoardEditInterval 下注看板两次编辑的最小间隔 避免触发Telegram限流
+	BetBoardEditInterval = 3 * time.Second
+	// BetBoardMaxLines 下注看板最多展示的下注明细条数 超出部分只计入合计
+	BetBoardMaxLines = 50
+)
+
+// betBoard 每个群当前期的下注看板 一期只发送一条消息,有新下注时原地编辑
+type betBoard struct {
+	IssueNumber string
+	MessageId   int
+	LastEditAt  time.Time
+	// Scheduled 已安排延迟编辑 期间的新下注合并到该次编辑
+	Scheduled bool
+	// Frozen 本期已封盘 看板保留到下一期首笔下注,期间的刷新均忽略
+	Frozen bool
+}
+
+var betBoards = make(map[string]*betBoard)
+var betBoardsMutex sync.Mutex
+
+func getBetBoard(chatGroupId string) *betBoard {
+	betBoardsMutex.Lock()
+	defer betBoardsMutex.Unlock()
+	return betBoards[chatGroupId]
+}
+
+func setBetBoard(chatGroupId string, board *betBoard) {
+	betBoardsMutex.Lock()
+	defer betBoardsMutex.Unlock()
+	betBoards[chatGroupId] = board
+}
+
+// refreshBetBoard 下注成功后异步刷新本期下注看板 首笔下注时发送看板,之后按 BetBoardEditInterval 限频编辑
+func refreshBetBoard(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, issueNumber string) {
+	go func() {
+		boardLock := getBetBoardLock(chatGroup.Id)
+		boardLock.Lock()
+		defer boardLock.Unlock()
+
+		board := getBetBoard(chatGroup.Id)
+		if board != nil && board.IssueNumber == issueNumber && board.Frozen {
+			return
+		}
+
+		// 下注期间已封盘或已进入下一期则不再刷新
+		redisKey := fmt.Sprintf(RedisCurrentIssueNumberKey, chatGroup.Id)
+		currentIssueNumber, err := redisDB.Get(redisDB.Context(), redisKey).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			logrus.WithFields(logrus.Fields{
+				"redisKey": redisKey,
+				"err":      err,
+			}).Error("redis获取当前期号异常")
+			return
+		}
+		if currentIssueNumber != issueNumber {
+			return
+		}
+
+		if board == nil || board.IssueNumber != issueNumber {
+			text, err := buildBetBoardText(chatGroup.Id, issueNumber, false)
+			if err != nil {
+				return
+			}
+			sendMsg := tgbotapi.NewMessage(chatGroup.TgChatGroupId, text)
+			sentMsg, err := sendMessage(bot, &sendMsg)
+			if err != nil {
+				blockedOrKicked(err, chatGroup.TgChatGroupId)
+				return
+			}
+			setBetBoard(chatGroup.Id, &betBoard{
+				IssueNumber: issueNumber,
+				MessageId:   sentMsg.MessageID,
+				LastEditAt:  time.Now(),
+			})
+			return
+		}
+
+		if board.Scheduled {
+			return
+		}
+		wait := BetBoardEditInterval - time.Since(board.LastEditAt)
+		if wait <= 0 {
+			editBetBoard(bot, chatGroup, board, false)
+			return
+		}
+		board.Scheduled = true
+		time.AfterFunc(wait, func() {
+			boardLock.Lock()
+			defer boardLock.Unlock()
+			// 延迟期间已封盘或已进入下一期则不再编辑
+			current := getBetBoard(chatGroup.Id)
+			if current != board || board.Frozen {
+				return
+			}
+			board.Scheduled = false
+			editBetBoard(bot, chatGroup, board, false)
+		})
+	}()
+}
+
+// freezeBetBoard 封盘时最后编辑一次本期下注看板 并标记本期已封盘,之后不再更新
+func freezeBetBoard(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, issueNumber string) {
+	boardLock := getBetBoardLock(chatGroup.Id)
+	boardLock.Lock()
+	defer boardLock.Unlock()
+
+	board := getBetBoard(chatGroup.Id)
+	if board == nil || board.IssueNumber != issueNumber {
+		// 本期还没有看板 只记录封盘标记 避免封盘后才到达的下注再发送看板
+		setBetBoard(chatGroup.Id, &betBoard{
+			IssueNumber: issueNumber,
+			Frozen:      true,
+		})
+		return
+	}
+	if board.Frozen {
+		return
+	}
+	board.Frozen = true
+	editBetBoard(bot, chatGroup, board, true)
+}
+
+// editBetBoard 按数据库中的下注记录重新生成看板内容并编辑 调用方需持有看板锁
+func editBetBoard(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, board *betBoard, frozen bool) {
+	text, err := buildBetBoardText(chatGroup.Id, board.IssueNumber, frozen)
+	if err != nil {
+		return
+	}
+	board.LastEditAt = time.Now()
+	editMsg := tgbotapi.NewEditMessageText(chatGroup.TgChatGroupId, board.MessageId, text)
+	_, err = sendMessage(bot, &editMsg)
+	blockedOrKicked(err, chatGroup.TgChatGroupId)
+}
+
+// buildBetBoardText 组装本期下注看板 逐笔列出下注人、竞猜类型及积分,并按竞猜类型合计
+func buildBetBoardText(chatGroupId string, issueNumber string, frozen bool) (string, error) {
+	betRecordQuery := &model.QuickThereBetRecord{
+		ChatGroupId: chatGroupId,
+		IssueNumber: issueNumber,
+	}
+	betRecords, err := betRecordQuery.ListByChatGroupIdAndIssueNumber(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("获取用户下注记录异常")
+		return "", err
+	}
+	sort.SliceStable(betRecords, func(i, j int) bool {
+		if betRecords[i].CreateTime != betRecords[j].CreateTime {
+			return betRecords[i].CreateTime < betRecords[j].CreateTime
+		}
+		return betRecords[i].Id < betRecords[j].Id
+	})
+
+	var chatGroupUserIds []string
+	for _, betRecord := range betRecords {
+		chatGroupUserIds = append(chatGroupUserIds, betRecord.ChatGroupUserId)
+	}
+	userNames := make(map[string]string)
+	if len(chatGroupUserIds) > 0 {
+		chatGroupUsers, err := model.ListChatGroupUserByIds(db, chatGroupUserIds)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroupId,
+				"err":         err,
+			}).Error("查询下注用户异常")
+			return "", err
+		}
+		for _, chatGroupUser := range chatGroupUsers {
+			userNames[chatGroupUser.Id] = rankUserName(chatGroupUser)
+		}
+	}
+
+	status := "进行中"
+	if frozen {
+		status = "已封盘"
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("第%s期 本期下注(%s)\n", issueNumber, status))
+
+	typeCounts := make(map[string]int)
+	typeAmounts := make(map[string]decimal.Decimal)
+	totalAmount := decimal.Zero
+	for i, betRecord := range betRecords {
+		typeCounts[betRecord.BetType]++
+		typeAmounts[betRecord.BetType] = typeAmounts[betRecord.BetType].Add(betRecord.BetAmount)
+		totalAmount = totalAmount.Add(betRecord.BetAmount)
+
+		if i < BetBoardMaxLines {
+			betType, _ := enums.GetGameLotteryType(betRecord.BetType)
+			text.WriteString(fmt.Sprintf("%s %s %s\n", userNames[betRecord.ChatGroupUserId], betType.Name, utils.FormatAmount(betRecord.BetAmount)))
+		}
+	}
+	if len(betRecords) > BetBoardMaxLines {
+		text.WriteString(fmt.Sprintf("...等共%d笔\n", len(betRecords)))
+	}
+
+	text.WriteString("\n")
+	for _, betType := range []enums.GameLotteryType{enums.Big, enums.Small, enums.Single, enums.Double, enums.Triplet} {
+		if typeCounts[betType.Value] == 0 {
+			continue
+		}
+		text.WriteString(fmt.Sprintf("%s: %d笔 %s\n", betType.Name, typeCounts[betType.Value], utils.FormatAmount(typeAmounts[betType.Value])))
+	}
+	text.WriteString(fmt.Sprintf("合计: %d笔 %s积分", len(betRecords), utils.FormatAmount(totalAmount)))
+	return text.String(), nil
+}
+
+// updateBetReplyStatusCallBack 开启/关闭逐条回复下注成功
+func updateBetReplyStatusCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	updateChatGroupStatusCallBack(bot, query, enums.CallbackUpdateBetReplyStatus, func(chatGroup *model.ChatGroup) (int, error) {
+		return chatGroup.BetReplyStatus, nil
+	}, func(chatGroup *model.ChatGroup, status int) error {
+		chatGroupUpdate := &model.ChatGroup{
+			Id:             chatGroup.Id,
+			BetReplyStatus: status,
+		}
+		if err := chatGroupUpdate.UpdateBetReplyStatusById(db); err != nil {
+			return err
+		}
+		chatGroup.BetReplyStatus = status
+		return nil
+	})
+}
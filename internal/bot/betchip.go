@@ -0,0 +1,277 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	// RedisBetChipTypeKey 快捷下注时用户已选择的竞猜类型
+	RedisBetChipTypeKey = "BET_CHIP_TYPE:CHAT_GROUP_ID:%s:TG_USER_ID:%d"
+	// BetChipTypeExpire 已选择竞猜类型的有效期
+	BetChipTypeExpire = 1 * time.Hour
+	// BetChipAllIn 梭哈 以用户当前全部余额下注
+	BetChipAllIn = "ALL"
+)
+
+// BetChipAmounts 快捷下注的筹码积分
+var BetChipAmounts = []string{"10", "50", "100", "500"}
+
+// buildBetChipInlineKeyboardMarkup 开奖公告下的快捷下注键盘 先选择竞猜类型再点击筹码下注
+// 按钮参数直接放在Data中而非redis,避免一期持续时间较长时按钮失效
+func buildBetChipInlineKeyboardMarkup(issueNumber string) tgbotapi.InlineKeyboardMarkup {
+	var typeButtons []tgbotapi.InlineKeyboardButton
+	for _, betType := range []enums.GameLotteryType{enums.Big, enums.Small, enums.Single, enums.Double, enums.Triplet} {
+		typeButtons = append(typeButtons, tgbotapi.NewInlineKeyboardButtonData(betType.Name,
+			fmt.Sprintf("%s%s", enums.CallbackBetChipType.Value, utils.MapToQueryString(map[string]string{"betType": betType.Value}))))
+	}
+
+	var chipButtons []tgbotapi.InlineKeyboardButton
+	for _, amount := range append(BetChipAmounts, BetChipAllIn) {
+		text := amount
+		if amount == BetChipAllIn {
+			text = "梭哈"
+		}
+		chipButtons = append(chipButtons, tgbotapi.NewInlineKeyboardButtonData(text,
+			fmt.Sprintf("%s%s", enums.CallbackBetChipAmount.Value, utils.MapToQueryString(map[string]string{"amount": amount, "issueNumber": issueNumber}))))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		typeButtons,
+		chipButtons,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔁重复上次下注",
+				fmt.Sprintf("%s%s", enums.CallbackBetChipRepeat.Value, utils.MapToQueryString(map[string]string{"issueNumber": issueNumber}))),
+		),
+	)
+}
+
+// parseBetChipCallBackData 解析快捷下注按钮Data中的参数
+func parseBetChipCallBackData(query *tgbotapi.CallbackQuery, callbackPrefix enums.CallbackPrefix) (map[string]string, error) {
+	queryStringToMap, err := utils.QueryStringToMap(strings.TrimPrefix(query.Data, callbackPrefix.Value))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"queryData": query.Data,
+			"err":       err,
+		}).Error("内联键盘解析异常")
+		return nil, err
+	}
+	return queryStringToMap, nil
+}
+
+// betChipTypeCallBack 快捷下注选择竞猜类型 选择结果按群和用户暂存到redis
+func betChipTypeCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	callBackData, err := parseBetChipCallBackData(query, enums.CallbackBetChipType)
+	if err != nil {
+		return
+	}
+
+	betType, ok := enums.GetGameLotteryType(callBackData["betType"])
+	if !ok {
+		answerCallbackQuery(bot, query, "竞猜类型异常", true)
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, query.Message.Chat.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": query.Message.Chat.ID,
+			"err":           err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	redisKey := fmt.Sprintf(RedisBetChipTypeKey, chatGroup.Id, query.From.ID)
+	err = redisDB.Set(redisDB.Context(), redisKey, betType.Value, BetChipTypeExpire).Err()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("redis存储快捷下注竞猜类型异常")
+		answerCallbackQuery(bot, query, "选择失败,请稍后再试!", true)
+		return
+	}
+
+	answerCallbackQuery(bot, query, fmt.Sprintf("已选择【%s】,请点击下注积分", betType.Name), false)
+}
+
+// betChipAmountCallBack 快捷下注点击筹码 以已选择的竞猜类型下注
+func betChipAmountCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	callBackData, err := parseBetChipCallBackData(query, enums.CallbackBetChipAmount)
+	if err != nil {
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, query.Message.Chat.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": query.Message.Chat.ID,
+			"err":           err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	redisKey := fmt.Sprintf(RedisBetChipTypeKey, chatGroup.Id, query.From.ID)
+	betTypeValue, err := redisDB.Get(redisDB.Context(), redisKey).Result()
+	if errors.Is(err, redis.Nil) {
+		answerCallbackQuery(bot, query, "请先选择竞猜类型", true)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("redis获取快捷下注竞猜类型异常")
+		return
+	}
+	betType, ok := enums.GetGameLotteryType(betTypeValue)
+	if !ok {
+		answerCallbackQuery(bot, query, "请先选择竞猜类型", true)
+		return
+	}
+
+	var betAmount decimal.Decimal
+	if callBackData["amount"] == BetChipAllIn {
+		// 梭哈 以当前余额下注
+		chatGroupUserQuery := &model.ChatGroupUser{
+			TgUserId:    query.From.ID,
+			ChatGroupId: chatGroup.Id,
+		}
+		chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			answerCallbackQuery(bot, query, "您还未注册，使用 /register 进行注册。", true)
+			return
+		} else if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"TgUserId":    chatGroupUserQuery.TgUserId,
+				"ChatGroupId": chatGroupUserQuery.ChatGroupId,
+				"err":         err,
+			}).Error("查询用户信息异常")
+			return
+		}
+		betAmount = decimal.Min(chatGroupUser.Balance, utils.MaxAmount)
+		if utils.CheckAmount(betAmount) != nil {
+			answerCallbackQuery(bot, query, "您的余额不足!", true)
+			return
+		}
+	} else {
+		betAmount, err = utils.ParseAmount(callBackData["amount"])
+		if err != nil {
+			answerCallbackQuery(bot, query, "下注积分异常", true)
+			return
+		}
+	}
+
+	placeBetChip(bot, query, chatGroup, callBackData["issueNumber"], betType, betAmount)
+}
+
+// betChipRepeatCallBack 重复上次下注 以用户在本群最近一笔下注的竞猜类型和积分下注当前期
+func betChipRepeatCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	callBackData, err := parseBetChipCallBackData(query, enums.CallbackBetChipRepeat)
+	if err != nil {
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, query.Message.Chat.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": query.Message.Chat.ID,
+			"err":           err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    query.From.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		answerCallbackQuery(bot, query, "您还未注册，使用 /register 进行注册。", true)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    chatGroupUserQuery.TgUserId,
+			"ChatGroupId": chatGroupUserQuery.ChatGroupId,
+			"err":         err,
+		}).Error("查询用户信息异常")
+		return
+	}
+
+	betRecordQuery := &model.QuickThereBetRecord{ChatGroupUserId: chatGroupUser.Id}
+	betRecords, _, err := betRecordQuery.PageByChatGroupUserIdAndFilter(db, &model.QuickThereBetRecordFilter{}, 0, 1)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("查询用户最近下注记录异常")
+		return
+	}
+	if len(betRecords) == 0 {
+		answerCallbackQuery(bot, query, "暂无下注记录", true)
+		return
+	}
+
+	betType, ok := enums.GetGameLotteryType(betRecords[0].BetType)
+	if !ok {
+		answerCallbackQuery(bot, query, "竞猜类型异常", true)
+		return
+	}
+
+	placeBetChip(bot, query, chatGroup, callBackData["issueNumber"], betType, betRecords[0].BetAmount)
+}
+
+// placeBetChip 快捷下注 校验玩法状态及期号后走与文字下注相同的下注流程,结果以回调提示展示
+func placeBetChip(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, chatGroup *model.ChatGroup, issueNumber string, betType enums.GameLotteryType, betAmount decimal.Decimal) {
+	if chatGroup.GameplayStatus == enums.GameplayStatusOFF.Value {
+		answerCallbackQuery(bot, query, "功能未开启！", true)
+		return
+	}
+
+	// 仅允许下注按钮所属的当前期 已封盘或已进入下一期的旧按钮不再生效
+	redisKey := fmt.Sprintf(RedisCurrentIssueNumberKey, chatGroup.Id)
+	currentIssueNumber, err := redisDB.Get(redisDB.Context(), redisKey).Result()
+	if errors.Is(err, redis.Nil) {
+		answerCallbackQuery(bot, query, "当前暂无开奖活动!", true)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("redis获取当前期号异常")
+		return
+	}
+	if currentIssueNumber != issueNumber {
+		answerCallbackQuery(bot, query, fmt.Sprintf("第%s期已封盘,请在最新一期下注!", issueNumber), true)
+		return
+	}
+
+	tipMsg, err := placeQuickThereBet(chatGroup, query.From, &model.QuickThereBetRecord{
+		IssueNumber: issueNumber,
+		BetType:     betType.Name,
+		BetAmount:   betAmount,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"err": err,
+		}).Error("保存下注记录异常")
+		answerCallbackQuery(bot, query, "下注失败,请稍后再试!", true)
+		return
+	}
+	if tipMsg != "" {
+		answerCallbackQuery(bot, query, tipMsg, true)
+		return
+	}
+
+	answerCallbackQuery(bot, query, fmt.Sprintf("下注成功! 第%s期【%s】%s积分", issueNumber, betType.Name, utils.FormatAmount(betAmount)), true)
+	refreshBetBoard(bot, chatGroup, issueNumber)
+}
@@ -0,0 +1,372 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const BetHistoryPageSize = 10
+
+// betHistoryQuery 下注记录查询条件 通过回调参数在翻页及切换筛选时传递
+type betHistoryQuery struct {
+	ChatGroupUserId string
+	TgUserId        int64
+	Page            int
+	DateFrom        string
+	DateTo          string
+	BetType         string
+	Result          string
+}
+
+func (q *betHistoryQuery) toCallbackData() map[string]string {
+	return map[string]string{
+		"chatGroupUserId": q.ChatGroupUserId,
+		"tgUserId":        strconv.FormatInt(q.TgUserId, 10),
+		"page":            strconv.Itoa(q.Page),
+		"dateFrom":        q.DateFrom,
+		"dateTo":          q.DateTo,
+		"betType":         q.BetType,
+		"result":          q.Result,
+	}
+}
+
+func betHistoryQueryFromCallbackData(callBackData map[string]string) (*betHistoryQuery, error) {
+	tgUserId, err := strconv.ParseInt(callBackData["tgUserId"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	page, err := strconv.Atoi(callBackData["page"])
+	if err != nil || page < 1 {
+		page = 1
+	}
+	return &betHistoryQuery{
+		ChatGroupUserId: callBackData["chatGroupUserId"],
+		TgUserId:        tgUserId,
+		Page:            page,
+		DateFrom:        callBackData["dateFrom"],
+		DateTo:          callBackData["dateTo"],
+		BetType:         callBackData["betType"],
+		Result:          callBackData["result"],
+	}, nil
+}
+
+// parseBetHistoryArgs 解析 /myhistory 筛选参数 顺序不限
+// 日期格式 2006-01-02,仅一个日期时查询当天,两个日期为起止范围;竞猜类型为 单/双/大/小/豹子;结果为 赢/输/未开奖
+func parseBetHistoryArgs(args []string, q *betHistoryQuery) error {
+	var dates []string
+	for _, arg := range args {
+		if _, err := time.ParseInLocation("2006-01-02", arg, time.Local); err == nil {
+			dates = append(dates, arg)
+			continue
+		}
+		if betType, ok := enums.GetGameLotteryTypeForName(arg); ok {
+			q.BetType = betType.Value
+			continue
+		}
+		matched := false
+		for _, value := range []enums.HistoryResultFilter{enums.HistoryResultWin, enums.HistoryResultLoss, enums.HistoryResultUnsettled} {
+			if value.Name == arg {
+				q.Result = value.Value
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("无法识别的筛选条件: %s", arg)
+		}
+	}
+	switch len(dates) {
+	case 0:
+	case 1:
+		q.DateFrom, q.DateTo = dates[0], dates[0]
+	case 2:
+		q.DateFrom, q.DateTo = dates[0], dates[1]
+		if q.DateFrom > q.DateTo {
+			q.DateFrom, q.DateTo = q.DateTo, q.DateFrom
+		}
+	default:
+		return errors.New("最多输入两个日期")
+	}
+	return nil
+}
+
+// toFilter 转换为下注记录筛选条件 结束日期包含当天
+func (q *betHistoryQuery) toFilter() *model.QuickThereBetRecordFilter {
+	filter := &model.QuickThereBetRecordFilter{BetType: q.BetType}
+	if q.DateFrom != "" {
+		filter.CreateTimeFrom = q.DateFrom + " 00:00:00"
+	}
+	if q.DateTo != "" {
+		dateTo, err := time.ParseInLocation("2006-01-02", q.DateTo, time.Local)
+		if err == nil {
+			filter.CreateTimeTo = dateTo.AddDate(0, 0, 1).Format("2006-01-02 15:04:05")
+		}
+	}
+	switch q.Result {
+	case enums.HistoryResultWin.Value:
+		betResultType := enums.Win.Value
+		filter.BetResultType = &betResultType
+	case enums.HistoryResultLoss.Value:
+		betResultType := enums.Loss.Value
+		filter.BetResultType = &betResultType
+	case enums.HistoryResultUnsettled.Value:
+		settleStatus := enums.Unsettled.Value
+		filter.SettleStatus = &settleStatus
+	}
+	return filter
+}
+
+// describe 当前筛选条件描述
+func (q *betHistoryQuery) describe() string {
+	var parts []string
+	if q.DateFrom != "" {
+		if q.DateFrom == q.DateTo {
+			parts = append(parts, q.DateFrom)
+		} else {
+			parts = append(parts, fmt.Sprintf("%s~%s", q.DateFrom, q.DateTo))
+		}
+	}
+	if betType, ok := enums.GetGameLotteryType(q.BetType); ok {
+		parts = append(parts, betType.Name)
+	}
+	if result, ok := enums.GetHistoryResultFilter(q.Result); ok && result != enums.HistoryResultAll {
+		parts = append(parts, result.Name)
+	}
+	if len(parts) == 0 {
+		return "全部"
+	}
+	return strings.Join(parts, " ")
+}
+
+func handleMyHistoryCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	fromUser := message.From
+	messageId := message.MessageID
+	tgChatId := message.Chat.ID
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatId": tgChatId,
+			"err":      err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		// 查询用户信息
+		TgUserId:    fromUser.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 没有找到记录
+		msgConfig := tgbotapi.NewMessage(tgChatId, "您还未注册，使用 /register 进行注册。")
+		msgConfig.ReplyToMessageID = messageId
+		_, err := sendMessage(bot, &msgConfig)
+		blockedOrKicked(err, tgChatId)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatId": tgChatId,
+			"err":      err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	q := &betHistoryQuery{
+		ChatGroupUserId: chatGroupUser.Id,
+		TgUserId:        fromUser.ID,
+		Page:            1,
+		Result:          enums.HistoryResultAll.Value,
+	}
+	err = parseBetHistoryArgs(strings.Fields(message.CommandArguments()), q)
+	if err != nil {
+		replyAutoDeleteMessage(bot, tgChatId, messageId, fmt.Sprintf("%s\n例子: /myhistory 2024-01-01 2024-01-31 大 赢", err.Error()))
+		return
+	}
+
+	text, inlineKeyboardMarkup, err := buildBetHistoryMessage(q)
+	if err != nil {
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(tgChatId, text)
+	sendMsg.ReplyToMessageID = messageId
+	sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	sentMsg, err := sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, tgChatId)
+		return
+	}
+	go func(messageID int) {
+		time.Sleep(1 * time.Minute)
+		deleteMsg := tgbotapi.NewDeleteMessage(tgChatId, messageID)
+		_, err := bot.Request(deleteMsg)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"err": err,
+			}).Error("删除消息异常")
+		}
+	}(sentMsg.MessageID)
+}
+
+// myHistoryPageCallBack 下注记录翻页/切换输赢筛选 仅本人可操作
+func myHistoryPageCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	callBackData, err := queryCallBackData(query, enums.CallbackMyHistoryPage)
+	if err != nil {
+		answerCallbackQuery(bot, query, "记录已过期,请重新发送 /myhistory", true)
+		return
+	}
+
+	q, err := betHistoryQueryFromCallbackData(callBackData)
+	if err != nil || q.TgUserId != query.From.ID {
+		answerCallbackQuery(bot, query, "仅本人可操作!", true)
+		return
+	}
+
+	text, inlineKeyboardMarkup, err := buildBetHistoryMessage(q)
+	if err != nil {
+		answerCallbackQuery(bot, query, "查询失败,请稍后再试!", true)
+		return
+	}
+
+	answerCallbackQuery(bot, query, "", false)
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	editMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &editMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// buildBetHistoryMessage 组装一页下注记录及翻页、筛选按钮 第一页附带救济金及反水记录
+func buildBetHistoryMessage(q *betHistoryQuery) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	betRecordQuery := &model.QuickThereBetRecord{ChatGroupUserId: q.ChatGroupUserId}
+	betRecords, total, err := betRecordQuery.PageByChatGroupUserIdAndFilter(db, q.toFilter(), (q.Page-1)*BetHistoryPageSize, BetHistoryPageSize)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": q.ChatGroupUserId,
+			"err":             err,
+		}).Error("查询下注记录异常")
+		return "", nil, err
+	}
+	totalPage := int((total + BetHistoryPageSize - 1) / BetHistoryPageSize)
+
+	var text strings.Builder
+	if total == 0 {
+		text.WriteString(fmt.Sprintf("筛选: %s\n您还没有下注记录哦!\n", q.describe()))
+	} else {
+		text.WriteString(fmt.Sprintf("您的下注记录(第%d/%d页,共%d条)\n筛选: %s\n", q.Page, totalPage, total, q.describe()))
+
+		pageBetAmount := decimal.Zero
+		pageNetProfit := decimal.Zero
+		for _, betRecord := range betRecords {
+			betType, _ := enums.GetGameLotteryType(betRecord.BetType)
+
+			betResultTypeName := "「未开奖」"
+			if betRecord.BetResultType != nil {
+				betResultType, _ := enums.GetBetResultType(*betRecord.BetResultType)
+				betResultTypeName = betResultType.Name
+			}
+
+			betResultAmount := ""
+			if betRecord.BetResultAmount.Valid {
+				betResultAmount = utils.FormatSignedAmount(betRecord.BetResultAmount.Decimal)
+			}
+
+			text.WriteString(fmt.Sprintf("%s期 %s %s %s %s %s \n",
+				betRecord.IssueNumber,
+				"快三",
+				betType.Name,
+				utils.FormatAmount(betRecord.BetAmount),
+				betResultTypeName,
+				betResultAmount,
+			))
+
+			pageBetAmount = pageBetAmount.Add(betRecord.BetAmount)
+			pageNetProfit = pageNetProfit.Add(betNetProfit(betRecord))
+		}
+		text.WriteString(fmt.Sprintf("本页合计: 下注%s丨净输赢%s\n", utils.FormatAmount(pageBetAmount), utils.FormatSignedAmount(pageNetProfit)))
+	}
+	text.WriteString("筛选示例: /myhistory 2024-01-01 2024-01-31 大 赢\n")
+
+	if q.Page == 1 {
+		// 救济金领取记录
+		if reliefHistoryText := buildReliefHistoryText(q.ChatGroupUserId); reliefHistoryText != "" {
+			text.WriteString("\n" + reliefHistoryText)
+		}
+
+		// 反水发放记录
+		if rebateHistoryText := buildRebateHistoryText(q.ChatGroupUserId); rebateHistoryText != "" {
+			text.WriteString("\n" + rebateHistoryText)
+		}
+	}
+
+	var inlineKeyboardRows [][]tgbotapi.InlineKeyboardButton
+
+	var pageButtons []tgbotapi.InlineKeyboardButton
+	if q.Page > 1 {
+		button, err := buildBetHistoryButton("⬅️上一页", q, q.Page-1, q.Result)
+		if err != nil {
+			return "", nil, err
+		}
+		pageButtons = append(pageButtons, button)
+	}
+	if q.Page < totalPage {
+		button, err := buildBetHistoryButton("➡️下一页", q, q.Page+1, q.Result)
+		if err != nil {
+			return "", nil, err
+		}
+		pageButtons = append(pageButtons, button)
+	}
+	if len(pageButtons) > 0 {
+		inlineKeyboardRows = append(inlineKeyboardRows, pageButtons)
+	}
+
+	var resultButtons []tgbotapi.InlineKeyboardButton
+	for _, value := range []enums.HistoryResultFilter{enums.HistoryResultAll, enums.HistoryResultWin, enums.HistoryResultLoss, enums.HistoryResultUnsettled} {
+		buttonDataText := value.Name
+		if value.Value == q.Result || (q.Result == "" && value == enums.HistoryResultAll) {
+			buttonDataText = fmt.Sprintf("%s✅", buttonDataText)
+		}
+		button, err := buildBetHistoryButton(buttonDataText, q, 1, value.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		resultButtons = append(resultButtons, button)
+	}
+	inlineKeyboardRows = append(inlineKeyboardRows, resultButtons)
+
+	inlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(inlineKeyboardRows...)
+	return text.String(), &inlineKeyboardMarkup, nil
+}
+
+// buildBetHistoryButton 在当前查询条件基础上切换页码或输赢筛选
+func buildBetHistoryButton(text string, q *betHistoryQuery, page int, result string) (tgbotapi.InlineKeyboardButton, error) {
+	next := *q
+	next.Page = page
+	next.Result = result
+	callbackDataKey, err := ButtonCallBackDataAddRedis(next.toCallbackData())
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": q.ChatGroupUserId,
+			"err":             err,
+		}).Error("内联键盘回调参数存入redis异常")
+		return tgbotapi.InlineKeyboardButton{}, err
+	}
+	callbackDataQueryString := utils.MapToQueryString(map[string]string{
+		"callbackDataKey": callbackDataKey,
+	})
+	return tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("%s%s", enums.CallbackMyHistoryPage.Value, callbackDataQueryString)), nil
+}
@@ -25,14 +25,25 @@ var (
 )
 
 func StartBot() {
+	initResultCardFont()
+
 	initDB()
 
 	bot := initTelegramBot()
 
 	initGameTask(bot)
 
+	initLiarsDiceTask(bot)
+
+	initReconcileTask(bot)
+	initRebateTask(bot)
+
+	initHongbaoTask(bot)
+
 	updateConfig := tgbotapi.NewUpdate(0)
 	updateConfig.Timeout = 60
+	// chat_member 更新默认不推送,需显式订阅以获取成员入群使用的邀请链接
+	updateConfig.AllowedUpdates = []string{tgbotapi.UpdateTypeMessage, tgbotapi.UpdateTypeCallbackQuery, tgbotapi.UpdateTypeChatMember}
 	updates := bot.GetUpdatesChan(updateConfig)
 
 	for update := range updates {
@@ -41,6 +52,8 @@ func StartBot() {
 			go handleMessage(bot, update.Message)
 		} else if update.CallbackQuery != nil {
 			go handleCallbackQuery(bot, update.CallbackQuery)
+		} else if update.ChatMember != nil {
+			go handleGroupChatMember(bot, update.ChatMember)
 		}
 	}
 }
@@ -68,6 +81,7 @@ func initGameTask(bot *tgbotapi.BotAPI) {
 			logrus.Printf("键 %s 不存在", redisKey)
 			issueNumber := time.Now().Format("20060102150405")
 
+			issueStart(bot, group, issueNumber)
 			go gameTaskStart(bot, group, issueNumber)
 			continue
 		} else if issueNumberResult.Err() != nil {
@@ -77,6 +91,8 @@ func initGameTask(bot *tgbotapi.BotAPI) {
 			// 有未开奖的任务
 			result, _ := issueNumberResult.Result()
 			logrus.Printf("有未开奖的任务期号:%s", result)
+			// 恢复的期号补做期号开始处理 已有的规则快照及已公布的承诺哈希不会被覆盖或重复发送
+			issueStart(bot, group, result)
 			go gameTaskStart(bot, group, result)
 			continue
 		}
@@ -105,6 +121,79 @@ func initDB() {
 		logrus.Fatal("自动迁移表结构失败:", err)
 	}
 
+	// 各玩法配置表新增chat_group_id唯一索引 需先清理并发重复创建的配置
+	for _, config := range []interface{}{&model.SignInConfig{}, &model.ReliefConfig{}, &model.RebateConfig{}, &model.ReferralConfig{}, &model.TransferConfig{}} {
+		err = model.DeleteDuplicateConfigByChatGroupId(db, config)
+		if err != nil {
+			logrus.Fatal("清理重复配置失败:", err)
+		}
+	}
+
+	err = db.AutoMigrate(&model.SignInConfig{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.SignInRecord{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.ReliefConfig{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.ReliefRecord{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.RebateConfig{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.RebateRecord{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.ReferralConfig{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.ReferralLink{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.ReferralRecord{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.Hongbao{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.HongbaoClaim{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.TransferConfig{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.TransferRecord{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
 	err = db.AutoMigrate(&model.QuickThereLotteryRecord{})
 	if err != nil {
 		logrus.Fatal("自动迁移表结构失败:", err)
@@ -115,6 +204,12 @@ func initDB() {
 		logrus.Fatal("自动迁移表结构失败:", err)
 	}
 
+	// 下注结果积分列由字符串改为decimal 需先规整历史数据
+	err = model.NormalizeLegacyBetResultAmount(db)
+	if err != nil {
+		logrus.Fatal("规整历史下注结果积分失败:", err)
+	}
+
 	err = db.AutoMigrate(&model.QuickThereBetRecord{})
 	if err != nil {
 		logrus.Fatal("自动迁移表结构失败:", err)
@@ -130,6 +225,31 @@ func initDB() {
 		logrus.Fatal("自动迁移表结构失败:", err)
 	}
 
+	err = db.AutoMigrate(&model.LiarsDiceTable{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.ProvablyFairSeed{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.BalanceLedger{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.BalanceReconcileReport{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
+	err = db.AutoMigrate(&model.BalanceAdjustRecord{})
+	if err != nil {
+		logrus.Fatal("自动迁移表结构失败:", err)
+	}
+
 	redisDB, err = database.InitRedisDB(os.Getenv(database.RedisDBConnectionString))
 	if err != nil {
 		logrus.Fatal("连接Redis数据库失败:", err)
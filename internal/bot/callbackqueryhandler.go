@@ -6,12 +6,13 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"os"
+	"strconv"
 	"strings"
 	"telegram-dice-bot/internal/common"
 	"telegram-dice-bot/internal/enums"
 	"telegram-dice-bot/internal/model"
 	"telegram-dice-bot/internal/utils"
-	"time"
 )
 
 // handleCallbackQuery 处理回调查询。
@@ -47,6 +48,21 @@ func handleCallbackQuery(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQ
 		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateGameplayType.Value) {
 			// 群配置-更新游戏类型
 			updateGameplayTypeCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackDrawSourceType.Value) {
+			// 群配置-开奖来源
+			drawSourceTypeCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateDrawSourceType.Value) {
+			// 群配置-更新开奖来源
+			updateDrawSourceTypeCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackQuickThereDiceCount.Value) {
+			// 群配置-快三-骰子数量
+			quickThereDiceCountCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateQuickThereDiceCount.Value) {
+			// 群配置-更新快三-骰子数量
+			updateQuickThereDiceCountCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateQuickThereSmallMax.Value) {
+			// 群配置-更新快三-大小分界
+			updateQuickThereSmallMaxCallBack(bot, callbackQuery)
 		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateQuickThereSimpleOdds.Value) {
 			// 群配置-更新快三-简易赔率
 			updateQuickThereSimpleOddsCallBack(bot, callbackQuery)
@@ -59,12 +75,72 @@ func handleCallbackQuery(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQ
 		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateGameDrawCycle.Value) {
 			// 群配置-更新游戏开奖周期
 			updateGameDrawCycleCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateRegisterReward.Value) {
+			// 群配置-更新注册奖励
+			updateRegisterRewardCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateSignInRewardLadder.Value) {
+			// 群配置-更新签到奖励阶梯
+			updateSignInRewardLadderCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateSignInMode.Value) {
+			// 群配置-更新签到模式
+			updateSignInModeCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateRollRewardTable.Value) {
+			// 群配置-更新掷骰签到奖励表
+			updateRollRewardTableCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateReliefStatus.Value) {
+			// 群配置-开启/关闭救济金
+			updateReliefStatusCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateReliefRule.Value) {
+			// 群配置-更新救济金规则
+			updateReliefRuleCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateRebateStatus.Value) {
+			// 群配置-开启/关闭反水
+			updateRebateStatusCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateRebateMode.Value) {
+			// 群配置-切换反水计算方式
+			updateRebateModeCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateRebateRule.Value) {
+			// 群配置-更新反水规则
+			updateRebateRuleCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateReferralStatus.Value) {
+			// 群配置-开启/关闭邀请奖励
+			updateReferralStatusCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateReferralRule.Value) {
+			// 群配置-更新邀请奖励规则
+			updateReferralRuleCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateTransferRule.Value) {
+			// 群配置-更新转让规则
+			updateTransferRuleCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateTransferFeeMode.Value) {
+			// 群配置-切换转让手续费去向
+			updateTransferFeeModeCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateTransferHouseAccount.Value) {
+			// 群配置-更新转让手续费收费账户
+			updateTransferHouseAccountCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackTransferApprovalList.Value) {
+			// 群配置-待审批转让
+			transferApprovalListCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackTransferApprove.Value) {
+			// 通过转让审批
+			reviewTransferCallBack(bot, callbackQuery, enums.CallbackTransferApprove)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackTransferReject.Value) {
+			// 拒绝转让审批
+			reviewTransferCallBack(bot, callbackQuery, enums.CallbackTransferReject)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackChatGroupUserLedger.Value) {
+			// 群用户积分流水
+			chatGroupUserLedgerCallBack(bot, callbackQuery)
 		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackQueryChatGroupUser.Value) {
 			// 查询用户信息
 			queryChatGroupUser(bot, callbackQuery)
 		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateChatGroupUserBalance.Value) {
 			// 修改用户积分
 			updateChatGroupUserBalance(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateBetReplyStatus.Value) {
+			// 开启/关闭逐条回复下注成功
+			updateBetReplyStatusCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackUpdateSettleSummaryStatus.Value) {
+			// 开启/关闭群内结算汇总
+			updateSettleSummaryStatusCallBack(bot, callbackQuery)
 		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackAdminExitGroup.Value) {
 			// 管理员退群
 			exitAdminGroupCallBack(bot, callbackQuery)
@@ -73,6 +149,57 @@ func handleCallbackQuery(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQ
 		if callbackQuery.Data == enums.CallbackLotteryHistory.Value {
 			// 群内联键盘 查看开奖历史
 			lotteryHistoryCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackLotteryHistoryPage.Value) {
+			// 开奖历史翻页
+			lotteryHistoryPageCallBack(bot, callbackQuery)
+		} else if callbackQuery.Data == enums.CallbackLotteryTrend.Value {
+			// 群内联键盘 查看走势图
+			lotteryTrendCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackLiarsDiceJoin.Value) {
+			// 吹牛骰子-加入
+			liarsDiceCallBack(bot, callbackQuery, enums.CallbackLiarsDiceJoin)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackLiarsDiceStart.Value) {
+			// 吹牛骰子-开始
+			liarsDiceCallBack(bot, callbackQuery, enums.CallbackLiarsDiceStart)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackLiarsDiceCancel.Value) {
+			// 吹牛骰子-取消
+			liarsDiceCallBack(bot, callbackQuery, enums.CallbackLiarsDiceCancel)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackLiarsDiceBid.Value) {
+			// 吹牛骰子-叫数
+			liarsDiceCallBack(bot, callbackQuery, enums.CallbackLiarsDiceBid)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackLiarsDiceCall.Value) {
+			// 吹牛骰子-开
+			liarsDiceCallBack(bot, callbackQuery, enums.CallbackLiarsDiceCall)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackLiarsDiceMyDice.Value) {
+			// 吹牛骰子-查看我的骰子
+			liarsDiceCallBack(bot, callbackQuery, enums.CallbackLiarsDiceMyDice)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackHongbaoGrab.Value) {
+			// 抢红包
+			hongbaoGrabCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackGiveConfirm.Value) {
+			// 群内转让积分-确认
+			giveCallBack(bot, callbackQuery, enums.CallbackGiveConfirm)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackGiveCancel.Value) {
+			// 群内转让积分-取消
+			giveCallBack(bot, callbackQuery, enums.CallbackGiveCancel)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackRankTab.Value) {
+			// 排行榜切换
+			rankTabCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackProfileScope.Value) {
+			// 个人统计范围切换
+			profileScopeCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackMyHistoryPage.Value) {
+			// 下注记录翻页/筛选
+			myHistoryPageCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackBetChipType.Value) {
+			// 快捷下注 选择竞猜类型
+			betChipTypeCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackBetChipAmount.Value) {
+			// 快捷下注 点击筹码
+			betChipAmountCallBack(bot, callbackQuery)
+		} else if strings.HasPrefix(callbackQuery.Data, enums.CallbackBetChipRepeat.Value) {
+			// 快捷下注 重复上次下注
+			betChipRepeatCallBack(bot, callbackQuery)
 		}
 	}
 }
@@ -164,7 +291,8 @@ func transferBalanceCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery
 	chatGroupId := callBackData["chatGroupId"]
 
 	sendMsg := tgbotapi.NewMessage(fromChatId, "请按照以下格式转让用户积分:\n"+
-		"[用户Id]+[积分] 例子: 10086+100")
+		"[用户Id]+[积分] 例子: 10086+100\n"+
+		"也可在群内回复对方消息发送 /give 100,或发送 /give @username 100")
 
 	// 设置当前机器人状态
 	err = PrivateChatCacheAddRedis(fromUser.ID, &common.BotPrivateChatCache{
@@ -347,7 +475,7 @@ func chatGroupInfoCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery)
 		return
 	}
 
-	sendMsg := tgbotapi.NewEditMessageText(fromChatId, messageId, fmt.Sprintf("您在【%s】中的信息:\n用户ID:%s\n积分余额:%.2f\n", chatGroup.TgChatGroupTitle, chatGroupUser.Id, chatGroupUser.Balance))
+	sendMsg := tgbotapi.NewEditMessageText(fromChatId, messageId, fmt.Sprintf("您在【%s】中的信息:\n用户ID:%s\n积分余额:%s\n", chatGroup.TgChatGroupTitle, chatGroupUser.Id, utils.FormatAmount(chatGroupUser.Balance)))
 
 	// 重新生成内联键盘回调key
 	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
@@ -506,88 +634,195 @@ func updateQuickThereSimpleOddsCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.Ca
 	}
 }
 
-func lotteryHistoryCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
-	tgChatGroupId := query.Message.Chat.ID
+func quickThereDiceCountCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatId := query.Message.Chat.ID
+	fromUser := query.From
+	messageId := query.Message.MessageID
 
-	// 查询该群历史开奖信息
-	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	callBackData, err := queryCallBackData(query, enums.CallbackQuickThereDiceCount)
 	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		logrus.WithFields(logrus.Fields{
-			"tgChatGroupId": tgChatGroupId,
-			"err":           err,
-		}).Error("群配置查询异常")
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
 		return
 	}
 
-	sendMsg := tgbotapi.NewMessage(tgChatGroupId, "")
+	quickThereConfig, err := model.QueryQuickThereConfigByChatGroupId(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("快三配置查询异常")
+		return
+	}
 
-	lotteryRecord := &model.LotteryRecord{ChatGroupId: chatGroup.Id}
-	lotteryRecords, err := lotteryRecord.ListByChatGroupId(db)
+	sendMsg := tgbotapi.NewEditMessageText(chatId, messageId, fmt.Sprintf("请选择【经典快三】骰子数量(下一期生效,大小分界将重置为默认值):\n\n%s", buildQuickThereOddsSuggestion(quickThereConfig)))
+
+	inlineKeyboardRows, err := buildQuickThereDiceCountInlineKeyboardButton(chatGroupId)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
-			"tgChatGroupId": tgChatGroupId,
-			"err":           err,
-		}).Error("开奖记录查询异常")
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("组装骰子数量内联键盘异常")
 		return
 	}
-	if len(lotteryRecords) == 0 {
-		sendMsg.Text = "暂无开奖记录"
-	} else {
-		sendMsg.Text = "近10期开奖记录:\n"
-		for _, record := range lotteryRecords {
-			// 开奖类型查询开奖信息
-			switch record.GameplayType {
-			case enums.QuickThere.Value:
-				quickThereLotteryRecord := &model.QuickThereLotteryRecord{
-					Id: record.Id,
-				}
-				quickThereLotteryRecord, err := quickThereLotteryRecord.QueryById(db)
-				if err != nil {
-					logrus.WithFields(logrus.Fields{
-						"IssueNumber": record.IssueNumber,
-					}).Error("快三开奖记录查询异常")
-					return
-				}
-
-				bigSmall, _ := enums.GetGameLotteryType(quickThereLotteryRecord.BigSmall)
-				singleDouble, _ := enums.GetGameLotteryType(quickThereLotteryRecord.SingleDouble)
-
-				triplet := ""
-				if quickThereLotteryRecord.Triplet == 1 {
-					triplet = "【豹子】"
-				}
-
-				sendMsg.Text += fmt.Sprintf("%s期 %s %d+%d+%d=%d %s %s %s\n",
-					quickThereLotteryRecord.IssueNumber,
-					"快三",
-					quickThereLotteryRecord.ValueA,
-					quickThereLotteryRecord.ValueB,
-					quickThereLotteryRecord.ValueC,
-					quickThereLotteryRecord.ValueA+quickThereLotteryRecord.ValueB+quickThereLotteryRecord.ValueC,
-					bigSmall.Name,
-					singleDouble.Name,
-					triplet,
-				)
-			}
-		}
+
+	newInlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(
+		inlineKeyboardRows...,
+	)
+
+	sendMsg.ReplyMarkup = &newInlineKeyboardMarkup
+
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
 	}
-	sentMsg, err := sendMessage(bot, &sendMsg)
+}
 
+func updateQuickThereDiceCountCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatId := query.Message.Chat.ID
+	fromUser := query.From
+	messageId := query.Message.MessageID
+
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateQuickThereDiceCount)
 	if err != nil {
-		blockedOrKicked(err, tgChatGroupId)
+		logrus.Error("内联键盘回调参数redis查询异常")
 		return
 	}
 
-	go func(messageID int) {
-		time.Sleep(1 * time.Minute)
-		deleteMsg := tgbotapi.NewDeleteMessage(tgChatGroupId, messageID)
-		_, err := bot.Request(deleteMsg)
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"err": err,
-			}).Error("删除消息异常")
-		}
-	}(sentMsg.MessageID)
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	diceCount, err := strconv.Atoi(callBackData["diceCount"])
+	if err != nil || diceCount < QuickThereMinDiceCount || diceCount > QuickThereMaxDiceCount {
+		logrus.WithField("diceCount", callBackData["diceCount"]).Error("骰子数量异常")
+		return
+	}
+
+	// 更改配置 大小分界重置为该骰子数量的默认值
+	quickThereConfigUpdate := &model.QuickThereConfig{
+		ChatGroupId:   chatGroupId,
+		DiceCount:     diceCount,
+		SmallMaxTotal: defaultSmallMaxTotal(diceCount),
+	}
+	err = quickThereConfigUpdate.UpdateDiceCountByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"DiceCount":   diceCount,
+			"err":         err,
+		}).Error("设置快三骰子数量异常")
+		return
+	}
+
+	quickThereConfig, err := model.QueryQuickThereConfigByChatGroupId(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("快三配置查询异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(chatId, messageId, fmt.Sprintf("设置成功!\n请选择【经典快三】骰子数量(下一期生效,大小分界将重置为默认值):\n\n%s", buildQuickThereOddsSuggestion(quickThereConfig)))
+
+	inlineKeyboardRows, err := buildQuickThereDiceCountInlineKeyboardButton(chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("组装骰子数量内联键盘异常")
+		return
+	}
+
+	newInlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(
+		inlineKeyboardRows...,
+	)
+
+	sendMsg.ReplyMarkup = &newInlineKeyboardMarkup
+
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+}
+
+func updateQuickThereSmallMaxCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatId := query.Message.Chat.ID
+	fromUser := query.From
+
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateQuickThereSmallMax)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	quickThereConfig, err := model.QueryQuickThereConfigByChatGroupId(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("快三配置查询异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("请输入️要设置的【经典快三】大小分界(总点数小于等于该值为小,范围%d-%d):", quickThereConfig.DiceCount, quickThereConfig.DiceCount*6-1))
+
+	// 设置当前机器人状态
+	err = PrivateChatCacheAddRedis(fromUser.ID, &common.BotPrivateChatCache{
+		ChatStatus:  enums.WaitQuickThereSmallMax.Value,
+		ChatGroupId: chatGroupId,
+	})
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"fromUserId":  fromUser.ID,
+			"ChatStatus":  enums.WaitQuickThereSmallMax.Value,
+			"ChatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("BotChatStatus 设置异常")
+		return
+	}
+
+	_, err = sendMessage(bot, &sendMsg)
+
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
 }
 
 func updateChatGroupUserBalance(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
@@ -951,6 +1186,127 @@ func updateGameplayTypeCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQu
 
 }
 
+func drawSourceTypeCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatId := query.Message.Chat.ID
+	fromUser := query.From
+	messageId := query.Message.MessageID
+
+	callBackData, err := queryCallBackData(query, enums.CallbackDrawSourceType)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(chatId, messageId, "请选择开奖来源(下一期生效):")
+
+	inlineKeyboardRows, err := buildDrawSourceTypeInlineKeyboardButton(chatGroupId)
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("组装开奖来源内联键盘异常")
+		return
+	}
+
+	newInlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(
+		inlineKeyboardRows...,
+	)
+
+	sendMsg.ReplyMarkup = &newInlineKeyboardMarkup
+
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+}
+
+func updateDrawSourceTypeCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatId := query.Message.Chat.ID
+	fromUser := query.From
+	messageId := query.Message.MessageID
+
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateDrawSourceType)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+	drawSourceType := callBackData["drawSourceType"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	if _, b := enums.GetDrawSourceType(drawSourceType); !b {
+		logrus.WithField("drawSourceType", drawSourceType).Error("开奖来源映射异常")
+		return
+	}
+
+	if drawSourceType == enums.HttpFeedDrawSource.Value && os.Getenv(DrawFeedURL) == "" {
+		answerCallbackQuery(bot, query, fmt.Sprintf("未配置外部数据源地址,请先设置环境变量%s!", DrawFeedURL), true)
+		return
+	}
+
+	// 更改配置
+	chatGroupUpdate := &model.ChatGroup{
+		Id:             chatGroupId,
+		DrawSourceType: drawSourceType,
+	}
+	err = chatGroupUpdate.UpdateDrawSourceTypeById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId":    chatGroupId,
+			"DrawSourceType": drawSourceType,
+			"err":            err,
+		}).Error("更新群配置异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(chatId, messageId, "请选择开奖来源(下一期生效):")
+
+	inlineKeyboardRows, err := buildDrawSourceTypeInlineKeyboardButton(chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("组装开奖来源内联键盘异常")
+		return
+	}
+
+	newInlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(
+		inlineKeyboardRows...,
+	)
+
+	sendMsg.ReplyMarkup = &newInlineKeyboardMarkup
+
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+}
+
 func gameplayTypeCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
 	chatId := query.Message.Chat.ID
 	fromUser := query.From
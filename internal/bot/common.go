@@ -8,6 +8,7 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"strconv"
 	"strings"
 	"telegram-dice-bot/internal/common"
 	"telegram-dice-bot/internal/enums"
@@ -66,6 +67,35 @@ func blockedOrKicked(err error, chatId int64) {
 
 }
 
+// answerCallbackQuery 应答回调查询,showAlert为true时以弹窗形式展示。
+func answerCallbackQuery(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, text string, showAlert bool) {
+	callbackConfig := tgbotapi.NewCallback(query.ID, text)
+	callbackConfig.ShowAlert = showAlert
+	_, err := bot.Request(callbackConfig)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"callbackQueryId": query.ID,
+			"err":             err,
+		}).Error("应答回调查询异常")
+	}
+}
+
+// queryCallBackData 解析内联键盘Data中的callbackDataKey,并从redis中查询回调参数。
+func queryCallBackData(query *tgbotapi.CallbackQuery, callbackPrefix enums.CallbackPrefix) (map[string]string, error) {
+	queryString := query.Data[strings.Index(query.Data, callbackPrefix.Value)+len(callbackPrefix.Value):]
+
+	queryStringToMap, err := utils.QueryStringToMap(queryString)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"queryData": query.Data,
+			"err":       err,
+		}).Error("内联键盘解析异常")
+		return nil, err
+	}
+
+	return ButtonCallBackDataQueryFromRedis(queryStringToMap["callbackDataKey"])
+}
+
 // getChatMember 获取有关聊天成员的信息。
 func getChatMember(bot *tgbotapi.BotAPI, chatID int64, userId int64) (tgbotapi.ChatMember, error) {
 	chatMemberConfig := tgbotapi.ChatConfigWithUser{
@@ -87,9 +117,18 @@ func buildDefaultInlineKeyboardMarkup(bot *tgbotapi.BotAPI) *tgbotapi.InlineKeyb
 	return &newInlineKeyboardMarkup
 }
 
-func buildGameplayConfigInlineKeyboardButton(chatGroup *model.ChatGroup, callbackDataQueryString string) ([]tgbotapi.InlineKeyboardButton, error) {
+func buildGameplayConfigInlineKeyboardButton(chatGroup *model.ChatGroup, callbackDataQueryString string) ([][]tgbotapi.InlineKeyboardButton, error) {
+
+	betReplyStatus, b := enums.GetGameplayStatus(chatGroup.BetReplyStatus)
+	if !b {
+		betReplyStatus = enums.GameplayStatusON
+	}
+	settleSummaryStatus, b := enums.GetGameplayStatus(chatGroup.SettleSummaryStatus)
+	if !b {
+		settleSummaryStatus = enums.GameplayStatusON
+	}
 
-	var inlineKeyboardButton []tgbotapi.InlineKeyboardButton
+	var inlineKeyboardRows [][]tgbotapi.InlineKeyboardButton
 	if chatGroup.GameplayType == enums.QuickThere.Value {
 		// 查询该配置
 		quickThereConfig, err := model.QueryQuickThereConfigByChatGroupId(db, chatGroup.Id)
@@ -97,13 +136,23 @@ func buildGameplayConfigInlineKeyboardButton(chatGroup *model.ChatGroup, callbac
 		if err != nil {
 			return nil, err
 		}
-		inlineKeyboardButton = tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⚖️简易倍率: %v 倍", quickThereConfig.SimpleOdds), fmt.Sprintf("%s%s", enums.CallbackUpdateQuickThereSimpleOdds.Value, callbackDataQueryString)),
-			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⚖️豹子倍率: %v 倍", quickThereConfig.TripletOdds), fmt.Sprintf("%s%s", enums.CallbackUpdateQuickThereTripletOdds.Value, callbackDataQueryString)),
+		inlineKeyboardRows = append(inlineKeyboardRows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🎲骰子数量: %d 颗", quickThereConfig.DiceCount), fmt.Sprintf("%s%s", enums.CallbackQuickThereDiceCount.Value, callbackDataQueryString)),
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📏小于等于 %d 为小", quickThereConfig.SmallMaxTotal), fmt.Sprintf("%s%s", enums.CallbackUpdateQuickThereSmallMax.Value, callbackDataQueryString)),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⚖️简易倍率: %s 倍", utils.FormatAmount(quickThereConfig.SimpleOdds)), fmt.Sprintf("%s%s", enums.CallbackUpdateQuickThereSimpleOdds.Value, callbackDataQueryString)),
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⚖️豹子倍率: %s 倍", utils.FormatAmount(quickThereConfig.TripletOdds)), fmt.Sprintf("%s%s", enums.CallbackUpdateQuickThereTripletOdds.Value, callbackDataQueryString)),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("💬下注回复: %s", betReplyStatus.Name), fmt.Sprintf("%s%s", enums.CallbackUpdateBetReplyStatus.Value, callbackDataQueryString)),
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📋结算汇总: %s", settleSummaryStatus.Name), fmt.Sprintf("%s%s", enums.CallbackUpdateSettleSummaryStatus.Value, callbackDataQueryString)),
+			),
 		)
 	}
 
-	return inlineKeyboardButton, nil
+	return inlineKeyboardRows, nil
 }
 
 func buildJoinedGroupMsg(query *tgbotapi.CallbackQuery) (*tgbotapi.EditMessageTextConfig, error) {
@@ -395,6 +444,30 @@ func ButtonCallBackDataQueryFromRedis(key string) (map[string]string, error) {
 	}
 }
 
+// ButtonCallBackDataDelRedis 删除回调参数 返回是否由本次调用删除 用于一次性按钮防止重复提交
+func ButtonCallBackDataDelRedis(key string) (bool, error) {
+	redisKey := fmt.Sprintf(RedisButtonCallBackDataKey, key)
+	deleted, err := redisDB.Del(redisDB.Context(), redisKey).Result()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("redis删除键盘回调信息异常")
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// ButtonCallBackDataRestoreRedis 按原key恢复已删除的回调参数 用于一次性按钮处理失败后允许重试
+func ButtonCallBackDataRestoreRedis(key string, queryMap map[string]string) error {
+	jsonBytes, err := json.Marshal(queryMap)
+	if err != nil {
+		return err
+	}
+	redisKey := fmt.Sprintf(RedisButtonCallBackDataKey, key)
+	return redisDB.SetNX(redisDB.Context(), redisKey, string(jsonBytes), 1*time.Hour).Err()
+}
+
 func PrivateChatCacheAddRedis(tgUserID int64, botPrivateChatCache *common.BotPrivateChatCache) error {
 
 	jsonBytes, err := json.Marshal(botPrivateChatCache)
@@ -426,6 +499,11 @@ func buildChatGroupInlineKeyboardMarkup(query *tgbotapi.CallbackQuery, chatGroup
 		return nil, errors.New("群配置游戏状态查询异常")
 	}
 
+	drawSourceType, b := enums.GetDrawSourceType(chatGroup.DrawSourceType)
+	if !b {
+		drawSourceType = enums.TelegramDiceDrawSource
+	}
+
 	// 重新生成内联键盘回调key
 	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
 		"chatGroupId": chatGroup.Id,
@@ -443,7 +521,7 @@ func buildChatGroupInlineKeyboardMarkup(query *tgbotapi.CallbackQuery, chatGroup
 		"callbackDataKey": callbackDataKey,
 	})
 
-	inlineKeyboardButtons, err := buildGameplayConfigInlineKeyboardButton(chatGroup, callbackDataQueryString)
+	gameplayConfigInlineKeyboardRows, err := buildGameplayConfigInlineKeyboardButton(chatGroup, callbackDataQueryString)
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		logrus.WithFields(logrus.Fields{
@@ -458,15 +536,53 @@ func buildChatGroupInlineKeyboardMarkup(query *tgbotapi.CallbackQuery, chatGroup
 		return nil, err
 	}
 
-	newInlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(
+	signInConfigInlineKeyboardRows, err := buildSignInConfigInlineKeyboardRows(chatGroup.Id, callbackDataQueryString)
+	if err != nil {
+		return nil, err
+	}
+
+	reliefConfigInlineKeyboardRow, err := buildReliefConfigInlineKeyboardRow(chatGroup.Id, callbackDataQueryString)
+	if err != nil {
+		return nil, err
+	}
+
+	rebateConfigInlineKeyboardRow, err := buildRebateConfigInlineKeyboardRow(chatGroup.Id, callbackDataQueryString)
+	if err != nil {
+		return nil, err
+	}
+
+	referralConfigInlineKeyboardRow, err := buildReferralConfigInlineKeyboardRow(chatGroup.Id, callbackDataQueryString)
+	if err != nil {
+		return nil, err
+	}
+
+	transferConfigInlineKeyboardRows, err := buildTransferConfigInlineKeyboardRows(chatGroup.Id, callbackDataQueryString)
+	if err != nil {
+		return nil, err
+	}
+
+	var inlineKeyboardRows [][]tgbotapi.InlineKeyboardButton
+	inlineKeyboardRows = append(inlineKeyboardRows,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🛠️当前玩法:【%s】", gameplayType.Name), fmt.Sprintf("%s%s", enums.CallbackGameplayType.Value, callbackDataQueryString)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🎯开奖来源:【%s】", drawSourceType.Name), fmt.Sprintf("%s%s", enums.CallbackDrawSourceType.Value, callbackDataQueryString)),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🕹️开启状态: %s", gameplayStatus.Name), fmt.Sprintf("%s%s", enums.CallbackUpdateGameplayStatus.Value, callbackDataQueryString)),
 			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⏲️开奖周期: %v 分钟", chatGroup.GameDrawCycle), fmt.Sprintf("%s%s", enums.CallbackUpdateGameDrawCycle.Value, callbackDataQueryString)),
 		),
-		inlineKeyboardButtons,
+	)
+	inlineKeyboardRows = append(inlineKeyboardRows, gameplayConfigInlineKeyboardRows...)
+	inlineKeyboardRows = append(inlineKeyboardRows, signInConfigInlineKeyboardRows...)
+	inlineKeyboardRows = append(inlineKeyboardRows,
+		reliefConfigInlineKeyboardRow,
+		rebateConfigInlineKeyboardRow,
+		referralConfigInlineKeyboardRow,
+	)
+	inlineKeyboardRows = append(inlineKeyboardRows, transferConfigInlineKeyboardRows...)
+	inlineKeyboardRows = append(inlineKeyboardRows,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔍查询用户信息", fmt.Sprintf("%s%s", enums.CallbackQueryChatGroupUser.Value, callbackDataQueryString)),
 			tgbotapi.NewInlineKeyboardButtonData("🖊️修改用户积分", fmt.Sprintf("%s%s", enums.CallbackUpdateChatGroupUserBalance.Value, callbackDataQueryString)),
@@ -476,5 +592,267 @@ func buildChatGroupInlineKeyboardMarkup(query *tgbotapi.CallbackQuery, chatGroup
 			tgbotapi.NewInlineKeyboardButtonData("🚮我已退群", fmt.Sprintf("%s%s", enums.CallbackAdminExitGroup.Value, callbackDataQueryString)),
 		),
 	)
+
+	newInlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(inlineKeyboardRows...)
 	return &newInlineKeyboardMarkup, nil
 }
+
+func buildDrawSourceTypeInlineKeyboardButton(chatGroupId string) ([][]tgbotapi.InlineKeyboardButton, error) {
+
+	ChatGroup, err := model.QueryChatGroupById(db, chatGroupId)
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+		}).Warn("未查询到群组信息 [未初始化过配置]")
+		return nil, err
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("群组信息查询异常")
+		return nil, err
+	}
+
+	var inlineKeyboardRows [][]tgbotapi.InlineKeyboardButton
+
+	for _, value := range []enums.DrawSourceType{enums.TelegramDiceDrawSource, enums.LocalRandomDrawSource, enums.HttpFeedDrawSource, enums.ProvablyFairDrawSource} {
+
+		callBackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+			"chatGroupId":    chatGroupId,
+			"drawSourceType": value.Value,
+		})
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId":    ChatGroup.Id,
+				"drawSourceType": value.Value,
+				"err":            err,
+			}).Error("内联键盘回调参数存入redis异常")
+			return nil, err
+		}
+
+		buttonDataText := value.Name
+
+		if ChatGroup.DrawSourceType == value.Value {
+			buttonDataText = fmt.Sprintf("%s✅", buttonDataText)
+		}
+
+		callBackDataQueryString := utils.MapToQueryString(map[string]string{
+			"callbackDataKey": callBackDataKey,
+		})
+
+		inlineKeyboardRows = append(inlineKeyboardRows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(buttonDataText, fmt.Sprintf("%s%s", enums.CallbackUpdateDrawSourceType.Value, callBackDataQueryString)),
+			),
+		)
+	}
+
+	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+		"chatGroupId": ChatGroup.Id,
+	})
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": ChatGroup.Id,
+			"err":         err,
+		}).Error("内联键盘回调参数存入redis异常")
+		return nil, err
+	}
+
+	callBackDataQueryString := utils.MapToQueryString(map[string]string{
+		"callbackDataKey": callbackDataKey,
+	})
+
+	inlineKeyboardRows = append(inlineKeyboardRows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️返回", fmt.Sprintf("%s%s", enums.CallbackChatGroupConfig.Value, callBackDataQueryString)),
+		),
+	)
+	return inlineKeyboardRows, nil
+}
+
+func buildQuickThereDiceCountInlineKeyboardButton(chatGroupId string) ([][]tgbotapi.InlineKeyboardButton, error) {
+
+	quickThereConfig, err := model.QueryQuickThereConfigByChatGroupId(db, chatGroupId)
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+		}).Warn("未查询到该群的快三配置")
+		return nil, err
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("快三配置查询异常")
+		return nil, err
+	}
+
+	var inlineKeyboardButtons []tgbotapi.InlineKeyboardButton
+
+	for diceCount := QuickThereMinDiceCount; diceCount <= QuickThereMaxDiceCount; diceCount++ {
+
+		callBackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+			"chatGroupId": chatGroupId,
+			"diceCount":   strconv.Itoa(diceCount),
+		})
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroupId,
+				"diceCount":   diceCount,
+				"err":         err,
+			}).Error("内联键盘回调参数存入redis异常")
+			return nil, err
+		}
+
+		buttonDataText := fmt.Sprintf("%d颗", diceCount)
+
+		if quickThereConfig.DiceCount == diceCount {
+			buttonDataText = fmt.Sprintf("%s✅", buttonDataText)
+		}
+
+		callBackDataQueryString := utils.MapToQueryString(map[string]string{
+			"callbackDataKey": callBackDataKey,
+		})
+
+		inlineKeyboardButtons = append(inlineKeyboardButtons,
+			tgbotapi.NewInlineKeyboardButtonData(buttonDataText, fmt.Sprintf("%s%s", enums.CallbackUpdateQuickThereDiceCount.Value, callBackDataQueryString)),
+		)
+	}
+
+	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+		"chatGroupId": chatGroupId,
+	})
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("内联键盘回调参数存入redis异常")
+		return nil, err
+	}
+
+	callBackDataQueryString := utils.MapToQueryString(map[string]string{
+		"callbackDataKey": callbackDataKey,
+	})
+
+	inlineKeyboardRows := [][]tgbotapi.InlineKeyboardButton{
+		inlineKeyboardButtons,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️返回", fmt.Sprintf("%s%s", enums.CallbackChatGroupConfig.Value, callBackDataQueryString)),
+		),
+	}
+	return inlineKeyboardRows, nil
+}
+
+// updateChatGroupStatusCallBack 校验管理员后切换群内某项开关(开启/关闭)并刷新群配置菜单
+// getStatus 返回当前开关状态 updateStatus 保存切换后的状态
+func updateChatGroupStatusCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, callbackPrefix enums.CallbackPrefix, getStatus func(chatGroup *model.ChatGroup) (int, error), updateStatus func(chatGroup *model.ChatGroup, status int) error) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+	fromUser := query.From
+
+	callBackData, err := queryCallBackData(query, callbackPrefix)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	status, err := getStatus(chatGroup)
+	if err != nil {
+		return
+	}
+
+	newStatus := enums.GameplayStatusON.Value
+	if status == enums.GameplayStatusON.Value {
+		newStatus = enums.GameplayStatusOFF.Value
+	}
+	err = updateStatus(chatGroup, newStatus)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"callback":    callbackPrefix.Name,
+			"status":      newStatus,
+			"err":         err,
+		}).Error("更新开关状态异常")
+		return
+	}
+
+	inlineKeyboardMarkup, err := buildChatGroupInlineKeyboardMarkup(query, chatGroup)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"err": err,
+		}).Error("组装群组配置内联键盘异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("点击修改【%s】相关配置:", chatGroup.TgChatGroupTitle))
+	sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// chatGroupConfig 按群保存的配置 chat_group_id 唯一
+type chatGroupConfig interface {
+	Create(db *gorm.DB) error
+}
+
+// queryOrCreateConfig 查询群配置 不存在时按默认值创建(兼容历史群) 其他请求已并发创建时直接查询已有配置
+func queryOrCreateConfig[T chatGroupConfig](chatGroupId string, configName string, query func(db *gorm.DB, chatGroupId string) (T, error), newDefault func() T) (T, error) {
+	var zero T
+	config, err := query(db, chatGroupId)
+	if err == nil {
+		return config, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("查询" + configName + "异常")
+		return zero, err
+	}
+
+	config = newDefault()
+	err = config.Create(db)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		config, err = query(db, chatGroupId)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroupId,
+				"err":         err,
+			}).Error("查询" + configName + "异常")
+			return zero, err
+		}
+		return config, nil
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("初始化" + configName + "异常")
+		return zero, err
+	}
+	return config, nil
+}
@@ -0,0 +1,378 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	DrawFeedURL = "DRAW_FEED_URL"
+)
+
+// DrawResult 开奖结果
+type DrawResult struct {
+	Values     []int // 骰子点数
+	MessageIds []int // 公布点数的消息ID(Telegram骰子消息或开奖文本消息)
+}
+
+// DrawSource 开奖结果来源 Draw 只负责产生骰子点数,Announce 负责在群内公布并记录公布消息ID
+// Telegram骰子的点数由骰子消息本身产生,因此只有该来源在 Draw 中发送消息
+type DrawSource interface {
+	Draw(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, numDice int) (*DrawResult, error)
+	Announce(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, result *DrawResult) error
+}
+
+// IssueStartHandler 需要在每期开始时执行操作的开奖来源实现此接口(如公布承诺哈希)
+type IssueStartHandler interface {
+	IssueStart(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string) error
+}
+
+var drawSources = map[string]DrawSource{
+	enums.TelegramDiceDrawSource.Value: &telegramDiceDrawSource{},
+	enums.LocalRandomDrawSource.Value:  &localRandomDrawSource{},
+	enums.HttpFeedDrawSource.Value: &httpFeedDrawSource{
+		feedURL: os.Getenv(DrawFeedURL),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	},
+	enums.ProvablyFairDrawSource.Value: &provablyFairDrawSource{},
+}
+
+// getDrawSource 获取群配置的开奖来源 未配置或配置无效时使用Telegram骰子
+func getDrawSource(group *model.ChatGroup) DrawSource {
+	if drawSource, ok := drawSources[group.DrawSourceType]; ok {
+		return drawSource
+	}
+	return drawSources[enums.TelegramDiceDrawSource.Value]
+}
+
+// drawSourceIssueStart 期号开始时通知群配置的开奖来源
+func drawSourceIssueStart(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string) {
+	issueStartHandler, ok := getDrawSource(group).(IssueStartHandler)
+	if !ok {
+		return
+	}
+	err := issueStartHandler.IssueStart(bot, group, issueNumber)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": group.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("开奖来源期号开始处理异常")
+	}
+}
+
+// telegramDiceDrawSource 发送Telegram骰子消息 以骰子动画的结果作为开奖点数
+type telegramDiceDrawSource struct{}
+
+func (s *telegramDiceDrawSource) Draw(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, numDice int) (*DrawResult, error) {
+	diceValues := make([]int, numDice)
+	messageIds := make([]int, numDice)
+	diceConfig := tgbotapi.NewDiceWithEmoji(group.TgChatGroupId, "🎲")
+
+	for i := 0; i < numDice; i++ {
+		diceMsg, err := bot.Send(diceConfig)
+		if err != nil {
+			logrus.WithField("err", err).Error("发送骰子消息异常")
+			return nil, err
+		}
+		diceValues[i] = diceMsg.Dice.Value
+		messageIds[i] = diceMsg.MessageID
+	}
+
+	// 等待骰子动画结束
+	time.Sleep(3 * time.Second)
+
+	return &DrawResult{Values: diceValues, MessageIds: messageIds}, nil
+}
+
+// Announce 骰子消息即为公布 无需再发送
+func (s *telegramDiceDrawSource) Announce(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, result *DrawResult) error {
+	return nil
+}
+
+// localRandomDrawSource 使用crypto/rand在本地产生点数 并以文本公布
+type localRandomDrawSource struct{}
+
+func (s *localRandomDrawSource) Draw(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, numDice int) (*DrawResult, error) {
+	diceValues := make([]int, numDice)
+	for i := 0; i < numDice; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(6))
+		if err != nil {
+			logrus.WithField("err", err).Error("生成随机数异常")
+			return nil, err
+		}
+		diceValues[i] = int(n.Int64()) + 1
+	}
+
+	return &DrawResult{Values: diceValues}, nil
+}
+
+func (s *localRandomDrawSource) Announce(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, result *DrawResult) error {
+	return announceDrawValues(bot, group, issueNumber, enums.LocalRandomDrawSource, result)
+}
+
+// httpFeedDrawSource 从外部开奖结果提供方获取点数
+// 请求: GET {DRAW_FEED_URL}?chatGroupId=xxx&issueNumber=xxx&numDice=3
+// 响应: {"values":[1,2,3]}
+type httpFeedDrawSource struct {
+	feedURL string
+	client  *http.Client
+}
+
+type httpFeedDrawResponse struct {
+	Values []int `json:"values"`
+}
+
+func (s *httpFeedDrawSource) Draw(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, numDice int) (*DrawResult, error) {
+	if s.feedURL == "" {
+		return nil, errors.New("未配置外部数据源地址")
+	}
+
+	feedURL, err := url.Parse(s.feedURL)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"feedURL": s.feedURL,
+			"err":     err,
+		}).Error("外部数据源地址解析异常")
+		return nil, err
+	}
+	query := feedURL.Query()
+	query.Set("chatGroupId", group.Id)
+	query.Set("issueNumber", issueNumber)
+	query.Set("numDice", strconv.Itoa(numDice))
+	feedURL.RawQuery = query.Encode()
+
+	resp, err := s.client.Get(feedURL.String())
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"feedURL": feedURL.String(),
+			"err":     err,
+		}).Error("请求外部数据源异常")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logrus.WithFields(logrus.Fields{
+			"feedURL":    feedURL.String(),
+			"statusCode": resp.StatusCode,
+		}).Error("外部数据源响应异常")
+		return nil, fmt.Errorf("外部数据源响应状态码异常: %d", resp.StatusCode)
+	}
+
+	var feedResponse httpFeedDrawResponse
+	err = json.NewDecoder(resp.Body).Decode(&feedResponse)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"feedURL": feedURL.String(),
+			"err":     err,
+		}).Error("外部数据源响应解析异常")
+		return nil, err
+	}
+
+	if len(feedResponse.Values) != numDice {
+		return nil, fmt.Errorf("外部数据源返回点数个数异常: %d", len(feedResponse.Values))
+	}
+	for _, value := range feedResponse.Values {
+		if value < 1 || value > 6 {
+			return nil, fmt.Errorf("外部数据源返回点数异常: %d", value)
+		}
+	}
+
+	return &DrawResult{Values: feedResponse.Values}, nil
+}
+
+func (s *httpFeedDrawSource) Announce(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, result *DrawResult) error {
+	return announceDrawValues(bot, group, issueNumber, enums.HttpFeedDrawSource, result)
+}
+
+// provablyFairDrawSource 可验证公平开奖
+// 期号开始时公布服务端种子的SHA-256承诺哈希,开奖时公布服务端种子,
+// 点数由服务端种子、期号和本期下注收集的客户端种子确定性推导,可通过 /verify 复算。
+type provablyFairDrawSource struct{}
+
+// IssueStart 公布承诺哈希并记录消息ID 重启后恢复的期号已公布过则不再重复发送
+func (s *provablyFairDrawSource) IssueStart(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string) error {
+	seed, err := queryOrCreateProvablyFairSeed(group, issueNumber)
+	if err != nil {
+		return err
+	}
+	if seed.CommitMessageId != 0 {
+		return nil
+	}
+	if seed.RevealTime != "" {
+		// 已开奖的期号不能再补发承诺
+		return nil
+	}
+
+	sendMsg := tgbotapi.NewMessage(group.TgChatGroupId, fmt.Sprintf("第%s期[可验证公平]承诺哈希:\nSHA-256(服务端种子)=%s\n下注时可附带客户端种子,例子: #单 20 lucky\n开奖后发送 /verify %s 验证", issueNumber, seed.ServerSeedHash, issueNumber))
+	sentMsg, err := sendMessage(bot, &sendMsg)
+	if err != nil {
+		return err
+	}
+
+	seed.CommitMessageId = sentMsg.MessageID
+	err = seed.UpdateCommitMessageIdById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"seedId": seed.Id,
+			"err":    err,
+		}).Error("保存承诺哈希消息ID异常")
+		return err
+	}
+	return nil
+}
+
+func (s *provablyFairDrawSource) Draw(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, numDice int) (*DrawResult, error) {
+	seed, err := queryOrCreateProvablyFairSeed(group, issueNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按下注顺序收集本期的客户端种子
+	quickThereBetRecordQuery := &model.QuickThereBetRecord{
+		ChatGroupId: group.Id,
+		IssueNumber: issueNumber,
+	}
+	quickThereBetRecords, err := quickThereBetRecordQuery.ListByChatGroupIdAndIssueNumber(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": group.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("获取用户下注记录异常")
+		return nil, err
+	}
+	sort.Slice(quickThereBetRecords, func(i, j int) bool {
+		return quickThereBetRecords[i].CreateTime < quickThereBetRecords[j].CreateTime ||
+			(quickThereBetRecords[i].CreateTime == quickThereBetRecords[j].CreateTime && quickThereBetRecords[i].Id < quickThereBetRecords[j].Id)
+	})
+	var clientSeeds []string
+	for _, betRecord := range quickThereBetRecords {
+		if betRecord.ClientSeed != "" {
+			clientSeeds = append(clientSeeds, betRecord.ClientSeed)
+		}
+	}
+
+	seed.ClientSeed = strings.Join(clientSeeds, ":")
+	seed.RevealTime = time.Now().Format("2006-01-02 15:04:05")
+	err = seed.UpdateRevealById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"seedId": seed.Id,
+			"err":    err,
+		}).Error("保存可验证公平种子异常")
+		return nil, err
+	}
+
+	diceValues := utils.DeriveDiceValues(seed.ServerSeed, issueNumber, seed.ClientSeed, numDice)
+
+	return &DrawResult{Values: diceValues}, nil
+}
+
+// Announce 公布点数后公布本期服务端种子及客户端种子
+func (s *provablyFairDrawSource) Announce(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, result *DrawResult) error {
+	err := announceDrawValues(bot, group, issueNumber, enums.ProvablyFairDrawSource, result)
+	if err != nil {
+		return err
+	}
+
+	seedQuery := &model.ProvablyFairSeed{
+		ChatGroupId: group.Id,
+		IssueNumber: issueNumber,
+	}
+	seed, err := seedQuery.QueryByChatGroupIdAndIssueNumber(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": group.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("查询可验证公平种子异常")
+		return nil
+	}
+
+	revealText := fmt.Sprintf("第%s期服务端种子: %s\n客户端种子: %s\n发送 /verify %s 验证", issueNumber, seed.ServerSeed, seed.ClientSeed, issueNumber)
+	if seed.CommitMessageId == 0 {
+		revealText = fmt.Sprintf("第%s期开奖前未公布承诺哈希,本期结果不可验证", issueNumber)
+	}
+	sendMsg := tgbotapi.NewMessage(group.TgChatGroupId, revealText)
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, group.TgChatGroupId)
+	return nil
+}
+
+// queryOrCreateProvablyFairSeed 查询该期的服务端种子 不存在则生成
+// 开奖时才生成的种子未公布承诺哈希(CommitMessageId为0),/verify 将该期视为不可验证
+func queryOrCreateProvablyFairSeed(group *model.ChatGroup, issueNumber string) (*model.ProvablyFairSeed, error) {
+	seedQuery := &model.ProvablyFairSeed{
+		ChatGroupId: group.Id,
+		IssueNumber: issueNumber,
+	}
+	seed, err := seedQuery.QueryByChatGroupIdAndIssueNumber(db)
+	if err == nil {
+		return seed, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": group.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("查询可验证公平种子异常")
+		return nil, err
+	}
+
+	serverSeed, err := utils.NewServerSeed()
+	if err != nil {
+		logrus.WithField("err", err).Error("生成服务端种子异常")
+		return nil, err
+	}
+
+	seed = &model.ProvablyFairSeed{
+		ChatGroupId:    group.Id,
+		IssueNumber:    issueNumber,
+		ServerSeed:     serverSeed,
+		ServerSeedHash: utils.HashServerSeed(serverSeed),
+		CreateTime:     time.Now().Format("2006-01-02 15:04:05"),
+	}
+	err = seed.Create(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": group.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("保存可验证公平种子异常")
+		return nil, err
+	}
+	return seed, nil
+}
+
+// announceDrawValues 在群内以文本公布非Telegram骰子来源的开奖点数 并记录公布消息ID
+func announceDrawValues(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string, drawSourceType enums.DrawSourceType, result *DrawResult) error {
+	values := make([]string, len(result.Values))
+	for i, value := range result.Values {
+		values[i] = fmt.Sprintf("🎲%d", value)
+	}
+
+	sendMsg := tgbotapi.NewMessage(group.TgChatGroupId, fmt.Sprintf("第%s期开奖点数[%s]:\n%s", issueNumber, drawSourceType.Name, strings.Join(values, " ")))
+	sentMsg, err := sendMessage(bot, &sendMsg)
+	if err != nil {
+		return err
+	}
+	result.MessageIds = []int{sentMsg.MessageID}
+	return nil
+}
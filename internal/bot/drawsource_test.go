@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"telegram-dice-bot/internal/model"
+	"testing"
+	"time"
+)
+
+func TestHttpFeedDrawSourceDraw(t *testing.T) {
+	group := &model.ChatGroup{Id: "1001"}
+
+	t.Run("正常响应", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if query.Get("chatGroupId") != group.Id || query.Get("issueNumber") != "20240101120000" || query.Get("numDice") != "3" {
+				t.Errorf("请求参数异常: %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"values":[1,5,6]}`))
+		}))
+		defer server.Close()
+
+		source := &httpFeedDrawSource{feedURL: server.URL, client: server.Client()}
+		result, err := source.Draw(nil, group, "20240101120000", 3)
+		if err != nil {
+			t.Fatalf("Draw 返回错误: %v", err)
+		}
+		if !reflect.DeepEqual(result.Values, []int{1, 5, 6}) {
+			t.Errorf("点数 = %v, 期望 [1 5 6]", result.Values)
+		}
+		if len(result.MessageIds) != 0 {
+			t.Errorf("Draw 不应公布点数, MessageIds = %v", result.MessageIds)
+		}
+	})
+
+	t.Run("响应格式异常", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"非JSON":  `values=1,2,3`,
+			"点数个数不符": `{"values":[1,2]}`,
+			"点数越界":   `{"values":[1,2,7]}`,
+		} {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(body))
+			}))
+			source := &httpFeedDrawSource{feedURL: server.URL, client: server.Client()}
+			if _, err := source.Draw(nil, group, "20240101120000", 3); err == nil {
+				t.Errorf("%s: 期望返回错误", name)
+			}
+			server.Close()
+		}
+	})
+
+	t.Run("请求超时", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		client := server.Client()
+		client.Timeout = 50 * time.Millisecond
+		source := &httpFeedDrawSource{feedURL: server.URL, client: client}
+		if _, err := source.Draw(nil, group, "20240101120000", 3); err == nil {
+			t.Error("期望返回超时错误")
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+// handleVerifyCommand 验证某期开奖结果 /verify [期号]
+func handleVerifyCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	messageId := message.MessageID
+
+	issueNumber := strings.TrimSpace(message.CommandArguments())
+	if issueNumber == "" {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "请输入期号,例子: /verify 20240101120000")
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	lotteryRecordQuery := &model.QuickThereLotteryRecord{
+		ChatGroupId: chatGroup.Id,
+		IssueNumber: issueNumber,
+	}
+	lotteryRecord, err := lotteryRecordQuery.QueryByIssueNumberAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, fmt.Sprintf("未查询到第%s期开奖记录", issueNumber))
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("查询开奖记录异常")
+		return
+	}
+
+	recordValues := quickThereLotteryDiceValues(lotteryRecord)
+
+	var verifyText strings.Builder
+	verifyText.WriteString(fmt.Sprintf("第%s期验证结果:\n开奖点数: %s\n", issueNumber, utils.JoinInts(recordValues, " ")))
+
+	// 开奖消息链接
+	messageIds := utils.SplitInts(lotteryRecord.DrawMessageIds, ",")
+	if len(messageIds) == 0 {
+		verifyText.WriteString("开奖消息: 未记录\n")
+	} else {
+		drawTgChatGroupId := lotteryRecord.TgChatGroupId
+		if drawTgChatGroupId == 0 {
+			drawTgChatGroupId = chatGroup.TgChatGroupId
+		}
+		verifyText.WriteString("开奖消息:\n")
+		for _, drawMessageId := range messageIds {
+			verifyText.WriteString(buildMessageLink(drawTgChatGroupId, message.Chat, drawMessageId) + "\n")
+		}
+	}
+
+	seedQuery := &model.ProvablyFairSeed{
+		ChatGroupId: chatGroup.Id,
+		IssueNumber: issueNumber,
+	}
+	seed, err := seedQuery.QueryByChatGroupIdAndIssueNumber(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && seed.RevealTime == "") {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, verifyText.String())
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("查询可验证公平种子异常")
+		return
+	}
+
+	if seed.CommitMessageId == 0 {
+		// 重启或中途切换开奖来源导致开奖前未公布承诺哈希 无法证明服务端种子未被更换
+		verifyText.WriteString("\n[可验证公平]\n⚠️本期开奖前未公布承诺哈希,结果不可验证")
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, verifyText.String())
+		return
+	}
+
+	hashMatched := utils.HashServerSeed(seed.ServerSeed) == seed.ServerSeedHash
+	derivedValues := utils.DeriveDiceValues(seed.ServerSeed, issueNumber, seed.ClientSeed, len(recordValues))
+	valuesMatched := utils.JoinInts(derivedValues, " ") == utils.JoinInts(recordValues, " ")
+
+	drawTgChatGroupId := lotteryRecord.TgChatGroupId
+	if drawTgChatGroupId == 0 {
+		drawTgChatGroupId = chatGroup.TgChatGroupId
+	}
+	verifyText.WriteString(fmt.Sprintf("\n[可验证公平]\n"+
+		"承诺消息: %s\n"+
+		"承诺哈希: %s\n"+
+		"服务端种子: %s\n"+
+		"客户端种子: %s\n"+
+		"哈希校验: %s\n"+
+		"推导点数: %s\n"+
+		"点数校验: %s",
+		buildMessageLink(drawTgChatGroupId, message.Chat, seed.CommitMessageId),
+		seed.ServerSeedHash,
+		seed.ServerSeed,
+		seed.ClientSeed,
+		verifyResultMark(hashMatched),
+		utils.JoinInts(derivedValues, " "),
+		verifyResultMark(valuesMatched)))
+
+	replyAutoDeleteMessage(bot, tgChatGroupId, messageId, verifyText.String())
+}
+
+func replyAutoDeleteMessage(bot *tgbotapi.BotAPI, tgChatGroupId int64, messageId int, text string) {
+	msgConfig := tgbotapi.NewMessage(tgChatGroupId, text)
+	msgConfig.ReplyToMessageID = messageId
+	msgConfig.DisableWebPagePreview = true
+	sentMsg, err := sendMessage(bot, &msgConfig)
+	if err != nil {
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	}
+	go func(messageID int) {
+		time.Sleep(1 * time.Minute)
+		deleteMsg := tgbotapi.NewDeleteMessage(tgChatGroupId, messageID)
+		_, err := bot.Request(deleteMsg)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"err": err,
+			}).Error("删除消息异常")
+		}
+	}(sentMsg.MessageID)
+}
+
+func verifyResultMark(matched bool) string {
+	if matched {
+		return "✅通过"
+	}
+	return "❌不一致"
+}
+
+// buildMessageLink 构建群消息链接
+// 公开群使用 t.me/用户名/消息ID,超级群使用 t.me/c/群ID(去掉-100前缀)/消息ID,普通群不支持消息链接
+func buildMessageLink(tgChatGroupId int64, chat *tgbotapi.Chat, messageId int) string {
+	if chat != nil && chat.ID == tgChatGroupId && chat.UserName != "" {
+		return fmt.Sprintf("https://t.me/%s/%d", chat.UserName, messageId)
+	}
+	chatIdStr := strconv.FormatInt(tgChatGroupId, 10)
+	if strings.HasPrefix(chatIdStr, "-100") {
+		return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(chatIdStr, "-100"), messageId)
+	}
+	return fmt.Sprintf("消息ID: %d", messageId)
+}
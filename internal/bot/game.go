@@ -28,6 +28,7 @@ func gameStart(bot *tgbotapi.BotAPI, group *model.ChatGroup) {
 	issueNumberResult := redisDB.Get(redisDB.Context(), redisKey)
 	if errors.Is(issueNumberResult.Err(), redis.Nil) || issueNumberResult == nil {
 		lotteryDrawTipMsgConfig := tgbotapi.NewMessage(group.TgChatGroupId, fmt.Sprintf("第%s期 %d分钟后开奖", issueNumber, group.GameDrawCycle))
+		lotteryDrawTipMsgConfig.ReplyMarkup = buildBetChipInlineKeyboardMarkup(issueNumber)
 		_, err := sendMessage(bot, &lotteryDrawTipMsgConfig)
 		if err != nil {
 			blockedOrKicked(err, group.TgChatGroupId)
@@ -46,6 +47,7 @@ func gameStart(bot *tgbotapi.BotAPI, group *model.ChatGroup) {
 		result, _ := issueNumberResult.Result()
 		issueNumber = result
 		lotteryDrawTipMsgConfig := tgbotapi.NewMessage(group.TgChatGroupId, fmt.Sprintf("第%s期 %d分钟后开奖", issueNumber, group.GameDrawCycle))
+		lotteryDrawTipMsgConfig.ReplyMarkup = buildBetChipInlineKeyboardMarkup(issueNumber)
 		_, err := sendMessage(bot, &lotteryDrawTipMsgConfig)
 		if err != nil {
 			blockedOrKicked(err, group.TgChatGroupId)
@@ -53,8 +55,17 @@ func gameStart(bot *tgbotapi.BotAPI, group *model.ChatGroup) {
 		}
 	}
 
+	issueStart(bot, group, issueNumber)
+
 	gameTaskStart(bot, group, issueNumber)
 }
+
+// issueStart 期号开始处理 保存本期快三规则快照,并通知开奖来源(如可验证公平模式公布承诺哈希)
+func issueStart(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string) {
+	snapshotQuickThereIssueRule(group, issueNumber)
+	drawSourceIssueStart(bot, group, issueNumber)
+}
+
 func gameStop(group *model.ChatGroup) {
 	gameTaskStop(group)
 }
@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
-	"strconv"
 	"strings"
 	"telegram-dice-bot/internal/enums"
 	"telegram-dice-bot/internal/model"
@@ -43,8 +43,30 @@ func handleGroupCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 		handleMyCommand(bot, message)
 	case "myhistory":
 		handleMyHistoryCommand(bot, message)
+	case "ledger":
+		handleLedgerCommand(bot, message)
+	case "signlog":
+		handleSignLogCommand(bot, message)
+	case "relief":
+		handleReliefCommand(bot, message)
+	case "invite":
+		handleInviteCommand(bot, message)
+	case "hongbao":
+		handleHongbaoCommand(bot, message)
+	case "give":
+		handleGiveCommand(bot, message)
+	case "rank":
+		handleRankCommand(bot, message)
+	case "profile":
+		handleProfileCommand(bot, message)
 	case "help":
 		handleHelpCommand(bot, message)
+	case "liarsdice":
+		handleLiarsDiceCommand(bot, message)
+	case "verify":
+		handleVerifyCommand(bot, message)
+	case "trend":
+		handleTrendCommand(bot, message)
 	}
 }
 
@@ -79,7 +101,7 @@ func handleHelpCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 			}).Error("群的快三配置异常")
 			return
 		}
-		gameHelp = fmt.Sprintf("当前倍率:\n简易%v倍丨豹子%v倍\n\n支持竞猜类型: 单、双、大、小、豹子\n竞猜示例(竞猜类型-单,下注积分-20):\n #单 20", quickThereConfig.SimpleOdds, quickThereConfig.TripletOdds)
+		gameHelp = fmt.Sprintf("骰子数量: %d颗丨总点数小于等于%d为小\n当前倍率:\n简易%s倍丨豹子%s倍\n\n支持竞猜类型: 单、双、大、小、豹子(所有骰子点数相同)\n竞猜示例(竞猜类型-单,下注积分-20):\n #单 20", quickThereConfig.DiceCount, quickThereConfig.SmallMaxTotal, utils.FormatAmount(quickThereConfig.SimpleOdds), utils.FormatAmount(quickThereConfig.TripletOdds))
 	}
 
 	gameplayType, b := enums.GetGameplayType(chatGroup.GameplayType)
@@ -96,8 +118,19 @@ func handleHelpCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 		fmt.Sprintf("/help 帮助\n"+
 			"/register 用户注册\n"+
 			"/sign 用户签到\n"+
+			"/signlog 查询签到记录\n"+
+			"/relief 领取救济金\n"+
+			"/invite 获取专属邀请链接\n"+
+			"/hongbao [总积分] [个数] 发拼手气红包(末尾加「均」为普通红包)\n"+
+			"/give [积分] 回复对方消息转让积分,或 /give @用户名 [积分]\n"+
 			"/my 查询积分\n"+
-			"/myhistory 查询历史下注记录\n\n"+
+			"/profile 个人统计\n"+
+			"/myhistory [开始日期] [结束日期] [类型] [赢/输/未开奖] 查询下注记录\n"+
+			"/ledger 查询积分流水\n"+
+			"/rank 排行榜\n"+
+			"/liarsdice [入场积分] 开设吹牛骰子牌桌\n"+
+			"/verify [期号] 验证开奖结果\n"+
+			"/trend [期数] 查看走势图\n\n"+
 			"当前游戏类型【%s】\n"+
 			"开奖周期 %v 分钟\n"+
 			"%s",
@@ -122,122 +155,6 @@ func handleHelpCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	}(sentMsg.MessageID)
 }
 
-func handleMyHistoryCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	fromUser := message.From
-	messageId := message.MessageID
-	tgChatId := message.Chat.ID
-
-	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatId)
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		logrus.WithFields(logrus.Fields{
-			"tgChatId": tgChatId,
-		}).Warn("未查询到群配置 [未初始化]")
-	} else if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"tgChatId": tgChatId,
-			"err":      err,
-		}).Error("群配置查询异常")
-		return
-	}
-
-	chatGroupUserQuery := &model.ChatGroupUser{
-		// 查询用户信息
-		TgUserId:    fromUser.ID,
-		ChatGroupId: chatGroup.Id,
-	}
-
-	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		// 没有找到记录
-		msgConfig := tgbotapi.NewMessage(tgChatId, "您还未注册，使用 /register 进行注册。")
-		msgConfig.ReplyToMessageID = messageId
-		_, err := sendMessage(bot, &msgConfig)
-		blockedOrKicked(err, tgChatId)
-		return
-	} else if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"tgChatId": tgChatId,
-			"err":      err,
-		}).Error("群配置查询异常")
-		return
-	}
-	// 查询下注记录
-
-	betRecord := &model.BetRecord{ChatGroupUserId: chatGroupUser.Id}
-	betRecords, err := betRecord.ListByChatGroupUserId(db)
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"chatGroupUserId": chatGroupUser.Id,
-			"err":             err,
-		}).Error("查询下注记录异常")
-		return
-	}
-	sendMsg := tgbotapi.NewMessage(tgChatId, "")
-	sendMsg.ReplyToMessageID = messageId
-
-	if len(betRecords) == 0 {
-		// 下注记录为空
-		sendMsg.Text = "您还没有下注记录哦!"
-	} else {
-		sendMsg.Text = "您的近10期下注记录如下:\n"
-
-		for _, record := range betRecords {
-			// 开奖类型查询开奖信息
-			switch record.GameplayType {
-			case enums.QuickThere.Value:
-
-				quickThereBetRecord := &model.QuickThereBetRecord{
-					Id: record.Id,
-				}
-				quickThereBetRecord, err := quickThereBetRecord.QueryById(db)
-				if err != nil {
-					logrus.WithFields(logrus.Fields{
-						"recordId": record.Id,
-						"err":      err,
-					}).Error("查询快三下注记录异常")
-					return
-				}
-
-				betType, _ := enums.GetGameLotteryType(quickThereBetRecord.BetType)
-
-				betResultTypeName := "「未开奖」"
-
-				if quickThereBetRecord.BetResultType != nil {
-					betType, _ := enums.GetBetResultType(*quickThereBetRecord.BetResultType)
-					betResultTypeName = betType.Name
-				}
-
-				sendMsg.Text += fmt.Sprintf("%s期 %s %s %v %s %v \n",
-					record.IssueNumber,
-					"快三",
-					betType.Name,
-					quickThereBetRecord.BetAmount,
-					betResultTypeName,
-					quickThereBetRecord.BetResultAmount,
-				)
-			}
-		}
-
-		sentMsg, err := sendMessage(bot, &sendMsg)
-		if err != nil {
-			blockedOrKicked(err, tgChatId)
-			return
-		}
-		go func(messageID int) {
-			time.Sleep(1 * time.Minute)
-			deleteMsg := tgbotapi.NewDeleteMessage(tgChatId, messageID)
-			_, err := bot.Request(deleteMsg)
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"err": err,
-				}).Error("删除消息异常")
-			}
-		}(sentMsg.MessageID)
-
-		return
-	}
-}
-
 func handleGroupNewMembers(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	// 检查是否有新成员加入
 	if message != nil && message.NewChatMembers != nil {
@@ -273,6 +190,7 @@ func handleGroupNewMembers(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 						GameDrawCycle:    1,
 						GameplayStatus:   0,
 						ChatGroupStatus:  enums.GroupNormal.Value,
+						DrawSourceType:   enums.TelegramDiceDrawSource.Value,
 						CreateTime:       time.Now().Format("2006-01-02 15:04:05"),
 					}
 					err = chatGroup.Create(tx)
@@ -286,10 +204,12 @@ func handleGroupNewMembers(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 
 					// 初始化快三配置
 					quickThereConfig := &model.QuickThereConfig{
-						ChatGroupId: chatGroupId,
-						SimpleOdds:  2,
-						TripletOdds: 10,
-						CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+						ChatGroupId:   chatGroupId,
+						SimpleOdds:    decimal.NewFromInt(2),
+						TripletOdds:   decimal.NewFromInt(10),
+						DiceCount:     3,
+						SmallMaxTotal: defaultSmallMaxTotal(3),
+						CreateTime:    time.Now().Format("2006-01-02 15:04:05"),
 					}
 
 					err = quickThereConfig.Create(tx)
@@ -301,6 +221,16 @@ func handleGroupNewMembers(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 						return
 					}
 
+					// 初始化签到配置
+					err = newDefaultSignInConfig(chatGroupId).Create(tx)
+					if err != nil {
+						logrus.WithFields(logrus.Fields{
+							"err": err,
+						}).Error("初始化签到配置异常")
+						tx.Rollback()
+						return
+					}
+
 					// 提交事务
 					if err := tx.Commit().Error; err != nil {
 						// 提交事务时出现异常，回滚事务
@@ -532,7 +462,7 @@ func handleBettingText(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 
 	if chatGroup.GameplayType == enums.QuickThere.Value {
 		b, err := handleQuickThereBettingText(bot, chatGroup, message)
-		if b {
+		if b && chatGroup.BetReplyStatus == enums.GameplayStatusON.Value {
 			// 回复下注成功信息
 			replyMsg := tgbotapi.NewMessage(tgChatGroupId, "下注成功!")
 			replyMsg.ReplyToMessageID = messageId
@@ -556,9 +486,9 @@ func handleQuickThereBettingText(bot *tgbotapi.BotAPI, chatGroup *model.ChatGrou
 	tgChatGroupId := message.Chat.ID
 	messageId := message.MessageID
 
-	// 解析下注命令，示例命令格式：#单 20
+	// 解析下注命令，示例命令格式：#单 20 可附带客户端种子：#单 20 lucky
 	parts := strings.Fields(text)
-	if len(parts) != 2 || !strings.HasPrefix(parts[0], "#") {
+	if (len(parts) != 2 && len(parts) != 3) || !strings.HasPrefix(parts[0], "#") {
 		return false, nil
 	}
 
@@ -568,11 +498,20 @@ func handleQuickThereBettingText(bot *tgbotapi.BotAPI, chatGroup *model.ChatGrou
 		return false, nil
 	}
 
-	betAmount, err := strconv.ParseFloat(parts[1], 64)
-	if err != nil || betAmount <= 0 {
+	betAmount, err := utils.ParseAmount(parts[1])
+	if err != nil {
 		return false, errors.New("下注积分异常")
 	}
 
+	// 可验证公平开奖使用的客户端种子
+	clientSeed := ""
+	if len(parts) == 3 {
+		clientSeed = parts[2]
+		if len(clientSeed) > 64 {
+			return false, errors.New("客户端种子过长")
+		}
+	}
+
 	if chatGroup.GameplayStatus == enums.GameplayStatusOFF.Value {
 		registrationMsg := tgbotapi.NewMessage(tgChatGroupId, "功能未开启！")
 		registrationMsg.ReplyToMessageID = messageId
@@ -615,6 +554,7 @@ func handleQuickThereBettingText(bot *tgbotapi.BotAPI, chatGroup *model.ChatGrou
 		IssueNumber: issueNumber,
 		BetType:     betType,
 		BetAmount:   betAmount,
+		ClientSeed:  clientSeed,
 	})
 
 	if !b && err != nil {
@@ -623,16 +563,42 @@ func handleQuickThereBettingText(bot *tgbotapi.BotAPI, chatGroup *model.ChatGrou
 		}).Error("保存下注记录异常")
 		return false, err
 	}
+	if b {
+		refreshBetBoard(bot, chatGroup, issueNumber)
+	}
 	return b, nil
 }
 
+// storeQuickThereBetRecord 文字下注 未注册、余额不足等提示回复到群内
 func storeQuickThereBetRecord(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, message *tgbotapi.Message, quickThereBetRecord *model.QuickThereBetRecord) (bool, error) {
-	user := message.From
-	messageId := message.MessageID
 	chatId := message.Chat.ID
 
+	tipMsg, err := placeQuickThereBet(chatGroup, message.From, quickThereBetRecord)
+	if err != nil {
+		return false, err
+	}
+	if tipMsg != "" {
+		replyMsg := tgbotapi.NewMessage(chatId, tipMsg)
+		replyMsg.ReplyToMessageID = message.MessageID
+		_, sendErr := bot.Send(replyMsg)
+		if sendErr != nil {
+			logrus.WithFields(logrus.Fields{
+				"tipMsg": tipMsg,
+				"err":    sendErr,
+			}).Error("发送下注提示消息异常")
+			blockedOrKicked(sendErr, chatId)
+			return false, sendErr
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// placeQuickThereBet 校验用户及余额后保存快三下注记录并扣除余额
+// 用户未注册或余额不足时返回提示文案,由调用方决定回复到群内或以回调提示展示
+func placeQuickThereBet(chatGroup *model.ChatGroup, user *tgbotapi.User, quickThereBetRecord *model.QuickThereBetRecord) (string, error) {
 	// 获取用户对应的互斥锁
-	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, message.Chat.ID, user.ID)
+	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, chatGroup.TgChatGroupId, user.ID)
 	userLock := getUserLock(userLockKey)
 	userLock.Lock()
 	defer userLock.Unlock()
@@ -647,45 +613,26 @@ func storeQuickThereBetRecord(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup,
 
 	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(tx)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		// 用户不存在，发送注册提示
-		registrationMsg := tgbotapi.NewMessage(chatId, "您还未注册，使用 /register 进行注册。")
-		registrationMsg.ReplyToMessageID = messageId
-		_, sendErr := bot.Send(registrationMsg)
-		if sendErr != nil {
-			logrus.WithFields(logrus.Fields{
-				"err": err,
-			}).Error("发送注册提示消息异常")
-			blockedOrKicked(sendErr, chatId)
-			return false, sendErr
-		}
-		return false, nil
+		tx.Rollback()
+		return "您还未注册，使用 /register 进行注册。", nil
 	} else if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"TgUserId":    chatGroupUserQuery.TgUserId,
 			"ChatGroupId": chatGroupUserQuery.ChatGroupId,
 			"err":         err,
 		}).Error("查询用户信息异常")
-		return false, err
+		tx.Rollback()
+		return "", err
 	} else {
 		// 检查用户余额是否足够
-		if chatGroupUser.Balance < quickThereBetRecord.BetAmount {
-			// 用户不存在，发送注册提示
-			balanceInsufficientMsg := tgbotapi.NewMessage(chatId, "您的余额不足!")
-			balanceInsufficientMsg.ReplyToMessageID = messageId
-			_, err := bot.Send(balanceInsufficientMsg)
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"err": err,
-				}).Error("您的余额不足提示异常")
-				blockedOrKicked(err, chatId)
-				return false, err
-			} else {
-				return false, nil
-			}
+		if chatGroupUser.Balance.LessThan(quickThereBetRecord.BetAmount) {
+			tx.Rollback()
+			return "您的余额不足!", nil
 		}
 
 		// 扣除用户余额
-		chatGroupUser.Balance -= quickThereBetRecord.BetAmount
+		balanceBefore := chatGroupUser.Balance
+		chatGroupUser.Balance = chatGroupUser.Balance.Sub(quickThereBetRecord.BetAmount)
 		// 同步更新用户信息
 		chatGroupUser.Username = user.UserName
 
@@ -695,7 +642,7 @@ func storeQuickThereBetRecord(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup,
 				"err": err,
 			}).Error("扣除用户余额异常")
 			tx.Rollback()
-			return false, result.Error
+			return "", result.Error
 		}
 		currentTime := time.Now().Format("2006-01-02 15:04:05")
 
@@ -706,13 +653,15 @@ func storeQuickThereBetRecord(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup,
 				"betType": quickThereBetRecord.BetType,
 				"err":     err,
 			}).Error("下注类型映射异常")
-			return false, errors.New("该下注类型映射异常")
+			tx.Rollback()
+			return "", errors.New("该下注类型映射异常")
 		}
 
 		id, err := utils.NextID()
 		if err != nil {
 			logrus.Error("SnowFlakeId create error")
-			return false, err
+			tx.Rollback()
+			return "", err
 		}
 
 		// 保存下注记录
@@ -735,7 +684,7 @@ func storeQuickThereBetRecord(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup,
 			//chatGroupUser.Balance += quickThereBetRecord.BetAmount
 			//tx.Save(&user)
 			tx.Rollback()
-			return false, err
+			return "", err
 		}
 
 		// 保存快三下注记录
@@ -746,6 +695,7 @@ func storeQuickThereBetRecord(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup,
 			IssueNumber:     quickThereBetRecord.IssueNumber,
 			BetType:         betType.Value,
 			BetAmount:       quickThereBetRecord.BetAmount,
+			ClientSeed:      quickThereBetRecord.ClientSeed,
 			SettleStatus:    enums.Unsettled.Value,
 			UpdateTime:      currentTime,
 			CreateTime:      currentTime,
@@ -760,16 +710,28 @@ func storeQuickThereBetRecord(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup,
 			//chatGroupUser.Balance += quickThereBetRecord.BetAmount
 			//tx.Save(&user)
 			tx.Rollback()
-			return false, result.Error
+			return "", result.Error
+		}
+
+		// 记录积分流水
+		err = addBalanceLedger(tx, chatGroupUser, balanceBefore, enums.BetLedger, &model.BalanceLedger{
+			RefId:       id,
+			IssueNumber: quickThereBetRecord.IssueNumber,
+		})
+		if err != nil {
+			tx.Rollback()
+			return "", err
 		}
 
 		// 提交事务
 		if err := tx.Commit().Error; err != nil {
 			// 提交事务时出现异常，回滚事务
+			logrus.WithField("err", err).Error("下注事务提交异常")
 			tx.Rollback()
+			return "", err
 		}
 
-		return true, nil
+		return "", nil
 	}
 }
 
@@ -811,7 +773,7 @@ func handleMyCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 			"err":         err,
 		}).Error("群用户查询异常")
 	} else {
-		msgConfig := tgbotapi.NewMessage(tgChatGroupId, fmt.Sprintf("%s 您的积分余额为%.2f", fromUser.FirstName, chatGroupUser.Balance))
+		msgConfig := tgbotapi.NewMessage(tgChatGroupId, fmt.Sprintf("%s 您的积分余额为%s", fromUser.FirstName, utils.FormatAmount(chatGroupUser.Balance)))
 		msgConfig.ReplyToMessageID = messageId
 		sentMsg, err := sendMessage(bot, &msgConfig)
 		if err != nil {
@@ -853,7 +815,7 @@ func handleSignCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 		ChatGroupId: chatGroup.Id,
 	}
 
-	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	_, err = chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		// 没有找到记录
@@ -869,48 +831,147 @@ func handleSignCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 			"err":         err,
 		}).Error("群用户查询异常")
 	} else {
-		// 获取用户对应的互斥锁
-		userLockKey := fmt.Sprintf(ChatGroupUserLockKey, message.Chat.ID, message.From.ID)
-		userLock := getUserLock(userLockKey)
-		userLock.Lock()
-		defer userLock.Unlock()
-
-		if chatGroupUser.SignInTime != "" {
-			signInTime, err := time.Parse("2006-01-02 15:04:05", chatGroupUser.SignInTime)
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"SignInTime": chatGroupUser.SignInTime,
-					"err":        err,
-				}).Error("时间解析异常")
-				return
-			}
-			// 获取当前时间
-			currentTime := time.Now()
-			currentMidnight := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), 0, 0, 0, 0, currentTime.Location())
-			if !signInTime.Before(currentMidnight) {
-				msgConfig := tgbotapi.NewMessage(tgChatGroupId, "今天已签到过了哦！")
-				msgConfig.ReplyToMessageID = messageId
-				_, err := sendMessage(bot, &msgConfig)
-				blockedOrKicked(err, tgChatGroupId)
-				return
-			}
-		}
-		chatGroupUser.SignInTime = time.Now().Format("2006-01-02 15:04:05")
-		chatGroupUser.Balance += 1000
-		result := db.Save(&chatGroupUser)
-		if result.Error != nil {
-			logrus.WithFields(logrus.Fields{
-				"err": err,
-			}).Error("保存用户信息异常")
+		replyText, waitDice := signInChatGroupUser(bot, message, chatGroup, chatGroupUserQuery)
+		if replyText == "" {
 			return
 		}
-		msgConfig := tgbotapi.NewMessage(tgChatGroupId, "签到成功！奖励1000积分！")
+		if waitDice {
+			// 等待骰子动画结束后公布结果 签到已落库,等待期间不占用用户锁
+			time.Sleep(3 * time.Second)
+		}
+		msgConfig := tgbotapi.NewMessage(tgChatGroupId, replyText)
 		msgConfig.ReplyToMessageID = messageId
-		_, err := sendMessage(bot, &msgConfig)
+		_, err = sendMessage(bot, &msgConfig)
 		blockedOrKicked(err, tgChatGroupId)
 	}
 }
 
+// signInChatGroupUser 在用户锁内完成签到并提交事务 返回回复文本及是否需等待骰子动画,回复文本为空表示无需回复
+func signInChatGroupUser(bot *tgbotapi.BotAPI, message *tgbotapi.Message, chatGroup *model.ChatGroup, chatGroupUserQuery *model.ChatGroupUser) (string, bool) {
+	tgChatGroupId := message.Chat.ID
+	fromUser := message.From
+
+	// 获取用户对应的互斥锁
+	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, tgChatGroupId, fromUser.ID)
+	userLock := getUserLock(userLockKey)
+	userLock.Lock()
+	defer userLock.Unlock()
+
+	// 加锁后重新查询 避免并发重复签到
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    fromUser.ID,
+			"ChatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("群用户查询异常")
+		return "", false
+	}
+
+	signInConfig, err := queryOrCreateSignInConfig(chatGroup.Id)
+	if err != nil {
+		return "", false
+	}
+	ladder, err := parseSignInRewardLadder(signInConfig.RewardLadder)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId":  chatGroup.Id,
+			"rewardLadder": signInConfig.RewardLadder,
+			"err":          err,
+		}).Error("签到奖励阶梯解析异常")
+		return "", false
+	}
+
+	now := time.Now()
+	if signedInToday(chatGroupUser, now) {
+		return fmt.Sprintf("今天已签到过了哦！\n当前连续签到%d天,%s。",
+			chatGroupUser.SignInStreak,
+			buildTomorrowSignInRewardText(signInConfig, ladder, chatGroupUser.SignInStreak+1)), false
+	}
+
+	streak := nextSignInStreak(chatGroupUser, now)
+	signInRecord := &model.SignInRecord{
+		ChatGroupId:     chatGroup.Id,
+		ChatGroupUserId: chatGroupUser.Id,
+		TgUserId:        chatGroupUser.TgUserId,
+		SignInMode:      enums.FixedSignIn.Value,
+		Streak:          streak,
+		Reward:          signInReward(ladder, streak),
+		CreateTime:      now.Format("2006-01-02 15:04:05"),
+	}
+
+	// 掷骰签到 奖励由骰子结果决定
+	rollText := ""
+	waitDice := false
+	if diceEmoji, maxValue, ok := signInDiceEmoji(signInConfig.SignInMode); ok {
+		rewardTable, err := parseRollRewardTable(rollRewardTableOf(signInConfig), maxValue)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroup.Id,
+				"signInMode":  signInConfig.SignInMode,
+				"err":         err,
+			}).Error("掷骰签到奖励表解析异常")
+			return "", false
+		}
+		roll, sent, err := rollSignInDice(bot, message, chatGroup, diceEmoji, now)
+		if err != nil {
+			return "", false
+		}
+		waitDice = sent
+
+		signInRecord.SignInMode = signInConfig.SignInMode
+		signInRecord.DiceEmoji = roll.DiceEmoji
+		signInRecord.DiceValue = roll.DiceValue
+		signInRecord.TgChatGroupId = tgChatGroupId
+		signInRecord.DiceMessageId = roll.DiceMessageId
+		signInRecord.Reward = rollReward(rewardTable, roll.DiceValue)
+		rollText = fmt.Sprintf("结果%s,", formatDiceValue(roll.DiceEmoji, roll.DiceValue))
+	}
+	reward := signInRecord.Reward
+
+	tx := db.Begin()
+	balanceBefore := chatGroupUser.Balance
+	chatGroupUser.SignInTime = now.Format("2006-01-02 15:04:05")
+	chatGroupUser.SignInStreak = streak
+	chatGroupUser.Balance = chatGroupUser.Balance.Add(reward)
+	result := tx.Save(&chatGroupUser)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"err": result.Error,
+		}).Error("保存用户信息异常")
+		tx.Rollback()
+		return "", false
+	}
+	err = signInRecord.Create(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("保存签到记录异常")
+		tx.Rollback()
+		return "", false
+	}
+	err = addBalanceLedger(tx, chatGroupUser, balanceBefore, enums.SignInLedger, &model.BalanceLedger{RefId: signInRecord.Id})
+	if err != nil {
+		tx.Rollback()
+		return "", false
+	}
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("签到事务提交异常")
+		tx.Rollback()
+		return "", false
+	}
+	if signInRecord.DiceEmoji != "" {
+		clearSignInRoll(chatGroup, fromUser.ID, now)
+	}
+
+	return fmt.Sprintf("签到成功！%s奖励%s积分！连续签到%d天。\n%s,断签将从第1天重新计算。",
+		rollText,
+		utils.FormatAmount(reward),
+		streak,
+		buildTomorrowSignInRewardText(signInConfig, ladder, streak+1)), waitDice
+}
+
 func handleRegisterCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	tgChatGroupId := message.Chat.ID
 	fromUser := message.From
@@ -933,22 +994,36 @@ func handleRegisterCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 
 	_, err = chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
+		signInConfig, err := queryOrCreateSignInConfig(chatGroup.Id)
+		if err != nil {
+			return
+		}
+
 		// 没有找到记录 则注册
 		chatGroupUser := &model.ChatGroupUser{
-			TgUserId:    fromUser.ID,
-			ChatGroupId: chatGroup.Id,
-			Username:    fromUser.UserName,
-			IsLeft:      0,
-			Balance:     1000,
-			CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+			TgUserId:       fromUser.ID,
+			ChatGroupId:    chatGroup.Id,
+			Username:       fromUser.UserName,
+			IsLeft:         0,
+			Balance:        signInConfig.RegisterReward,
+			InitialBalance: decimal.NewNullDecimal(signInConfig.RegisterReward),
+			CreateTime:     time.Now().Format("2006-01-02 15:04:05"),
+		}
+		tx := db.Begin()
+		err = chatGroupUser.Create(tx)
+		if err == nil {
+			err = addBalanceLedger(tx, chatGroupUser, decimal.Zero, enums.RegisterLedger, nil)
+		}
+		if err == nil {
+			err = tx.Commit().Error
 		}
-		err := chatGroupUser.Create(db)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"err": err,
 			}).Error("创建用户信息异常")
+			tx.Rollback()
 		} else {
-			msgConfig := tgbotapi.NewMessage(tgChatGroupId, "注册成功！奖励1000积分！")
+			msgConfig := tgbotapi.NewMessage(tgChatGroupId, fmt.Sprintf("注册成功！奖励%s积分！", utils.FormatAmount(signInConfig.RegisterReward)))
 			msgConfig.ReplyToMessageID = messageId
 			_, err := sendMessage(bot, &msgConfig)
 			blockedOrKicked(err, tgChatGroupId)
@@ -0,0 +1,664 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	HongbaoMaxCount       = 100              // 单个红包最多拆分个数
+	HongbaoExpireDuration = 30 * time.Minute // 红包有效期 需小于内联键盘回调参数在redis中的有效期
+)
+
+var (
+	hongbaoTimers      = make(map[string]*time.Timer)
+	hongbaoTimersMutex sync.Mutex
+)
+
+// initHongbaoTask 重启后恢复未过期红包的过期退还任务
+func initHongbaoTask(bot *tgbotapi.BotAPI) {
+	hongbaos, err := model.ListHongbaoByStatus(db, enums.HongbaoActive.Value)
+	if err != nil {
+		logrus.WithField("err", err).Error("查询进行中的红包异常")
+		return
+	}
+
+	for _, hongbao := range hongbaos {
+		logrus.WithField("hongbaoId", hongbao.Id).Info("恢复红包过期任务")
+		scheduleHongbaoExpire(bot, hongbao)
+	}
+}
+
+// handleHongbaoCommand 发红包 示例: /hongbao 100 5 拼手气红包, /hongbao 100 5 均 普通红包
+func handleHongbaoCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	fromUser := message.From
+	messageId := message.MessageID
+
+	totalAmount, totalCount, splitMode, err := parseHongbaoArgs(message.CommandArguments())
+	if err != nil {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, fmt.Sprintf("%s\n例子: /hongbao 100 5 (拼手气红包)\n/hongbao 100 5 均 (普通红包)", err.Error()))
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	hongbaoId, err := utils.NextID()
+	if err != nil {
+		logrus.Error("SnowFlakeId create error")
+		return
+	}
+
+	currentTime := time.Now()
+	hongbao := &model.Hongbao{
+		Id:             hongbaoId,
+		ChatGroupId:    chatGroup.Id,
+		TgChatGroupId:  tgChatGroupId,
+		SenderTgUserId: fromUser.ID,
+		SenderName:     hongbaoUserName(fromUser),
+		SplitMode:      splitMode.Value,
+		TotalAmount:    totalAmount,
+		TotalCount:     totalCount,
+		RemainAmount:   totalAmount,
+		RemainCount:    totalCount,
+		Status:         enums.HongbaoActive.Value,
+		ExpireTime:     currentTime.Add(HongbaoExpireDuration).Format("2006-01-02 15:04:05"),
+		UpdateTime:     currentTime.Format("2006-01-02 15:04:05"),
+		CreateTime:     currentTime.Format("2006-01-02 15:04:05"),
+	}
+
+	tipMsg, err := createHongbao(chatGroup, fromUser, hongbao)
+	if err != nil {
+		return
+	} else if tipMsg != "" {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, tipMsg)
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(tgChatGroupId, buildHongbaoText(hongbao, nil))
+	inlineKeyboardMarkup, err := buildHongbaoInlineKeyboardMarkup(hongbao)
+	if err == nil {
+		sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	}
+	sentMsg, err := sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, tgChatGroupId)
+	} else {
+		hongbao.MessageId = sentMsg.MessageID
+		err = hongbao.UpdateMessageIdById(db)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"hongbaoId": hongbao.Id,
+				"messageId": hongbao.MessageId,
+				"err":       err,
+			}).Error("更新红包消息ID异常")
+		}
+	}
+
+	// 消息发送失败时红包无法领取 仍按期过期退还
+	scheduleHongbaoExpire(bot, hongbao)
+}
+
+// parseHongbaoArgs 解析红包参数 总积分 个数 [均]
+func parseHongbaoArgs(args string) (decimal.Decimal, int, enums.HongbaoSplitMode, error) {
+	parts := strings.Fields(args)
+	if len(parts) != 2 && len(parts) != 3 {
+		return decimal.Zero, 0, enums.HongbaoRandomSplit, errors.New("请输入红包总积分和个数")
+	}
+	totalAmount, err := utils.ParseAmount(parts[0])
+	if err != nil {
+		return decimal.Zero, 0, enums.HongbaoRandomSplit, errors.New("红包总积分不合法")
+	}
+	totalCount, err := strconv.Atoi(parts[1])
+	if err != nil || totalCount < 1 || totalCount > HongbaoMaxCount {
+		return decimal.Zero, 0, enums.HongbaoRandomSplit, fmt.Errorf("红包个数需为1-%d", HongbaoMaxCount)
+	}
+	// 每个红包至少0.01积分
+	if totalAmount.LessThan(decimal.New(int64(totalCount), -utils.AmountPlaces)) {
+		return decimal.Zero, 0, enums.HongbaoRandomSplit, errors.New("红包总积分不足,每个红包至少0.01积分")
+	}
+	splitMode := enums.HongbaoRandomSplit
+	if len(parts) == 3 {
+		if parts[2] != "均" {
+			return decimal.Zero, 0, enums.HongbaoRandomSplit, errors.New("普通红包请以「均」结尾")
+		}
+		splitMode = enums.HongbaoEqualSplit
+	}
+	return totalAmount, totalCount, splitMode, nil
+}
+
+// createHongbao 扣除发送人积分并创建红包
+func createHongbao(chatGroup *model.ChatGroup, fromUser *tgbotapi.User, hongbao *model.Hongbao) (tipMsg string, err error) {
+	// 获取用户对应的互斥锁
+	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, chatGroup.TgChatGroupId, fromUser.ID)
+	userLock := getUserLock(userLockKey)
+	userLock.Lock()
+	defer userLock.Unlock()
+
+	tx := db.Begin()
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    fromUser.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(tx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return "您还未注册，使用 /register 进行注册。", nil
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    fromUser.ID,
+			"ChatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("查询用户信息异常")
+		tx.Rollback()
+		return "", err
+	}
+
+	if chatGroupUser.Balance.LessThan(hongbao.TotalAmount) {
+		tx.Rollback()
+		return fmt.Sprintf("您的余额不足!发红包需要%s积分,积分余额%s。", utils.FormatAmount(hongbao.TotalAmount), utils.FormatAmount(chatGroupUser.Balance)), nil
+	}
+
+	balanceBefore := chatGroupUser.Balance
+	chatGroupUser.Balance = chatGroupUser.Balance.Sub(hongbao.TotalAmount)
+	result := tx.Save(&chatGroupUser)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             result.Error,
+		}).Error("扣除红包积分异常")
+		tx.Rollback()
+		return "", result.Error
+	}
+
+	hongbao.SenderChatGroupUserId = chatGroupUser.Id
+	err = hongbao.Create(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("创建红包异常")
+		tx.Rollback()
+		return "", err
+	}
+
+	err = addBalanceLedger(tx, chatGroupUser, balanceBefore, enums.HongbaoSendLedger, &model.BalanceLedger{RefId: hongbao.Id})
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("发红包事务提交异常")
+		tx.Rollback()
+		return "", err
+	}
+
+	return "", nil
+}
+
+// hongbaoGrabCallBack 抢红包
+// 回调在各自的协程中并发执行,以红包锁串行化同一红包的领取,并以剩余个数作为版本号更新红包,防止超领
+func hongbaoGrabCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	callBackData, err := queryCallBackData(query, enums.CallbackHongbaoGrab)
+	if err != nil {
+		answerCallbackQuery(bot, query, "红包已过期!", true)
+		return
+	}
+
+	hongbaoLock := getHongbaoLock(callBackData["hongbaoId"])
+	hongbaoLock.Lock()
+	defer hongbaoLock.Unlock()
+
+	hongbaoQuery := &model.Hongbao{Id: callBackData["hongbaoId"]}
+	hongbao, err := hongbaoQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"hongbaoId": hongbaoQuery.Id,
+			"err":       err,
+		}).Error("查询红包异常")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			removeHongbaoLock(hongbaoQuery.Id)
+		}
+		answerCallbackQuery(bot, query, "红包不存在!", true)
+		return
+	}
+
+	if hongbao.Status != enums.HongbaoActive.Value || hongbao.RemainCount <= 0 {
+		removeHongbaoLock(hongbao.Id)
+		hongbaoStatus, _ := enums.GetHongbaoStatus(hongbao.Status)
+		answerCallbackQuery(bot, query, fmt.Sprintf("来晚了,红包%s!", hongbaoStatus.Name), true)
+		return
+	}
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    query.From.ID,
+		ChatGroupId: hongbao.ChatGroupId,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		answerCallbackQuery(bot, query, "您还未注册，使用 /register 进行注册。", true)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    query.From.ID,
+			"ChatGroupId": hongbao.ChatGroupId,
+			"err":         err,
+		}).Error("群用户查询异常")
+		return
+	}
+
+	hongbaoClaimQuery := &model.HongbaoClaim{HongbaoId: hongbao.Id}
+	hongbaoClaims, err := hongbaoClaimQuery.ListByHongbaoId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"hongbaoId": hongbao.Id,
+			"err":       err,
+		}).Error("查询抢红包记录异常")
+		return
+	}
+	for _, claim := range hongbaoClaims {
+		if claim.ChatGroupUserId == chatGroupUser.Id {
+			answerCallbackQuery(bot, query, fmt.Sprintf("您已抢过该红包,抢到%s积分。", utils.FormatAmount(claim.Amount)), true)
+			return
+		}
+	}
+
+	amount := nextHongbaoShare(hongbao)
+	claim, err := claimHongbao(hongbao, chatGroupUser, query.From, amount)
+	if err != nil {
+		answerCallbackQuery(bot, query, "抢红包失败,请稍后再试!", true)
+		return
+	}
+	hongbaoClaims = append(hongbaoClaims, claim)
+
+	answerCallbackQuery(bot, query, fmt.Sprintf("🧧恭喜抢到%s积分!", utils.FormatAmount(amount)), true)
+	refreshHongbaoMessage(bot, hongbao, hongbaoClaims)
+
+	if hongbao.Status == enums.HongbaoFinished.Value {
+		stopHongbaoExpire(hongbao.Id)
+		removeHongbaoLock(hongbao.Id)
+	}
+}
+
+// claimHongbao 记录领取并为领取人加积分 需在红包锁内调用
+func claimHongbao(hongbao *model.Hongbao, chatGroupUser *model.ChatGroupUser, fromUser *tgbotapi.User, amount decimal.Decimal) (*model.HongbaoClaim, error) {
+	// 获取用户对应的互斥锁
+	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, hongbao.TgChatGroupId, chatGroupUser.TgUserId)
+	userLock := getUserLock(userLockKey)
+	userLock.Lock()
+	defer userLock.Unlock()
+
+	tx := db.Begin()
+
+	// 加锁后重新查询余额
+	chatGroupUserQuery := &model.ChatGroupUser{Id: chatGroupUser.Id}
+	chatGroupUser, err := chatGroupUserQuery.QueryById(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUserQuery.Id,
+			"err":             err,
+		}).Error("查询用户信息异常")
+		tx.Rollback()
+		return nil, err
+	}
+
+	currentTime := time.Now().Format("2006-01-02 15:04:05")
+	remainCountBefore := hongbao.RemainCount
+	hongbaoUpdate := &model.Hongbao{
+		Id:           hongbao.Id,
+		RemainAmount: hongbao.RemainAmount.Sub(amount),
+		RemainCount:  hongbao.RemainCount - 1,
+		Status:       enums.HongbaoActive.Value,
+		UpdateTime:   currentTime,
+	}
+	if hongbaoUpdate.RemainCount == 0 {
+		hongbaoUpdate.Status = enums.HongbaoFinished.Value
+	}
+	updated, err := hongbaoUpdate.UpdateRemainByIdAndRemainCount(tx, remainCountBefore)
+	if err != nil || !updated {
+		logrus.WithFields(logrus.Fields{
+			"hongbaoId": hongbao.Id,
+			"updated":   updated,
+			"err":       err,
+		}).Error("更新红包剩余积分异常")
+		tx.Rollback()
+		if err == nil {
+			err = errors.New("红包已被更新")
+		}
+		return nil, err
+	}
+
+	claim := &model.HongbaoClaim{
+		HongbaoId:       hongbao.Id,
+		ChatGroupUserId: chatGroupUser.Id,
+		TgUserId:        chatGroupUser.TgUserId,
+		ClaimerName:     hongbaoUserName(fromUser),
+		Amount:          amount,
+		CreateTime:      currentTime,
+	}
+	err = claim.Create(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"hongbaoId":       hongbao.Id,
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("保存抢红包记录异常")
+		tx.Rollback()
+		return nil, err
+	}
+
+	balanceBefore := chatGroupUser.Balance
+	chatGroupUser.Balance = chatGroupUser.Balance.Add(amount)
+	result := tx.Save(&chatGroupUser)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             result.Error,
+		}).Error("更新用户余额异常")
+		tx.Rollback()
+		return nil, result.Error
+	}
+
+	err = addBalanceLedger(tx, chatGroupUser, balanceBefore, enums.HongbaoClaimLedger, &model.BalanceLedger{RefId: hongbao.Id})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("抢红包事务提交异常")
+		tx.Rollback()
+		return nil, err
+	}
+
+	hongbao.RemainAmount = hongbaoUpdate.RemainAmount
+	hongbao.RemainCount = hongbaoUpdate.RemainCount
+	hongbao.Status = hongbaoUpdate.Status
+	hongbao.UpdateTime = hongbaoUpdate.UpdateTime
+	return claim, nil
+}
+
+// nextHongbaoShare 计算下一个红包金额
+// 普通红包平分,除不尽的零头归最后一个;拼手气红包使用二倍均值法,在[0.01, 剩余均值*2)内随机,最后一个领取剩余全部
+func nextHongbaoShare(hongbao *model.Hongbao) decimal.Decimal {
+	remainCents := hongbao.RemainAmount.Shift(utils.AmountPlaces).IntPart()
+	remainCount := int64(hongbao.RemainCount)
+	if remainCount <= 1 {
+		return hongbao.RemainAmount
+	}
+
+	var shareCents int64
+	if hongbao.SplitMode == enums.HongbaoEqualSplit.Value {
+		shareCents = remainCents / remainCount
+	} else {
+		maxCents := remainCents * 2 / remainCount
+		shareCents = 1
+		if maxCents > 1 {
+			shareCents = rand.Int63n(maxCents-1) + 1
+		}
+	}
+	return decimal.New(shareCents, -utils.AmountPlaces)
+}
+
+// scheduleHongbaoExpire 到期后退还红包剩余积分
+func scheduleHongbaoExpire(bot *tgbotapi.BotAPI, hongbao *model.Hongbao) {
+	duration := time.Duration(0)
+	expireTime, err := time.ParseInLocation("2006-01-02 15:04:05", hongbao.ExpireTime, time.Local)
+	if err == nil {
+		duration = time.Until(expireTime)
+	}
+
+	hongbaoTimersMutex.Lock()
+	defer hongbaoTimersMutex.Unlock()
+
+	if timer, ok := hongbaoTimers[hongbao.Id]; ok {
+		timer.Stop()
+	}
+	hongbaoId := hongbao.Id
+	hongbaoTimers[hongbaoId] = time.AfterFunc(duration, func() {
+		hongbaoExpire(bot, hongbaoId)
+	})
+}
+
+func stopHongbaoExpire(hongbaoId string) {
+	hongbaoTimersMutex.Lock()
+	defer hongbaoTimersMutex.Unlock()
+
+	if timer, ok := hongbaoTimers[hongbaoId]; ok {
+		timer.Stop()
+		delete(hongbaoTimers, hongbaoId)
+	}
+}
+
+// hongbaoExpire 红包过期 剩余积分退还发送人
+func hongbaoExpire(bot *tgbotapi.BotAPI, hongbaoId string) {
+	stopHongbaoExpire(hongbaoId)
+
+	hongbaoLock := getHongbaoLock(hongbaoId)
+	hongbaoLock.Lock()
+	defer hongbaoLock.Unlock()
+
+	hongbaoQuery := &model.Hongbao{Id: hongbaoId}
+	hongbao, err := hongbaoQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"hongbaoId": hongbaoId,
+			"err":       err,
+		}).Error("查询红包异常")
+		return
+	}
+	if hongbao.Status != enums.HongbaoActive.Value {
+		removeHongbaoLock(hongbaoId)
+		return
+	}
+
+	refundAmount := hongbao.RemainAmount
+	err = refundHongbao(hongbao)
+	if err != nil {
+		return
+	}
+	removeHongbaoLock(hongbaoId)
+
+	hongbaoClaimQuery := &model.HongbaoClaim{HongbaoId: hongbao.Id}
+	hongbaoClaims, err := hongbaoClaimQuery.ListByHongbaoId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"hongbaoId": hongbao.Id,
+			"err":       err,
+		}).Error("查询抢红包记录异常")
+		return
+	}
+	refreshHongbaoMessage(bot, hongbao, hongbaoClaims)
+
+	if refundAmount.IsPositive() {
+		sendMsg := tgbotapi.NewMessage(hongbao.SenderTgUserId,
+			fmt.Sprintf("您发的红包已过期,剩余%d个共%s积分已退还。", hongbao.RemainCount, utils.FormatAmount(refundAmount)))
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, hongbao.SenderTgUserId)
+	}
+}
+
+// refundHongbao 红包置为过期并退还剩余积分 需在红包锁内调用
+func refundHongbao(hongbao *model.Hongbao) error {
+	// 获取用户对应的互斥锁
+	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, hongbao.TgChatGroupId, hongbao.SenderTgUserId)
+	userLock := getUserLock(userLockKey)
+	userLock.Lock()
+	defer userLock.Unlock()
+
+	tx := db.Begin()
+
+	hongbaoUpdate := &model.Hongbao{
+		Id:           hongbao.Id,
+		RemainAmount: hongbao.RemainAmount,
+		RemainCount:  hongbao.RemainCount,
+		Status:       enums.HongbaoExpired.Value,
+		UpdateTime:   time.Now().Format("2006-01-02 15:04:05"),
+	}
+	updated, err := hongbaoUpdate.UpdateRemainByIdAndRemainCount(tx, hongbao.RemainCount)
+	if err != nil || !updated {
+		logrus.WithFields(logrus.Fields{
+			"hongbaoId": hongbao.Id,
+			"updated":   updated,
+			"err":       err,
+		}).Error("更新红包状态异常")
+		tx.Rollback()
+		if err == nil {
+			err = errors.New("红包已被更新")
+		}
+		return err
+	}
+
+	if hongbao.RemainAmount.IsPositive() {
+		chatGroupUserQuery := &model.ChatGroupUser{Id: hongbao.SenderChatGroupUserId}
+		chatGroupUser, err := chatGroupUserQuery.QueryById(tx)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupUserId": hongbao.SenderChatGroupUserId,
+				"err":             err,
+			}).Error("查询用户信息异常")
+			tx.Rollback()
+			return err
+		}
+
+		balanceBefore := chatGroupUser.Balance
+		chatGroupUser.Balance = chatGroupUser.Balance.Add(hongbao.RemainAmount)
+		result := tx.Save(&chatGroupUser)
+		if result.Error != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupUserId": chatGroupUser.Id,
+				"err":             result.Error,
+			}).Error("更新用户余额异常")
+			tx.Rollback()
+			return result.Error
+		}
+
+		err = addBalanceLedger(tx, chatGroupUser, balanceBefore, enums.HongbaoRefundLedger, &model.BalanceLedger{RefId: hongbao.Id})
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("红包退还事务提交异常")
+		tx.Rollback()
+		return err
+	}
+
+	hongbao.Status = hongbaoUpdate.Status
+	hongbao.UpdateTime = hongbaoUpdate.UpdateTime
+	return nil
+}
+
+// refreshHongbaoMessage 刷新群内红包消息 红包结束后移除按钮
+func refreshHongbaoMessage(bot *tgbotapi.BotAPI, hongbao *model.Hongbao, hongbaoClaims []*model.HongbaoClaim) {
+	if hongbao.MessageId == 0 {
+		return
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(hongbao.TgChatGroupId, hongbao.MessageId, buildHongbaoText(hongbao, hongbaoClaims))
+	if hongbao.Status == enums.HongbaoActive.Value {
+		inlineKeyboardMarkup, err := buildHongbaoInlineKeyboardMarkup(hongbao)
+		if err == nil {
+			editMsg.ReplyMarkup = inlineKeyboardMarkup
+		}
+	}
+	_, err := sendMessage(bot, &editMsg)
+	blockedOrKicked(err, hongbao.TgChatGroupId)
+}
+
+func buildHongbaoText(hongbao *model.Hongbao, hongbaoClaims []*model.HongbaoClaim) string {
+	splitMode, _ := enums.GetHongbaoSplitMode(hongbao.SplitMode)
+	status, _ := enums.GetHongbaoStatus(hongbao.Status)
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("🧧%s发了一个%s【%s】\n共%s积分 %d个 剩余%d个\n",
+		hongbao.SenderName,
+		splitMode.Name,
+		status.Name,
+		utils.FormatAmount(hongbao.TotalAmount),
+		hongbao.TotalCount,
+		hongbao.RemainCount))
+
+	if len(hongbaoClaims) > 0 {
+		// 拼手气红包抢完后标记手气最佳
+		bestIndex := -1
+		if hongbao.SplitMode == enums.HongbaoRandomSplit.Value && hongbao.Status == enums.HongbaoFinished.Value {
+			for i, claim := range hongbaoClaims {
+				if bestIndex == -1 || claim.Amount.GreaterThan(hongbaoClaims[bestIndex].Amount) {
+					bestIndex = i
+				}
+			}
+		}
+
+		text.WriteString("\n")
+		for i, claim := range hongbaoClaims {
+			best := ""
+			if i == bestIndex {
+				best = " 👑手气最佳"
+			}
+			text.WriteString(fmt.Sprintf("%s %s积分%s\n", claim.ClaimerName, utils.FormatAmount(claim.Amount), best))
+		}
+	}
+
+	switch hongbao.Status {
+	case enums.HongbaoActive.Value:
+		text.WriteString(fmt.Sprintf("\n%s前有效,过期未领取的积分将退还。", hongbao.ExpireTime))
+	case enums.HongbaoExpired.Value:
+		text.WriteString(fmt.Sprintf("\n红包已过期,剩余%s积分已退还。", utils.FormatAmount(hongbao.RemainAmount)))
+	}
+	return text.String()
+}
+
+func buildHongbaoInlineKeyboardMarkup(hongbao *model.Hongbao) (*tgbotapi.InlineKeyboardMarkup, error) {
+	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+		"hongbaoId": hongbao.Id,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"hongbaoId": hongbao.Id,
+			"err":       err,
+		}).Error("内联键盘回调参数存入redis异常")
+		return nil, err
+	}
+
+	callbackDataQueryString := utils.MapToQueryString(map[string]string{
+		"callbackDataKey": callbackDataKey,
+	})
+
+	inlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🧧抢", fmt.Sprintf("%s%s", enums.CallbackHongbaoGrab.Value, callbackDataQueryString)),
+		),
+	)
+	return &inlineKeyboardMarkup, nil
+}
+
+func hongbaoUserName(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return fmt.Sprintf("@%s", user.UserName)
+	}
+	return user.FirstName
+}
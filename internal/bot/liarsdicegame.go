@@ -0,0 +1,998 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"telegram-dice-bot/internal/common"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	LiarsDiceDiceCount     = 5                // 每位玩家初始骰子数
+	LiarsDiceMinPlayers    = 2                // 最少开局人数
+	LiarsDiceMaxPlayers    = 6                // 最多入座人数
+	LiarsDiceTurnTimeout   = 60 * time.Second // 每回合行动超时时间
+	LiarsDiceWaitTimeout   = 5 * time.Minute  // 等待入座超时时间
+	LiarsDiceRetryInterval = 30 * time.Second // 超时退款或派奖失败后的重试间隔
+)
+
+var (
+	liarsDiceTimers      = make(map[string]*time.Timer)
+	liarsDiceTimersMutex sync.Mutex
+)
+
+var liarsDiceActiveStatuses = []string{enums.LiarsDiceWaiting.Value, enums.LiarsDicePlaying.Value}
+
+// initLiarsDiceTask 恢复重启前未结束的牌桌超时任务
+func initLiarsDiceTask(bot *tgbotapi.BotAPI) {
+	liarsDiceTables, err := model.ListLiarsDiceTableByStatuses(db, liarsDiceActiveStatuses)
+	if err != nil {
+		logrus.WithField("err", err).Error("查询未结束的吹牛骰子牌桌异常")
+		return
+	}
+
+	for _, table := range liarsDiceTables {
+		state, err := unmarshalLiarsDiceState(table)
+		if err != nil {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"tableId": table.Id,
+			"status":  table.Status,
+		}).Info("恢复吹牛骰子牌桌")
+		scheduleLiarsDiceTimeout(bot, table, state.Version)
+	}
+}
+
+// handleLiarsDiceCommand 开设吹牛骰子牌桌 示例: /liarsdice 100
+func handleLiarsDiceCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	fromUser := message.From
+	messageId := message.MessageID
+
+	ante, err := utils.ParseAmount(message.CommandArguments())
+	if err != nil {
+		msgConfig := tgbotapi.NewMessage(tgChatGroupId, "请输入入场积分,例子: /liarsdice 100")
+		msgConfig.ReplyToMessageID = messageId
+		_, err := sendMessage(bot, &msgConfig)
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	}
+
+	// 查询该群的信息
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	liarsDiceLock := getLiarsDiceLock(chatGroup.Id)
+	liarsDiceLock.Lock()
+	defer liarsDiceLock.Unlock()
+
+	// 每个群同时只允许一张牌桌
+	liarsDiceTableQuery := &model.LiarsDiceTable{ChatGroupId: chatGroup.Id}
+	_, err = liarsDiceTableQuery.QueryByChatGroupIdAndStatuses(db, liarsDiceActiveStatuses)
+	if err == nil {
+		msgConfig := tgbotapi.NewMessage(tgChatGroupId, "当前群内已有未结束的吹牛骰子牌桌!")
+		msgConfig.ReplyToMessageID = messageId
+		_, err := sendMessage(bot, &msgConfig)
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("查询吹牛骰子牌桌异常")
+		return
+	}
+
+	table, state, tipMsg, err := createLiarsDiceTable(chatGroup, fromUser, ante)
+	if err != nil {
+		return
+	} else if tipMsg != "" {
+		msgConfig := tgbotapi.NewMessage(tgChatGroupId, tipMsg)
+		msgConfig.ReplyToMessageID = messageId
+		_, err := sendMessage(bot, &msgConfig)
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(tgChatGroupId, buildLiarsDiceTableText(table, state))
+	inlineKeyboardMarkup, err := buildLiarsDiceInlineKeyboardMarkup(table, state)
+	if err != nil {
+		return
+	}
+	sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	sentMsg, err := sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	}
+
+	table.MessageId = sentMsg.MessageID
+	db.Model(table).Update("message_id", table.MessageId)
+
+	scheduleLiarsDiceTimeout(bot, table, state.Version)
+}
+
+// createLiarsDiceTable 扣除开桌用户入场积分并创建牌桌 扣款与牌桌在同一事务中提交 tipMsg不为空时表示无法开桌的原因
+func createLiarsDiceTable(chatGroup *model.ChatGroup, fromUser *tgbotapi.User, ante decimal.Decimal) (*model.LiarsDiceTable, *common.LiarsDiceState, string, error) {
+	tableId, err := utils.NextID()
+	if err != nil {
+		logrus.Error("SnowFlakeId create error")
+		return nil, nil, "", err
+	}
+
+	unlock := lockChatGroupUsers(chatGroup.TgChatGroupId, fromUser.ID)
+	defer unlock()
+
+	tx := db.Begin()
+
+	player, tipMsg, err := liarsDiceDeductAnte(tx, chatGroup, fromUser, tableId, ante)
+	if err != nil || tipMsg != "" {
+		tx.Rollback()
+		return nil, nil, tipMsg, err
+	}
+
+	currentTime := time.Now()
+	state := &common.LiarsDiceState{
+		Players: []*common.LiarsDicePlayer{player},
+	}
+	table := &model.LiarsDiceTable{
+		Id:              tableId,
+		ChatGroupId:     chatGroup.Id,
+		TgChatGroupId:   chatGroup.TgChatGroupId,
+		CreatorTgUserId: fromUser.ID,
+		Ante:            ante,
+		Pot:             ante,
+		Status:          enums.LiarsDiceWaiting.Value,
+		TurnDeadline:    currentTime.Add(LiarsDiceWaitTimeout).Format("2006-01-02 15:04:05"),
+		UpdateTime:      currentTime.Format("2006-01-02 15:04:05"),
+		CreateTime:      currentTime.Format("2006-01-02 15:04:05"),
+	}
+	stateBytes, _ := json.Marshal(state)
+	table.State = string(stateBytes)
+
+	err = table.Create(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("创建吹牛骰子牌桌异常")
+		tx.Rollback()
+		return nil, nil, "", err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("创建吹牛骰子牌桌事务提交异常")
+		tx.Rollback()
+		return nil, nil, "", err
+	}
+	return table, state, "", nil
+}
+
+// liarsDiceCallBack 吹牛骰子群内联键盘回调
+func liarsDiceCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, callbackPrefix enums.CallbackPrefix) {
+	callBackData, err := queryCallBackData(query, callbackPrefix)
+	if err != nil {
+		answerCallbackQuery(bot, query, "操作已过期!", true)
+		return
+	}
+
+	tableQuery := &model.LiarsDiceTable{Id: callBackData["tableId"]}
+	table, err := tableQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tableId": tableQuery.Id,
+			"err":     err,
+		}).Error("查询吹牛骰子牌桌异常")
+		answerCallbackQuery(bot, query, "牌桌不存在!", true)
+		return
+	}
+
+	liarsDiceLock := getLiarsDiceLock(table.ChatGroupId)
+	liarsDiceLock.Lock()
+	defer liarsDiceLock.Unlock()
+
+	// 加锁后重新查询牌桌
+	table, err = table.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tableId": tableQuery.Id,
+			"err":     err,
+		}).Error("查询吹牛骰子牌桌异常")
+		return
+	}
+	state, err := unmarshalLiarsDiceState(table)
+	if err != nil {
+		return
+	}
+
+	if callbackPrefix == enums.CallbackLiarsDiceMyDice {
+		// 查看自己的骰子不校验状态版本
+		player := findLiarsDicePlayer(state, query.From.ID)
+		if player == nil || table.Status != enums.LiarsDicePlaying.Value {
+			answerCallbackQuery(bot, query, "您不在本局游戏中!", true)
+			return
+		}
+		answerCallbackQuery(bot, query, fmt.Sprintf("第%d回合 您的骰子: %s", state.Round, formatLiarsDiceValues(player.Dice)), true)
+		return
+	}
+
+	if callBackData["version"] != strconv.Itoa(state.Version) {
+		answerCallbackQuery(bot, query, "牌桌状态已变化,请使用最新的按钮!", true)
+		return
+	}
+
+	// 入座扣款、退还入场积分及发放奖池与牌桌状态在同一事务中提交 失败时牌桌保持原状态可重新操作
+	unlock := lockLiarsDicePlayers(table, state, query.From.ID)
+	defer unlock()
+
+	tx := db.Begin()
+
+	previousRound := state.Round
+	var tipMsg string
+	switch callbackPrefix {
+	case enums.CallbackLiarsDiceJoin:
+		tipMsg = liarsDiceJoin(tx, table, state, query.From)
+	case enums.CallbackLiarsDiceStart:
+		tipMsg = liarsDiceStart(table, state, query.From)
+	case enums.CallbackLiarsDiceCancel:
+		tipMsg = liarsDiceCancel(tx, table, state, query.From)
+	case enums.CallbackLiarsDiceBid:
+		quantity, _ := strconv.Atoi(callBackData["quantity"])
+		face, _ := strconv.Atoi(callBackData["face"])
+		tipMsg = liarsDiceBid(table, state, query.From, quantity, face)
+	case enums.CallbackLiarsDiceCall:
+		tipMsg = liarsDiceCall(tx, table, state, query.From)
+	}
+
+	if tipMsg != "" {
+		tx.Rollback()
+		answerCallbackQuery(bot, query, tipMsg, true)
+		return
+	}
+
+	err = commitLiarsDiceTable(tx, table, state)
+	if err != nil {
+		answerCallbackQuery(bot, query, "操作失败,请稍后重试!", true)
+		return
+	}
+
+	answerCallbackQuery(bot, query, "", false)
+	refreshLiarsDiceTable(bot, table, state, previousRound)
+}
+
+func liarsDiceJoin(tx *gorm.DB, table *model.LiarsDiceTable, state *common.LiarsDiceState, fromUser *tgbotapi.User) string {
+	if table.Status != enums.LiarsDiceWaiting.Value {
+		return "牌桌已开始或已结束!"
+	}
+	if findLiarsDicePlayer(state, fromUser.ID) != nil {
+		return "您已在牌桌中!"
+	}
+	if len(state.Players) >= LiarsDiceMaxPlayers {
+		return fmt.Sprintf("牌桌已满%d人!", LiarsDiceMaxPlayers)
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, table.ChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": table.ChatGroupId,
+			"err":         err,
+		}).Error("群配置查询异常")
+		return "群配置查询异常!"
+	}
+
+	player, tipMsg, err := liarsDiceDeductAnte(tx, chatGroup, fromUser, table.Id, table.Ante)
+	if err != nil {
+		return "加入牌桌失败!"
+	} else if tipMsg != "" {
+		return tipMsg
+	}
+
+	state.Players = append(state.Players, player)
+	table.Pot = table.Pot.Add(table.Ante)
+	state.Version++
+	return ""
+}
+
+func liarsDiceStart(table *model.LiarsDiceTable, state *common.LiarsDiceState, fromUser *tgbotapi.User) string {
+	if table.Status != enums.LiarsDiceWaiting.Value {
+		return "牌桌已开始或已结束!"
+	}
+	if fromUser.ID != table.CreatorTgUserId {
+		return "只有开桌用户可以开始游戏!"
+	}
+	if len(state.Players) < LiarsDiceMinPlayers {
+		return fmt.Sprintf("至少需要%d人才能开始!", LiarsDiceMinPlayers)
+	}
+
+	for _, player := range state.Players {
+		player.Dice = make([]int, LiarsDiceDiceCount)
+	}
+	table.Status = enums.LiarsDicePlaying.Value
+	state.TurnIndex = 0
+	if err := startLiarsDiceRound(table, state); err != nil {
+		return "摇骰失败,请稍后重试!"
+	}
+	return ""
+}
+
+func liarsDiceCancel(tx *gorm.DB, table *model.LiarsDiceTable, state *common.LiarsDiceState, fromUser *tgbotapi.User) string {
+	if table.Status != enums.LiarsDiceWaiting.Value {
+		return "牌桌已开始或已结束!"
+	}
+	if fromUser.ID != table.CreatorTgUserId {
+		return "只有开桌用户可以取消牌桌!"
+	}
+	err := cancelLiarsDiceTable(tx, table, state, "开桌用户已取消牌桌,入场积分已退还。")
+	if err != nil {
+		return "退还入场积分失败,请稍后重试!"
+	}
+	return ""
+}
+
+func liarsDiceBid(table *model.LiarsDiceTable, state *common.LiarsDiceState, fromUser *tgbotapi.User, quantity int, face int) string {
+	if table.Status != enums.LiarsDicePlaying.Value {
+		return "牌桌未在进行中!"
+	}
+	if state.Players[state.TurnIndex].TgUserId != fromUser.ID {
+		return "还没轮到您行动!"
+	}
+	if !isValidLiarsDiceBid(state, quantity, face) {
+		return "叫数必须大于当前叫数!"
+	}
+
+	state.BidQuantity = quantity
+	state.BidFace = face
+	state.BidderIndex = state.TurnIndex
+	state.TurnIndex = nextLiarsDicePlayerIndex(state, state.TurnIndex)
+	state.Version++
+	table.TurnDeadline = time.Now().Add(LiarsDiceTurnTimeout).Format("2006-01-02 15:04:05")
+	return ""
+}
+
+func liarsDiceCall(tx *gorm.DB, table *model.LiarsDiceTable, state *common.LiarsDiceState, fromUser *tgbotapi.User) string {
+	if table.Status != enums.LiarsDicePlaying.Value {
+		return "牌桌未在进行中!"
+	}
+	if state.Players[state.TurnIndex].TgUserId != fromUser.ID {
+		return "还没轮到您行动!"
+	}
+	if state.BidQuantity == 0 {
+		return "本回合还没有人叫数,请先叫数!"
+	}
+
+	caller := state.Players[state.TurnIndex]
+	bidder := state.Players[state.BidderIndex]
+
+	// 开骰 统计所有在局玩家的叫数点数
+	count := 0
+	var reveal strings.Builder
+	for _, player := range state.Players {
+		if player.Eliminated {
+			continue
+		}
+		for _, value := range player.Dice {
+			if value == state.BidFace {
+				count++
+			}
+		}
+		reveal.WriteString(fmt.Sprintf("%s: %s\n", liarsDicePlayerName(player), formatLiarsDiceValues(player.Dice)))
+	}
+
+	loserIndex := state.TurnIndex
+	if count < state.BidQuantity {
+		loserIndex = state.BidderIndex
+	}
+	loser := state.Players[loserIndex]
+	loser.Dice = loser.Dice[:len(loser.Dice)-1]
+
+	state.LastResult = fmt.Sprintf("第%d回合 %s 开 %s 的【%d个%d】,实际%d个%d,%s 失去1颗骰子。\n%s",
+		state.Round,
+		liarsDicePlayerName(caller),
+		liarsDicePlayerName(bidder),
+		state.BidQuantity, state.BidFace,
+		count, state.BidFace,
+		liarsDicePlayerName(loser),
+		reveal.String())
+
+	if len(loser.Dice) == 0 {
+		loser.Eliminated = true
+		state.LastResult += fmt.Sprintf("%s 已出局!\n", liarsDicePlayerName(loser))
+	}
+
+	if activeLiarsDicePlayerCount(state) == 1 {
+		err := finishLiarsDiceTable(tx, table, state)
+		if err != nil {
+			return "发放奖池失败,请稍后重试!"
+		}
+		return ""
+	}
+
+	// 输家先手开始新回合 输家出局则由其下家先手
+	state.TurnIndex = loserIndex
+	if loser.Eliminated {
+		state.TurnIndex = nextLiarsDicePlayerIndex(state, loserIndex)
+	}
+	if err := startLiarsDiceRound(table, state); err != nil {
+		return "摇骰失败,请稍后重试!"
+	}
+	return ""
+}
+
+// liarsDiceTimeout 牌桌超时处理 等待中超时则取消牌桌 进行中超时则当前行动玩家出局
+func liarsDiceTimeout(bot *tgbotapi.BotAPI, table *model.LiarsDiceTable, version int) {
+	liarsDiceLock := getLiarsDiceLock(table.ChatGroupId)
+	liarsDiceLock.Lock()
+	defer liarsDiceLock.Unlock()
+
+	tableId := table.Id
+	table, err := table.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tableId": tableId,
+			"err":     err,
+		}).Error("查询吹牛骰子牌桌异常")
+		return
+	}
+	state, err := unmarshalLiarsDiceState(table)
+	if err != nil {
+		return
+	}
+	if state.Version != version {
+		// 超时前已有新的行动
+		return
+	}
+
+	if table.Status != enums.LiarsDiceWaiting.Value && table.Status != enums.LiarsDicePlaying.Value {
+		return
+	}
+
+	unlock := lockLiarsDicePlayers(table, state)
+	defer unlock()
+
+	tx := db.Begin()
+
+	previousRound := state.Round
+	if table.Status == enums.LiarsDiceWaiting.Value {
+		err = cancelLiarsDiceTable(tx, table, state, "等待超时,牌桌已取消,入场积分已退还。")
+	} else {
+		timeoutPlayer := state.Players[state.TurnIndex]
+		timeoutPlayer.Eliminated = true
+		timeoutPlayer.Dice = nil
+		state.LastResult = fmt.Sprintf("%s 行动超时,已出局!\n", liarsDicePlayerName(timeoutPlayer))
+
+		if activeLiarsDicePlayerCount(state) == 1 {
+			err = finishLiarsDiceTable(tx, table, state)
+		} else {
+			state.TurnIndex = nextLiarsDicePlayerIndex(state, state.TurnIndex)
+			if state.BidderIndex >= 0 && state.Players[state.BidderIndex].Eliminated {
+				// 叫数玩家出局 重新开始回合
+				err = startLiarsDiceRound(table, state)
+			} else {
+				state.Version++
+				table.TurnDeadline = time.Now().Add(LiarsDiceTurnTimeout).Format("2006-01-02 15:04:05")
+			}
+		}
+	}
+
+	if err != nil {
+		tx.Rollback()
+	} else {
+		err = commitLiarsDiceTable(tx, table, state)
+	}
+	if err != nil {
+		// 退款或派奖失败 牌桌保持原状态 稍后重试
+		retryLiarsDiceTimeout(bot, table, version)
+		return
+	}
+
+	refreshLiarsDiceTable(bot, table, state, previousRound)
+}
+
+// startLiarsDiceRound 开始新的回合 使用crypto/rand重新摇骰 骰子在事务提交后由 refreshLiarsDiceTable 私聊发送给玩家
+func startLiarsDiceRound(table *model.LiarsDiceTable, state *common.LiarsDiceState) error {
+	for _, player := range state.Players {
+		if player.Eliminated {
+			continue
+		}
+		for i := range player.Dice {
+			n, err := rand.Int(rand.Reader, big.NewInt(6))
+			if err != nil {
+				logrus.WithField("err", err).Error("生成随机数异常")
+				return err
+			}
+			player.Dice[i] = int(n.Int64()) + 1
+		}
+	}
+
+	state.Round++
+	state.Version++
+	state.BidQuantity = 0
+	state.BidFace = 0
+	state.BidderIndex = -1
+	table.TurnDeadline = time.Now().Add(LiarsDiceTurnTimeout).Format("2006-01-02 15:04:05")
+	return nil
+}
+
+// sendLiarsDiceRoundDice 私聊发送玩家本回合的骰子
+func sendLiarsDiceRoundDice(bot *tgbotapi.BotAPI, table *model.LiarsDiceTable, state *common.LiarsDiceState) {
+	chatGroupTitle := ""
+	chatGroup, err := model.QueryChatGroupById(db, table.ChatGroupId)
+	if err == nil {
+		chatGroupTitle = chatGroup.TgChatGroupTitle
+	}
+
+	for _, player := range state.Players {
+		if player.Eliminated {
+			continue
+		}
+		sendMsg := tgbotapi.NewMessage(player.TgUserId, fmt.Sprintf("【%s】吹牛骰子第%d回合\n您的骰子: %s",
+			chatGroupTitle, state.Round, formatLiarsDiceValues(player.Dice)))
+		_, err := sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, player.TgUserId)
+	}
+}
+
+// cancelLiarsDiceTable 取消牌桌并退还所有玩家的入场积分 退款失败时返回错误,由调用方回滚事务
+func cancelLiarsDiceTable(tx *gorm.DB, table *model.LiarsDiceTable, state *common.LiarsDiceState, reason string) error {
+	for _, player := range state.Players {
+		err := liarsDiceChangeBalance(tx, player, table.Id, table.Ante, enums.LiarsDiceRefundLedger)
+		if err != nil {
+			return err
+		}
+	}
+	table.Status = enums.LiarsDiceCancelled.Value
+	table.Pot = decimal.Zero
+	state.LastResult = reason
+	state.Version++
+	return nil
+}
+
+// finishLiarsDiceTable 结束牌桌 奖池发放给最后的幸存者 派奖失败时返回错误,由调用方回滚事务
+func finishLiarsDiceTable(tx *gorm.DB, table *model.LiarsDiceTable, state *common.LiarsDiceState) error {
+	var winner *common.LiarsDicePlayer
+	for _, player := range state.Players {
+		if !player.Eliminated {
+			winner = player
+			break
+		}
+	}
+
+	err := liarsDiceChangeBalance(tx, winner, table.Id, table.Pot, enums.LiarsDiceWinLedger)
+	if err != nil {
+		return err
+	}
+
+	table.Status = enums.LiarsDiceFinished.Value
+	table.WinnerTgUserId = winner.TgUserId
+	state.LastResult += fmt.Sprintf("🏆 %s 获胜,赢得奖池%s积分!", liarsDicePlayerName(winner), utils.FormatAmount(table.Pot))
+	state.Version++
+	return nil
+}
+
+// notifyLiarsDiceWinner 事务提交后私聊通知获胜玩家
+func notifyLiarsDiceWinner(bot *tgbotapi.BotAPI, table *model.LiarsDiceTable, state *common.LiarsDiceState) {
+	winner := findLiarsDicePlayer(state, table.WinnerTgUserId)
+	if winner == nil {
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, table.ChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": table.ChatGroupId,
+			"err":         err,
+		}).Error("群配置查询异常")
+		return
+	}
+	chatGroupUserQuery := &model.ChatGroupUser{Id: winner.ChatGroupUserId}
+	chatGroupUser, err := chatGroupUserQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": winner.ChatGroupUserId,
+			"err":             err,
+		}).Error("查询用户信息异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(winner.TgUserId, fmt.Sprintf("【%s】恭喜您赢得吹牛骰子奖池%s积分,积分余额%s。", chatGroup.TgChatGroupTitle, utils.FormatAmount(table.Pot), utils.FormatAmount(chatGroupUser.Balance)))
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, winner.TgUserId)
+}
+
+// liarsDiceDeductAnte 在事务中扣除入场积分 tipMsg不为空时表示无法入座的原因 调用方需持有用户锁并负责提交或回滚
+func liarsDiceDeductAnte(tx *gorm.DB, chatGroup *model.ChatGroup, fromUser *tgbotapi.User, tableId string, ante decimal.Decimal) (player *common.LiarsDicePlayer, tipMsg string, err error) {
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    fromUser.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(tx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "您还未注册，使用 /register 进行注册。", nil
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    fromUser.ID,
+			"ChatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("查询用户信息异常")
+		return nil, "", err
+	}
+
+	if chatGroupUser.Balance.LessThan(ante) {
+		return nil, fmt.Sprintf("您的余额不足!入场需要%s积分。", utils.FormatAmount(ante)), nil
+	}
+
+	balanceBefore := chatGroupUser.Balance
+	chatGroupUser.Balance = chatGroupUser.Balance.Sub(ante)
+	result := tx.Save(&chatGroupUser)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             result.Error,
+		}).Error("扣除入场积分异常")
+		return nil, "", result.Error
+	}
+
+	err = addBalanceLedger(tx, chatGroupUser, balanceBefore, enums.LiarsDiceAnteLedger, &model.BalanceLedger{RefId: tableId})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &common.LiarsDicePlayer{
+		ChatGroupUserId: chatGroupUser.Id,
+		TgUserId:        fromUser.ID,
+		Username:        fromUser.UserName,
+		FirstName:       fromUser.FirstName,
+	}, "", nil
+}
+
+// liarsDiceChangeBalance 在事务中增加玩家积分(退还入场积分或发放奖池) 调用方需持有用户锁并负责提交或回滚
+func liarsDiceChangeBalance(tx *gorm.DB, player *common.LiarsDicePlayer, tableId string, amount decimal.Decimal, ledgerType enums.BalanceLedgerType) error {
+	chatGroupUserQuery := &model.ChatGroupUser{Id: player.ChatGroupUserId}
+	chatGroupUser, err := chatGroupUserQuery.QueryById(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": player.ChatGroupUserId,
+			"err":             err,
+		}).Error("查询用户信息异常")
+		return err
+	}
+
+	balanceBefore := chatGroupUser.Balance
+	chatGroupUser.Balance = chatGroupUser.Balance.Add(amount)
+	result := tx.Save(&chatGroupUser)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"amount":          amount,
+			"err":             result.Error,
+		}).Error("更新用户余额异常")
+		return result.Error
+	}
+
+	return addBalanceLedger(tx, chatGroupUser, balanceBefore, ledgerType, &model.BalanceLedger{RefId: tableId})
+}
+
+// lockLiarsDicePlayers 获取牌桌内全部玩家及额外用户的互斥锁 持有至牌桌事务提交 返回解锁函数
+func lockLiarsDicePlayers(table *model.LiarsDiceTable, state *common.LiarsDiceState, tgUserIds ...int64) func() {
+	for _, player := range state.Players {
+		tgUserIds = append(tgUserIds, player.TgUserId)
+	}
+	return lockChatGroupUsers(table.TgChatGroupId, tgUserIds...)
+}
+
+// commitLiarsDiceTable 在同一事务中持久化牌桌状态并提交 失败时回滚
+func commitLiarsDiceTable(tx *gorm.DB, table *model.LiarsDiceTable, state *common.LiarsDiceState) error {
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tableId": table.Id,
+			"err":     err,
+		}).Error("吹牛骰子牌桌状态序列化异常")
+		tx.Rollback()
+		return err
+	}
+	table.State = string(stateBytes)
+	table.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+
+	result := tx.Save(table)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"tableId": table.Id,
+			"err":     result.Error,
+		}).Error("保存吹牛骰子牌桌异常")
+		tx.Rollback()
+		return result.Error
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tableId": table.Id,
+			"err":     err,
+		}).Error("吹牛骰子牌桌事务提交异常")
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// refreshLiarsDiceTable 牌桌事务提交后刷新群内牌桌消息 开始新回合时私聊发送骰子 通知获胜玩家 并重新设置超时任务
+func refreshLiarsDiceTable(bot *tgbotapi.BotAPI, table *model.LiarsDiceTable, state *common.LiarsDiceState, previousRound int) {
+	if table.Status == enums.LiarsDicePlaying.Value && state.Round != previousRound {
+		sendLiarsDiceRoundDice(bot, table, state)
+	}
+	if table.Status == enums.LiarsDiceFinished.Value {
+		notifyLiarsDiceWinner(bot, table, state)
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(table.TgChatGroupId, table.MessageId, buildLiarsDiceTableText(table, state))
+	inlineKeyboardMarkup, err := buildLiarsDiceInlineKeyboardMarkup(table, state)
+	if err != nil {
+		return
+	}
+	sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, table.TgChatGroupId)
+
+	if table.Status == enums.LiarsDiceWaiting.Value || table.Status == enums.LiarsDicePlaying.Value {
+		scheduleLiarsDiceTimeout(bot, table, state.Version)
+	} else {
+		stopLiarsDiceTimeout(table.Id)
+	}
+}
+
+// scheduleLiarsDiceTimeout 按牌桌截止时间设置超时任务 会替换该牌桌原有的任务
+func scheduleLiarsDiceTimeout(bot *tgbotapi.BotAPI, table *model.LiarsDiceTable, version int) {
+	duration := time.Duration(0)
+	deadline, err := time.ParseInLocation("2006-01-02 15:04:05", table.TurnDeadline, time.Local)
+	if err == nil {
+		duration = time.Until(deadline)
+	}
+
+	liarsDiceTimersMutex.Lock()
+	defer liarsDiceTimersMutex.Unlock()
+
+	if timer, ok := liarsDiceTimers[table.Id]; ok {
+		timer.Stop()
+	}
+	liarsDiceTimers[table.Id] = time.AfterFunc(duration, func() {
+		liarsDiceTimeout(bot, table, version)
+	})
+}
+
+// retryLiarsDiceTimeout 超时处理中退款或派奖失败 间隔 LiarsDiceRetryInterval 后重试
+func retryLiarsDiceTimeout(bot *tgbotapi.BotAPI, table *model.LiarsDiceTable, version int) {
+	liarsDiceTimersMutex.Lock()
+	defer liarsDiceTimersMutex.Unlock()
+
+	if timer, ok := liarsDiceTimers[table.Id]; ok {
+		timer.Stop()
+	}
+	liarsDiceTimers[table.Id] = time.AfterFunc(LiarsDiceRetryInterval, func() {
+		liarsDiceTimeout(bot, table, version)
+	})
+}
+
+func stopLiarsDiceTimeout(tableId string) {
+	liarsDiceTimersMutex.Lock()
+	defer liarsDiceTimersMutex.Unlock()
+
+	if timer, ok := liarsDiceTimers[tableId]; ok {
+		timer.Stop()
+		delete(liarsDiceTimers, tableId)
+	}
+}
+
+func buildLiarsDiceTableText(table *model.LiarsDiceTable, state *common.LiarsDiceState) string {
+	status, _ := enums.GetLiarsDiceTableStatus(table.Status)
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("🎲吹牛骰子【%s】\n入场积分: %s 奖池: %s\n", status.Name, utils.FormatAmount(table.Ante), utils.FormatAmount(table.Pot)))
+	if table.Status == enums.LiarsDicePlaying.Value {
+		text.WriteString(fmt.Sprintf("第%d回合\n", state.Round))
+	}
+
+	text.WriteString("玩家:\n")
+	for i, player := range state.Players {
+		playerStatus := ""
+		if player.Eliminated {
+			playerStatus = "❌出局"
+		} else if table.Status == enums.LiarsDicePlaying.Value {
+			playerStatus = fmt.Sprintf("🎲x%d", len(player.Dice))
+			if i == state.TurnIndex {
+				playerStatus += " 👈"
+			}
+		}
+		text.WriteString(fmt.Sprintf("%d. %s %s\n", i+1, liarsDicePlayerName(player), playerStatus))
+	}
+
+	if state.LastResult != "" {
+		text.WriteString(fmt.Sprintf("\n%s\n", state.LastResult))
+	}
+
+	switch table.Status {
+	case enums.LiarsDiceWaiting.Value:
+		text.WriteString(fmt.Sprintf("\n%d-%d人可开局,由开桌用户点击开始。\n截止时间: %s", LiarsDiceMinPlayers, LiarsDiceMaxPlayers, table.TurnDeadline))
+	case enums.LiarsDicePlaying.Value:
+		if state.BidQuantity > 0 {
+			text.WriteString(fmt.Sprintf("\n当前叫数:【%d个%d】(%s)\n", state.BidQuantity, state.BidFace, liarsDicePlayerName(state.Players[state.BidderIndex])))
+		} else {
+			text.WriteString("\n本回合还没有人叫数\n")
+		}
+		text.WriteString(fmt.Sprintf("轮到 %s 行动,截止时间: %s\n骰子已私聊发送,也可点击【我的骰子】查看。", liarsDicePlayerName(state.Players[state.TurnIndex]), table.TurnDeadline))
+	}
+
+	return text.String()
+}
+
+func buildLiarsDiceInlineKeyboardMarkup(table *model.LiarsDiceTable, state *common.LiarsDiceState) (*tgbotapi.InlineKeyboardMarkup, error) {
+	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+		"tableId": table.Id,
+		"version": strconv.Itoa(state.Version),
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tableId": table.Id,
+			"err":     err,
+		}).Error("内联键盘回调参数存入redis异常")
+		return nil, err
+	}
+
+	callbackDataQueryString := utils.MapToQueryString(map[string]string{
+		"callbackDataKey": callbackDataKey,
+	})
+
+	var inlineKeyboardRows [][]tgbotapi.InlineKeyboardButton
+
+	switch table.Status {
+	case enums.LiarsDiceWaiting.Value:
+		inlineKeyboardRows = append(inlineKeyboardRows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🙋加入", fmt.Sprintf("%s%s", enums.CallbackLiarsDiceJoin.Value, callbackDataQueryString)),
+				tgbotapi.NewInlineKeyboardButtonData("▶️开始", fmt.Sprintf("%s%s", enums.CallbackLiarsDiceStart.Value, callbackDataQueryString)),
+				tgbotapi.NewInlineKeyboardButtonData("🚫取消", fmt.Sprintf("%s%s", enums.CallbackLiarsDiceCancel.Value, callbackDataQueryString)),
+			),
+		)
+	case enums.LiarsDicePlaying.Value:
+		// 叫数按钮 每行为同一个数的不同点数
+		totalDice := 0
+		for _, player := range state.Players {
+			totalDice += len(player.Dice)
+		}
+		minQuantity := state.BidQuantity
+		if minQuantity == 0 {
+			minQuantity = 1
+		}
+		for quantity := minQuantity; quantity <= minQuantity+2 && quantity <= totalDice; quantity++ {
+			var row []tgbotapi.InlineKeyboardButton
+			for face := 1; face <= 6; face++ {
+				if !isValidLiarsDiceBid(state, quantity, face) {
+					continue
+				}
+				bidCallbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+					"tableId":  table.Id,
+					"version":  strconv.Itoa(state.Version),
+					"quantity": strconv.Itoa(quantity),
+					"face":     strconv.Itoa(face),
+				})
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"tableId": table.Id,
+						"err":     err,
+					}).Error("内联键盘回调参数存入redis异常")
+					return nil, err
+				}
+				bidCallbackDataQueryString := utils.MapToQueryString(map[string]string{
+					"callbackDataKey": bidCallbackDataKey,
+				})
+				row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d个%d", quantity, face), fmt.Sprintf("%s%s", enums.CallbackLiarsDiceBid.Value, bidCallbackDataQueryString)))
+			}
+			if len(row) > 0 {
+				inlineKeyboardRows = append(inlineKeyboardRows, row)
+			}
+		}
+		inlineKeyboardRows = append(inlineKeyboardRows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🔥开!", fmt.Sprintf("%s%s", enums.CallbackLiarsDiceCall.Value, callbackDataQueryString)),
+				tgbotapi.NewInlineKeyboardButtonData("🎲我的骰子", fmt.Sprintf("%s%s", enums.CallbackLiarsDiceMyDice.Value, callbackDataQueryString)),
+			),
+		)
+	default:
+		// 牌桌结束后移除键盘
+		return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}, nil
+	}
+
+	newInlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(inlineKeyboardRows...)
+	return &newInlineKeyboardMarkup, nil
+}
+
+func unmarshalLiarsDiceState(table *model.LiarsDiceTable) (*common.LiarsDiceState, error) {
+	var state common.LiarsDiceState
+	err := json.Unmarshal([]byte(table.State), &state)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tableId": table.Id,
+			"err":     err,
+		}).Error("吹牛骰子牌桌状态解析异常")
+		return nil, err
+	}
+	return &state, nil
+}
+
+// isValidLiarsDiceBid 叫数需大于当前叫数: 个数更多 或 个数相同点数更大
+func isValidLiarsDiceBid(state *common.LiarsDiceState, quantity int, face int) bool {
+	if face < 1 || face > 6 || quantity < 1 {
+		return false
+	}
+	totalDice := 0
+	for _, player := range state.Players {
+		totalDice += len(player.Dice)
+	}
+	if quantity > totalDice {
+		return false
+	}
+	return quantity > state.BidQuantity || (quantity == state.BidQuantity && face > state.BidFace)
+}
+
+func nextLiarsDicePlayerIndex(state *common.LiarsDiceState, index int) int {
+	for i := 1; i <= len(state.Players); i++ {
+		next := (index + i) % len(state.Players)
+		if !state.Players[next].Eliminated {
+			return next
+		}
+	}
+	return index
+}
+
+func activeLiarsDicePlayerCount(state *common.LiarsDiceState) int {
+	count := 0
+	for _, player := range state.Players {
+		if !player.Eliminated {
+			count++
+		}
+	}
+	return count
+}
+
+func findLiarsDicePlayer(state *common.LiarsDiceState, tgUserId int64) *common.LiarsDicePlayer {
+	for _, player := range state.Players {
+		if player.TgUserId == tgUserId {
+			return player
+		}
+	}
+	return nil
+}
+
+func liarsDicePlayerName(player *common.LiarsDicePlayer) string {
+	if player.Username != "" {
+		return fmt.Sprintf("@%s", player.Username)
+	}
+	return player.FirstName
+}
+
+func formatLiarsDiceValues(dice []int) string {
+	values := make([]string, len(dice))
+	for i, value := range dice {
+		values[i] = strconv.Itoa(value)
+	}
+	return strings.Join(values, " ")
+}
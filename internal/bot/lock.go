@@ -1,6 +1,10 @@
 package bot
 
-import "sync"
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
 
 const (
 	ChatGroupUserLockKey = "%v_%v"
@@ -13,6 +17,15 @@ var userLocksMutex sync.Mutex
 var chatLocks = make(map[string]*sync.Mutex)
 var chatLocksMutex sync.Mutex
 
+var liarsDiceLocks = make(map[string]*sync.Mutex)
+var liarsDiceLocksMutex sync.Mutex
+
+var hongbaoLocks = make(map[string]*sync.Mutex)
+var hongbaoLocksMutex sync.Mutex
+
+var betBoardLocks = make(map[string]*sync.Mutex)
+var betBoardLocksMutex sync.Mutex
+
 // getUserLock 根据userID获取对应的互斥锁，如果不存在则创建一个新的锁
 func getUserLock(userID string) *sync.Mutex {
 	userLocksMutex.Lock()
@@ -36,3 +49,72 @@ func getChatLock(chatId string) *sync.Mutex {
 
 	return chatLocks[chatId]
 }
+
+// getLiarsDiceLock 根据chatGroupId获取吹牛骰子牌桌的互斥锁，如果不存在则创建一个新的锁
+func getLiarsDiceLock(chatGroupId string) *sync.Mutex {
+	liarsDiceLocksMutex.Lock()
+	defer liarsDiceLocksMutex.Unlock()
+
+	if _, ok := liarsDiceLocks[chatGroupId]; !ok {
+		liarsDiceLocks[chatGroupId] = &sync.Mutex{}
+	}
+
+	return liarsDiceLocks[chatGroupId]
+}
+
+// getHongbaoLock 根据hongbaoId获取红包的互斥锁，如果不存在则创建一个新的锁
+func getHongbaoLock(hongbaoId string) *sync.Mutex {
+	hongbaoLocksMutex.Lock()
+	defer hongbaoLocksMutex.Unlock()
+
+	if _, ok := hongbaoLocks[hongbaoId]; !ok {
+		hongbaoLocks[hongbaoId] = &sync.Mutex{}
+	}
+
+	return hongbaoLocks[hongbaoId]
+}
+
+// removeHongbaoLock 红包抢完或过期后删除对应的互斥锁 之后的领取均会因红包状态直接返回
+func removeHongbaoLock(hongbaoId string) {
+	hongbaoLocksMutex.Lock()
+	defer hongbaoLocksMutex.Unlock()
+
+	delete(hongbaoLocks, hongbaoId)
+}
+
+// getBetBoardLock 根据chatGroupId获取本期下注看板的互斥锁，如果不存在则创建一个新的锁
+func getBetBoardLock(chatGroupId string) *sync.Mutex {
+	betBoardLocksMutex.Lock()
+	defer betBoardLocksMutex.Unlock()
+
+	if _, ok := betBoardLocks[chatGroupId]; !ok {
+		betBoardLocks[chatGroupId] = &sync.Mutex{}
+	}
+
+	return betBoardLocks[chatGroupId]
+}
+
+// lockChatGroupUsers 按TgUserId从小到大获取同一群内多个用户的互斥锁，避免互相等待死锁，返回解锁函数
+func lockChatGroupUsers(tgChatGroupId int64, tgUserIds ...int64) func() {
+	sortedTgUserIds := make([]int64, 0, len(tgUserIds))
+	seen := make(map[int64]bool)
+	for _, tgUserId := range tgUserIds {
+		if !seen[tgUserId] {
+			seen[tgUserId] = true
+			sortedTgUserIds = append(sortedTgUserIds, tgUserId)
+		}
+	}
+	sort.Slice(sortedTgUserIds, func(i, j int) bool { return sortedTgUserIds[i] < sortedTgUserIds[j] })
+
+	locks := make([]*sync.Mutex, 0, len(sortedTgUserIds))
+	for _, tgUserId := range sortedTgUserIds {
+		lock := getUserLock(fmt.Sprintf(ChatGroupUserLockKey, tgChatGroupId, tgUserId))
+		lock.Lock()
+		locks = append(locks, lock)
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
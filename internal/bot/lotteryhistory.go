@@ -0,0 +1,295 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	LotteryHistoryPageSize = 10
+	// LotteryTrendIssueCount 走势统计的期数
+	LotteryTrendIssueCount = 100
+)
+
+func lotteryHistoryCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	tgChatGroupId := query.Message.Chat.ID
+
+	// 查询该群历史开奖信息
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	text, inlineKeyboardMarkup, err := buildLotteryHistoryMessage(chatGroup, 1)
+	if err != nil {
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(tgChatGroupId, text)
+	if inlineKeyboardMarkup != nil {
+		sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	}
+	sentMsg, err := sendMessage(bot, &sendMsg)
+
+	if err != nil {
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	}
+
+	go func(messageID int) {
+		time.Sleep(1 * time.Minute)
+		deleteMsg := tgbotapi.NewDeleteMessage(tgChatGroupId, messageID)
+		_, err := bot.Request(deleteMsg)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"err": err,
+			}).Error("删除消息异常")
+		}
+	}(sentMsg.MessageID)
+}
+
+// lotteryHistoryPageCallBack 开奖历史翻页 群内任何人可操作
+func lotteryHistoryPageCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	callBackData, err := queryCallBackData(query, enums.CallbackLotteryHistoryPage)
+	if err != nil {
+		answerCallbackQuery(bot, query, "记录已过期,请重新查看开奖历史", true)
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, callBackData["chatGroupId"])
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": callBackData["chatGroupId"],
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	page, err := strconv.Atoi(callBackData["page"])
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	text, inlineKeyboardMarkup, err := buildLotteryHistoryMessage(chatGroup, page)
+	if err != nil {
+		answerCallbackQuery(bot, query, "查询失败,请稍后再试!", true)
+		return
+	}
+
+	answerCallbackQuery(bot, query, "", false)
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	editMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &editMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// buildLotteryHistoryMessage 组装走势统计及一页开奖记录 没有开奖记录时不返回按钮
+func buildLotteryHistoryMessage(chatGroup *model.ChatGroup, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	lotteryRecord := &model.LotteryRecord{ChatGroupId: chatGroup.Id}
+	lotteryRecords, total, err := lotteryRecord.PageByChatGroupId(db, (page-1)*LotteryHistoryPageSize, LotteryHistoryPageSize)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("开奖记录查询异常")
+		return "", nil, err
+	}
+	if total == 0 {
+		return "暂无开奖记录", nil, nil
+	}
+	totalPage := int((total + LotteryHistoryPageSize - 1) / LotteryHistoryPageSize)
+
+	var text strings.Builder
+	if chatGroup.GameplayType == enums.QuickThere.Value {
+		trendText, err := buildQuickThereTrendText(chatGroup)
+		if err != nil {
+			return "", nil, err
+		}
+		text.WriteString(trendText)
+		text.WriteString("\n")
+	}
+
+	text.WriteString(fmt.Sprintf("开奖记录(第%d/%d页,共%d期):\n", page, totalPage, total))
+	for _, record := range lotteryRecords {
+		// 开奖类型查询开奖信息
+		switch record.GameplayType {
+		case enums.QuickThere.Value:
+			quickThereLotteryRecord := &model.QuickThereLotteryRecord{
+				Id: record.Id,
+			}
+			quickThereLotteryRecord, err := quickThereLotteryRecord.QueryById(db)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"IssueNumber": record.IssueNumber,
+				}).Error("快三开奖记录查询异常")
+				return "", nil, err
+			}
+
+			bigSmall, _ := enums.GetGameLotteryType(quickThereLotteryRecord.BigSmall)
+			singleDouble, _ := enums.GetGameLotteryType(quickThereLotteryRecord.SingleDouble)
+
+			triplet := ""
+			if quickThereLotteryRecord.Triplet == 1 {
+				triplet = "【豹子】"
+			}
+
+			diceValues := quickThereLotteryDiceValues(quickThereLotteryRecord)
+
+			text.WriteString(fmt.Sprintf("%s期 %s %s=%d %s %s %s\n",
+				quickThereLotteryRecord.IssueNumber,
+				"快三",
+				utils.JoinInts(diceValues, "+"),
+				sumDiceValues(diceValues),
+				bigSmall.Name,
+				singleDouble.Name,
+				triplet,
+			))
+		}
+	}
+
+	var inlineKeyboardButtons []tgbotapi.InlineKeyboardButton
+	if page > 1 {
+		button, err := buildLotteryHistoryButton("⬅️上一页", chatGroup.Id, page-1)
+		if err != nil {
+			return "", nil, err
+		}
+		inlineKeyboardButtons = append(inlineKeyboardButtons, button)
+	}
+	if page < totalPage {
+		button, err := buildLotteryHistoryButton("➡️下一页", chatGroup.Id, page+1)
+		if err != nil {
+			return "", nil, err
+		}
+		inlineKeyboardButtons = append(inlineKeyboardButtons, button)
+	}
+	if len(inlineKeyboardButtons) == 0 {
+		return text.String(), nil, nil
+	}
+
+	inlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(inlineKeyboardButtons)
+	return text.String(), &inlineKeyboardMarkup, nil
+}
+
+func buildLotteryHistoryButton(text string, chatGroupId string, page int) (tgbotapi.InlineKeyboardButton, error) {
+	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+		"chatGroupId": chatGroupId,
+		"page":        strconv.Itoa(page),
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("内联键盘回调参数存入redis异常")
+		return tgbotapi.InlineKeyboardButton{}, err
+	}
+	callbackDataQueryString := utils.MapToQueryString(map[string]string{
+		"callbackDataKey": callbackDataKey,
+	})
+	return tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("%s%s", enums.CallbackLotteryHistoryPage.Value, callbackDataQueryString)), nil
+}
+
+// buildQuickThereTrendText 统计最近 LotteryTrendIssueCount 期的大小单双豹子次数及占比、当前连开期数和距上次豹子期数
+func buildQuickThereTrendText(chatGroup *model.ChatGroup) (string, error) {
+	quickThereLotteryRecordQuery := &model.QuickThereLotteryRecord{ChatGroupId: chatGroup.Id}
+	quickThereLotteryRecords, err := quickThereLotteryRecordQuery.ListRecentByChatGroupId(db, LotteryTrendIssueCount)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("快三开奖记录查询异常")
+		return "", err
+	}
+	if len(quickThereLotteryRecords) == 0 {
+		return "", nil
+	}
+
+	counts := make(map[string]int)
+	for _, record := range quickThereLotteryRecords {
+		counts[record.BigSmall]++
+		counts[record.SingleDouble]++
+		if record.Triplet == 1 {
+			counts[enums.Triplet.Value]++
+		}
+	}
+
+	issueCount := len(quickThereLotteryRecords)
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("近%d期走势:\n", issueCount))
+	for _, lotteryTypes := range [][]enums.GameLotteryType{{enums.Big, enums.Small}, {enums.Single, enums.Double}, {enums.Triplet}} {
+		var parts []string
+		for _, lotteryType := range lotteryTypes {
+			parts = append(parts, fmt.Sprintf("%s %d次(%.1f%%)", lotteryType.Name, counts[lotteryType.Value], float64(counts[lotteryType.Value])*100/float64(issueCount)))
+		}
+		text.WriteString(strings.Join(parts, "丨") + "\n")
+	}
+
+	// 当前连开 从最近一期往前数相同结果的期数
+	bigSmallStreak, singleDoubleStreak := 1, 1
+	latest := quickThereLotteryRecords[0]
+	for _, record := range quickThereLotteryRecords[1:] {
+		if record.BigSmall != latest.BigSmall {
+			break
+		}
+		bigSmallStreak++
+	}
+	for _, record := range quickThereLotteryRecords[1:] {
+		if record.SingleDouble != latest.SingleDouble {
+			break
+		}
+		singleDoubleStreak++
+	}
+	bigSmall, _ := enums.GetGameLotteryType(latest.BigSmall)
+	singleDouble, _ := enums.GetGameLotteryType(latest.SingleDouble)
+	text.WriteString(fmt.Sprintf("当前连开: %s%d期丨%s%d期\n", bigSmall.Name, bigSmallStreak, singleDouble.Name, singleDoubleStreak))
+
+	// 距上次豹子 统计全部开奖记录而非仅走势统计范围
+	lastTriplet, err := quickThereLotteryRecordQuery.QueryLastTripletByChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		issueTotal, err := quickThereLotteryRecordQuery.CountByChatGroupId(db)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroup.Id,
+				"err":         err,
+			}).Error("快三开奖期数统计异常")
+			return "", err
+		}
+		text.WriteString(fmt.Sprintf("豹子: 已连续%d期未开出\n", issueTotal))
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("查询最近豹子开奖记录异常")
+		return "", err
+	} else {
+		sinceTriplet, err := quickThereLotteryRecordQuery.CountByChatGroupIdAndIssueNumberAfter(db, lastTriplet.IssueNumber)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroup.Id,
+				"err":         err,
+			}).Error("快三开奖期数统计异常")
+			return "", err
+		}
+		if sinceTriplet == 0 {
+			text.WriteString(fmt.Sprintf("豹子: 上期(%s期)刚开出\n", lastTriplet.IssueNumber))
+		} else {
+			text.WriteString(fmt.Sprintf("豹子: 距上次(%s期)已%d期未开出\n", lastTriplet.IssueNumber, sinceTriplet))
+		}
+	}
+	return text.String(), nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"os"
@@ -14,6 +15,8 @@ import (
 	"telegram-dice-bot/internal/common"
 	"telegram-dice-bot/internal/enums"
 	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
 )
 
 var whiteList = os.Getenv(WhiteList)
@@ -94,6 +97,9 @@ func handlePrivateText(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 		} else if enums.WaitQuickThereTripletOdds.Value == botPrivateChatCache.ChatStatus {
 			// 快三豹子倍率设置
 			updateQuickThereTripletOdds(bot, message, &botPrivateChatCache)
+		} else if enums.WaitQuickThereSmallMax.Value == botPrivateChatCache.ChatStatus {
+			// 快三大小分界设置
+			updateQuickThereSmallMax(bot, message, &botPrivateChatCache)
 		} else if enums.WaitQueryUser.Value == botPrivateChatCache.ChatStatus {
 			// 查询用户信息
 			queryUser(bot, message, &botPrivateChatCache)
@@ -103,6 +109,30 @@ func handlePrivateText(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 		} else if enums.WaitTransferBalance.Value == botPrivateChatCache.ChatStatus {
 			// 转让用户积分
 			transferBalance(bot, message, &botPrivateChatCache)
+		} else if enums.WaitRegisterReward.Value == botPrivateChatCache.ChatStatus {
+			// 注册奖励设置
+			updateRegisterReward(bot, message, &botPrivateChatCache)
+		} else if enums.WaitSignInRewardLadder.Value == botPrivateChatCache.ChatStatus {
+			// 签到奖励阶梯设置
+			updateSignInRewardLadder(bot, message, &botPrivateChatCache)
+		} else if enums.WaitRollRewardTable.Value == botPrivateChatCache.ChatStatus {
+			// 掷骰签到奖励表设置
+			updateRollRewardTable(bot, message, &botPrivateChatCache)
+		} else if enums.WaitReliefRule.Value == botPrivateChatCache.ChatStatus {
+			// 救济金规则设置
+			updateReliefRule(bot, message, &botPrivateChatCache)
+		} else if enums.WaitRebateRule.Value == botPrivateChatCache.ChatStatus {
+			// 反水规则设置
+			updateRebateRule(bot, message, &botPrivateChatCache)
+		} else if enums.WaitReferralRule.Value == botPrivateChatCache.ChatStatus {
+			// 邀请奖励规则设置
+			updateReferralRule(bot, message, &botPrivateChatCache)
+		} else if enums.WaitTransferRule.Value == botPrivateChatCache.ChatStatus {
+			// 转让规则设置
+			updateTransferRule(bot, message, &botPrivateChatCache)
+		} else if enums.WaitTransferHouseAccount.Value == botPrivateChatCache.ChatStatus {
+			// 转让手续费收费账户设置
+			updateTransferHouseAccount(bot, message, &botPrivateChatCache)
 		}
 
 	}
@@ -130,15 +160,15 @@ func transferBalance(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivate
 	// 分割字符串
 	chatGroupUserId := text[:index]
 	updateBalanceStr := text[index+1:]
-	updateBalance, err := strconv.ParseFloat(updateBalanceStr, 64)
+	updateBalance, err := decimal.NewFromString(updateBalanceStr)
 	if err != nil {
-		logrus.WithField("updateBalanceStr", updateBalanceStr).Error("updateBalance转int异常")
+		logrus.WithField("updateBalanceStr", updateBalanceStr).Error("updateBalance转decimal异常")
 		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("积分存在非法字符:%s", updateBalanceStr))
 		_, err = sendMessage(bot, &sendMsg)
 		blockedOrKicked(err, chatId)
 		return
-	} else if updateBalance <= 0 || updateBalance > 9999999999 {
-		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("积分不合法,可转让积分范围[0-9999999999]"))
+	} else if utils.CheckAmount(updateBalance) != nil {
+		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("积分不合法,可转让积分范围(0-9999999999],最多两位小数"))
 		_, err = sendMessage(bot, &sendMsg)
 		blockedOrKicked(err, chatId)
 		return
@@ -175,12 +205,6 @@ func transferBalance(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivate
 		return
 	}
 
-	// 获取被转让用户对应的互斥锁
-	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, group.TgChatGroupId, groupUser.TgUserId)
-	userLock := getUserLock(userLockKey)
-	userLock.Lock()
-	defer userLock.Unlock()
-
 	// 查询发起转让用户信息
 	sendChatGroupUser := &model.ChatGroupUser{
 		TgUserId:    fromUser.ID,
@@ -205,36 +229,30 @@ func transferBalance(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivate
 		return
 	}
 
-	// 获取发起转让用户对应的互斥锁
-	sendUserLockKey := fmt.Sprintf(ChatGroupUserLockKey, chatGroup.TgChatGroupId, sendGroupUser.TgUserId)
-	sendUserLock := getUserLock(sendUserLockKey)
-	sendUserLock.Lock()
-	defer sendUserLock.Unlock()
-
-	tx := db.Begin()
-
-	// 重新查询用户信息
-	groupUser, _ = groupUser.QueryById(tx)
-	sendGroupUser, _ = sendGroupUser.QueryById(tx)
-
 	// 根据运算符执行特定逻辑
 	switch operator {
 	case "+":
-		if sendGroupUser.Balance < updateBalance {
-			sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("积分余额不足,您的积分余额为%.2f。", sendGroupUser.Balance))
-		} else {
-			groupUser.Balance += updateBalance
-			tx.Save(&groupUser)
-			sendGroupUser.Balance -= updateBalance
-			tx.Save(&sendGroupUser)
-			sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("转让成功!【%s】中的用户【@%s】增加%.2f积分,您的积分余额为%.2f。", group.TgChatGroupTitle, groupUser.Username, updateBalance, sendGroupUser.Balance))
-			// 提交事务
-			if err := tx.Commit().Error; err != nil {
-				// 提交事务时出现异常，回滚事务
-				tx.Rollback()
-				return
-			}
+		var tipMsg string
+		var transferRecord *model.TransferRecord
+		transferRecord, sendGroupUser, groupUser, tipMsg, err = transferBetweenChatGroupUsers(group, sendGroupUser, groupUser, updateBalance)
+		if err != nil {
+			return
+		} else if tipMsg != "" {
+			sendMsg = tgbotapi.NewMessage(chatId, tipMsg)
+			_, err = sendMessage(bot, &sendMsg)
+			blockedOrKicked(err, chatId)
+			return
+		} else if transferRecord.Status == enums.TransferPending.Value {
+			sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("向【%s】中的用户【@%s】转让%s积分超过审批门槛,已提交管理员审批,审批通过后到账。", group.TgChatGroupTitle, groupUser.Username, utils.FormatAmount(updateBalance)))
+			_, err = sendMessage(bot, &sendMsg)
+			// 删除bot与当前对话人的cache
+			redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, fromUser.ID)
+			redisDB.Del(redisDB.Context(), redisKey)
+			blockedOrKicked(err, chatId)
+			notifyTransferApproval(bot, group, transferRecord, sendGroupUser, groupUser)
+			return
 		}
+		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("转让成功!【%s】中的用户【@%s】增加%s积分%s,您的积分余额为%s。", group.TgChatGroupTitle, groupUser.Username, utils.FormatAmount(updateBalance), formatTransferFee(transferRecord), utils.FormatAmount(sendGroupUser.Balance)))
 	}
 
 	_, err = sendMessage(bot, &sendMsg)
@@ -244,7 +262,7 @@ func transferBalance(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivate
 	blockedOrKicked(err, chatId)
 
 	// 发送被转让用户的提示消息
-	sendNotifyMsg := tgbotapi.NewMessage(groupUser.TgUserId, fmt.Sprintf("【%s】您收到用户【@%s】转让的%.2f积分,您的积分余额为%.2f。", group.TgChatGroupTitle, sendGroupUser.Username, updateBalance, groupUser.Balance))
+	sendNotifyMsg := tgbotapi.NewMessage(groupUser.TgUserId, fmt.Sprintf("【%s】您收到用户【@%s】转让的%s积分,您的积分余额为%s。", group.TgChatGroupTitle, sendGroupUser.Username, utils.FormatAmount(updateBalance), utils.FormatAmount(groupUser.Balance)))
 	_, err = sendMessage(bot, &sendNotifyMsg)
 	blockedOrKicked(err, groupUser.TgUserId)
 	return
@@ -266,8 +284,8 @@ func updateQuickThereTripletOdds(bot *tgbotapi.BotAPI, message *tgbotapi.Message
 		return
 	}
 
-	// 将字符串转换为float64
-	tripletOdds, err := strconv.ParseFloat(text, 64)
+	// 倍率最多两位小数
+	tripletOdds, err := utils.ParseAmount(text)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -287,7 +305,7 @@ func updateQuickThereTripletOdds(bot *tgbotapi.BotAPI, message *tgbotapi.Message
 		return
 	}
 
-	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!\n【经典快三】豹子倍率已设置为%.2f倍!", tripletOdds))
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!\n【经典快三】豹子倍率已设置为%s倍!", utils.FormatAmount(tripletOdds)))
 	sendMsg.ReplyToMessageID = messageId
 
 	_, err = sendMessage(bot, &sendMsg)
@@ -316,8 +334,8 @@ func updateQuickThereSimpleOdds(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 		return
 	}
 
-	// 将字符串转换为float64
-	simpleOdds, err := strconv.ParseFloat(text, 64)
+	// 倍率最多两位小数
+	simpleOdds, err := utils.ParseAmount(text)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -337,7 +355,65 @@ func updateQuickThereSimpleOdds(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 		return
 	}
 
-	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!\n【经典快三】简易倍率已设置为%.2f倍!", simpleOdds))
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!\n【经典快三】简易倍率已设置为%s倍!", utils.FormatAmount(simpleOdds)))
+	sendMsg.ReplyToMessageID = messageId
+
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+	// 删除bot与当前对话人的cache
+	redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
+	redisDB.Del(redisDB.Context(), redisKey)
+}
+
+func updateQuickThereSmallMax(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCache *common.BotPrivateChatCache) {
+	text := message.Text
+	tgUserId := message.From.ID
+	chatId := message.Chat.ID
+	messageId := message.MessageID
+
+	// 校验当前对话人是否为该群管理员
+	err := checkGroupAdmin(botPrivateChatCache.ChatGroupId, tgUserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"tgUserId":    tgUserId,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	quickThereConfig, err := model.QueryQuickThereConfigByChatGroupId(db, botPrivateChatCache.ChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ChatGroupId": botPrivateChatCache.ChatGroupId,
+			"err":         err,
+		}).Error("快三配置查询异常")
+		return
+	}
+
+	// 分界需保证大、小均有可能出现
+	smallMaxTotal, err := strconv.Atoi(text)
+	if err != nil || smallMaxTotal < quickThereConfig.DiceCount || smallMaxTotal >= quickThereConfig.DiceCount*6 {
+		sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("请输入%d-%d之间的整数!", quickThereConfig.DiceCount, quickThereConfig.DiceCount*6-1))
+		sendMsg.ReplyToMessageID = messageId
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	}
+
+	quickThereConfig.SmallMaxTotal = smallMaxTotal
+	err = quickThereConfig.UpdateSmallMaxTotalByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ChatGroupId":   botPrivateChatCache.ChatGroupId,
+			"SmallMaxTotal": smallMaxTotal,
+		}).Error("设置快三大小分界异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!\n【经典快三】总点数小于等于%d为小(下一期生效)!\n\n%s", smallMaxTotal, buildQuickThereOddsSuggestion(quickThereConfig)))
 	sendMsg.ReplyToMessageID = messageId
 
 	_, err = sendMessage(bot, &sendMsg)
@@ -390,18 +466,18 @@ func updateUserBalance(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPriva
 	// 分割字符串
 	chatGroupUserId := text[:index]
 	updateBalanceStr := text[index+1:]
-	updateBalance, err := strconv.ParseFloat(updateBalanceStr, 64)
+	updateBalance, err := decimal.NewFromString(updateBalanceStr)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"updateBalanceStr": updateBalanceStr,
 			"err":              err,
-		}).Error("updateBalance转int异常")
+		}).Error("updateBalance转decimal异常")
 		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("积分存在非法字符:%s", updateBalanceStr))
 		_, err = sendMessage(bot, &sendMsg)
 		blockedOrKicked(err, chatId)
 		return
-	} else if updateBalance <= 0 || updateBalance > 9999999999 {
-		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("积分不合法,可调整积分范围[0-9999999999]"))
+	} else if utils.CheckAmount(updateBalance) != nil {
+		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("积分不合法,可调整积分范围(0-9999999999],最多两位小数"))
 		_, err = sendMessage(bot, &sendMsg)
 		blockedOrKicked(err, chatId)
 		return
@@ -446,32 +522,70 @@ func updateUserBalance(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPriva
 
 	// 重新查询用户信息
 	groupUser, _ = chatGroupUser.QueryById(db)
+	balanceBefore := groupUser.Balance
 	var sendNotifyMsg tgbotapi.MessageConfig
 
 	// 根据运算符执行特定逻辑
 	switch operator {
 	case "+":
-		groupUser.Balance += updateBalance
-		db.Save(&groupUser)
-		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("已为【%s】中的用户【@%s】增加%.2f积分,积分余额为%.2f。", group.TgChatGroupTitle, groupUser.Username, updateBalance, groupUser.Balance))
-		sendNotifyMsg = tgbotapi.NewMessage(groupUser.TgUserId, fmt.Sprintf("【%s】管理员为您增加了%.2f积分,您的积分余额为%.2f。", group.TgChatGroupTitle, updateBalance, groupUser.Balance))
+		groupUser.Balance = groupUser.Balance.Add(updateBalance)
+		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("已为【%s】中的用户【@%s】增加%s积分,积分余额为%s。", group.TgChatGroupTitle, groupUser.Username, utils.FormatAmount(updateBalance), utils.FormatAmount(groupUser.Balance)))
+		sendNotifyMsg = tgbotapi.NewMessage(groupUser.TgUserId, fmt.Sprintf("【%s】管理员为您增加了%s积分,您的积分余额为%s。", group.TgChatGroupTitle, utils.FormatAmount(updateBalance), utils.FormatAmount(groupUser.Balance)))
 	case "-":
-		if groupUser.Balance < updateBalance {
-			sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("【%s】中的用户【@%s】积分余额为%.2f,小于您想扣除的积分，请留点积分吧。", group.TgChatGroupTitle, groupUser.Username, groupUser.Balance))
+		if groupUser.Balance.LessThan(updateBalance) {
+			sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("【%s】中的用户【@%s】积分余额为%s,小于您想扣除的积分，请留点积分吧。", group.TgChatGroupTitle, groupUser.Username, utils.FormatAmount(groupUser.Balance)))
 			_, err = sendMessage(bot, &sendMsg)
 			blockedOrKicked(err, chatId)
 			return
 		} else {
-			groupUser.Balance -= updateBalance
-			db.Save(&groupUser)
-			sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("已为【%s】中的用户【@%s】扣除%.2f积分,积分余额为%.2f。", group.TgChatGroupTitle, groupUser.Username, updateBalance, groupUser.Balance))
-			sendNotifyMsg = tgbotapi.NewMessage(groupUser.TgUserId, fmt.Sprintf("【%s】管理员扣除了您%.2f积分,您的积分余额为%.2f。", group.TgChatGroupTitle, updateBalance, groupUser.Balance))
+			groupUser.Balance = groupUser.Balance.Sub(updateBalance)
+			sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("已为【%s】中的用户【@%s】扣除%s积分,积分余额为%s。", group.TgChatGroupTitle, groupUser.Username, utils.FormatAmount(updateBalance), utils.FormatAmount(groupUser.Balance)))
+			sendNotifyMsg = tgbotapi.NewMessage(groupUser.TgUserId, fmt.Sprintf("【%s】管理员扣除了您%s积分,您的积分余额为%s。", group.TgChatGroupTitle, utils.FormatAmount(updateBalance), utils.FormatAmount(groupUser.Balance)))
 		}
 	case "=":
 		groupUser.Balance = updateBalance
-		db.Save(&groupUser)
-		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("已将【%s】中的用户【@%s】积分修改为%.2f。", group.TgChatGroupTitle, groupUser.Username, groupUser.Balance))
-		sendNotifyMsg = tgbotapi.NewMessage(groupUser.TgUserId, fmt.Sprintf("【%s】管理员将您的积分修改为%.2f。", group.TgChatGroupTitle, groupUser.Balance))
+		sendMsg = tgbotapi.NewMessage(chatId, fmt.Sprintf("已将【%s】中的用户【@%s】积分修改为%s。", group.TgChatGroupTitle, groupUser.Username, utils.FormatAmount(groupUser.Balance)))
+		sendNotifyMsg = tgbotapi.NewMessage(groupUser.TgUserId, fmt.Sprintf("【%s】管理员将您的积分修改为%s。", group.TgChatGroupTitle, utils.FormatAmount(groupUser.Balance)))
+	}
+
+	tx := db.Begin()
+	result := tx.Save(&groupUser)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": groupUser.Id,
+			"err":             result.Error,
+		}).Error("修改用户积分异常")
+		tx.Rollback()
+		return
+	}
+	err = addBalanceLedger(tx, groupUser, balanceBefore, enums.AdminAdjustLedger, &model.BalanceLedger{OperatorTgUserId: tgUserId})
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+	balanceAdjustRecord := &model.BalanceAdjustRecord{
+		ChatGroupId:      groupUser.ChatGroupId,
+		ChatGroupUserId:  groupUser.Id,
+		TgUserId:         groupUser.TgUserId,
+		OperatorTgUserId: tgUserId,
+		Operator:         operator,
+		Amount:           updateBalance,
+		Delta:            groupUser.Balance.Sub(balanceBefore),
+		CreateTime:       time.Now().Format("2006-01-02 15:04:05"),
+	}
+	err = balanceAdjustRecord.Create(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": groupUser.Id,
+			"err":             err,
+		}).Error("保存积分调整记录异常")
+		tx.Rollback()
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("修改用户积分事务提交异常")
+		tx.Rollback()
+		return
 	}
 
 	_, err = sendMessage(bot, &sendMsg)
@@ -524,9 +638,30 @@ func queryUser(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCa
 		}).Error("群id+用户名查找群成员异常")
 	} else {
 		// 查询到记录
-		msgConfig := tgbotapi.NewMessage(chatId, fmt.Sprintf("用户ID:%v\n用户名称:%s\n积分余额:%.2f", groupUser.Id, groupUser.Username, groupUser.Balance))
+		msgConfig := tgbotapi.NewMessage(chatId, fmt.Sprintf("用户ID:%v\n用户名称:%s\n积分余额:%s", groupUser.Id, groupUser.Username, utils.FormatAmount(groupUser.Balance)))
 		msgConfig.ReplyToMessageID = messageId
-		_, err := sendMessage(bot, &msgConfig)
+
+		callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+			"chatGroupId":     groupUser.ChatGroupId,
+			"chatGroupUserId": groupUser.Id,
+		})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupUserId": groupUser.Id,
+				"err":             err,
+			}).Error("内联键盘回调参数存入redis异常")
+			return
+		}
+		callbackDataQueryString := utils.MapToQueryString(map[string]string{
+			"callbackDataKey": callbackDataKey,
+		})
+		newInlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📒积分流水", fmt.Sprintf("%s%s", enums.CallbackChatGroupUserLedger.Value, callbackDataQueryString)),
+			),
+		)
+		msgConfig.ReplyMarkup = newInlineKeyboardMarkup
+		_, err = sendMessage(bot, &msgConfig)
 		blockedOrKicked(err, chatId)
 		// 删除bot与当前对话人的cache
 		redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
@@ -0,0 +1,265 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+// profileStat 个人下注统计
+type profileStat struct {
+	BetCount          int
+	WinCount          int
+	Turnover          decimal.Decimal
+	NetProfit         decimal.Decimal
+	BiggestWin        decimal.Decimal
+	FavouriteBetType  string
+	LongestWinStreak  int
+	LongestLossStreak int
+}
+
+// calcProfileStat 按下注时间顺序统计已结算下注 连胜/连败按结算结果连续计算
+func calcProfileStat(betRecords []*model.QuickThereBetRecord) *profileStat {
+	stat := &profileStat{
+		Turnover:   decimal.Zero,
+		NetProfit:  decimal.Zero,
+		BiggestWin: decimal.Zero,
+	}
+	betTypeCounts := make(map[string]int)
+	winStreak, lossStreak := 0, 0
+	for _, betRecord := range betRecords {
+		stat.BetCount++
+		stat.Turnover = stat.Turnover.Add(betRecord.BetAmount)
+		netProfit := betNetProfit(betRecord)
+		stat.NetProfit = stat.NetProfit.Add(netProfit)
+
+		betTypeCounts[betRecord.BetType]++
+		if betTypeCounts[betRecord.BetType] > betTypeCounts[stat.FavouriteBetType] {
+			stat.FavouriteBetType = betRecord.BetType
+		}
+
+		if betRecord.BetResultType != nil && *betRecord.BetResultType == enums.Win.Value {
+			stat.WinCount++
+			if netProfit.GreaterThan(stat.BiggestWin) {
+				stat.BiggestWin = netProfit
+			}
+			winStreak++
+			lossStreak = 0
+		} else {
+			lossStreak++
+			winStreak = 0
+		}
+		if winStreak > stat.LongestWinStreak {
+			stat.LongestWinStreak = winStreak
+		}
+		if lossStreak > stat.LongestLossStreak {
+			stat.LongestLossStreak = lossStreak
+		}
+	}
+	return stat
+}
+
+// handleProfileCommand 查看个人统计 默认展示本群数据
+func handleProfileCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	fromUser := message.From
+	messageId := message.MessageID
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	text, inlineKeyboardMarkup, err := buildProfileMessage(chatGroup, fromUser.ID, false)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "您还未注册，使用 /register 进行注册。")
+		return
+	} else if err != nil {
+		return
+	}
+
+	msgConfig := tgbotapi.NewMessage(tgChatGroupId, text)
+	msgConfig.ReplyToMessageID = messageId
+	msgConfig.ReplyMarkup = inlineKeyboardMarkup
+	sentMsg, err := sendMessage(bot, &msgConfig)
+	if err != nil {
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	}
+	go func(messageID int) {
+		time.Sleep(1 * time.Minute)
+		deleteMsg := tgbotapi.NewDeleteMessage(tgChatGroupId, messageID)
+		_, err := bot.Request(deleteMsg)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"err": err,
+			}).Error("删除消息异常")
+		}
+	}(sentMsg.MessageID)
+}
+
+// profileScopeCallBack 切换本群/全部群统计 仅本人可操作
+func profileScopeCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	callBackData, err := queryCallBackData(query, enums.CallbackProfileScope)
+	if err != nil {
+		answerCallbackQuery(bot, query, "统计已过期,请重新发送 /profile", true)
+		return
+	}
+
+	tgUserId, err := strconv.ParseInt(callBackData["tgUserId"], 10, 64)
+	if err != nil || tgUserId != query.From.ID {
+		answerCallbackQuery(bot, query, "仅本人可切换!", true)
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, callBackData["chatGroupId"])
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": callBackData["chatGroupId"],
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	text, inlineKeyboardMarkup, err := buildProfileMessage(chatGroup, tgUserId, callBackData["allGroups"] == "1")
+	if err != nil {
+		answerCallbackQuery(bot, query, "统计查询失败,请稍后再试!", true)
+		return
+	}
+
+	answerCallbackQuery(bot, query, "", false)
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	editMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &editMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// buildProfileMessage 组装个人统计文本 allGroups 为 true 时汇总用户加入的所有群
+func buildProfileMessage(chatGroup *model.ChatGroup, tgUserId int64, allGroups bool) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    tgUserId,
+		ChatGroupId: chatGroup.Id,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logrus.WithFields(logrus.Fields{
+				"TgUserId":    tgUserId,
+				"ChatGroupId": chatGroup.Id,
+				"err":         err,
+			}).Error("群用户查询异常")
+		}
+		return "", nil, err
+	}
+
+	chatGroupUsers := []*model.ChatGroupUser{chatGroupUser}
+	if allGroups {
+		chatGroupUsers, err = chatGroupUserQuery.ListByTgUserId(db)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"TgUserId": tgUserId,
+				"err":      err,
+			}).Error("查询用户加入的群异常")
+			return "", nil, err
+		}
+	}
+
+	now := time.Now()
+	var chatGroupUserIds []string
+	registerTime := chatGroupUser.CreateTime
+	signInStreak := 0
+	for _, groupUser := range chatGroupUsers {
+		chatGroupUserIds = append(chatGroupUserIds, groupUser.Id)
+		if groupUser.CreateTime < registerTime {
+			registerTime = groupUser.CreateTime
+		}
+		if streak := currentSignInStreak(groupUser, now); streak > signInStreak {
+			signInStreak = streak
+		}
+	}
+
+	betRecordQuery := &model.QuickThereBetRecord{SettleStatus: enums.Settled.Value}
+	betRecords, err := betRecordQuery.ListByChatGroupUserIdsAndSettleStatus(db, chatGroupUserIds)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId": tgUserId,
+			"err":      err,
+		}).Error("查询下注记录异常")
+		return "", nil, err
+	}
+	stat := calcProfileStat(betRecords)
+
+	var text strings.Builder
+	if allGroups {
+		text.WriteString(fmt.Sprintf("%s 的个人统计(全部%d个群)\n", rankUserName(chatGroupUser), len(chatGroupUsers)))
+	} else {
+		text.WriteString(fmt.Sprintf("%s 的个人统计(%s)\n", rankUserName(chatGroupUser), chatGroup.TgChatGroupTitle))
+	}
+	winRate := "-"
+	favouriteBetType := "-"
+	if stat.BetCount > 0 {
+		winRate = fmt.Sprintf("%.2f%%", float64(stat.WinCount)*100/float64(stat.BetCount))
+		betType, _ := enums.GetGameLotteryType(stat.FavouriteBetType)
+		favouriteBetType = betType.Name
+	}
+	text.WriteString(fmt.Sprintf("总下注: %d次丨累计流水: %s\n", stat.BetCount, utils.FormatAmount(stat.Turnover)))
+	text.WriteString(fmt.Sprintf("胜率: %s(%d胜%d负)\n", winRate, stat.WinCount, stat.BetCount-stat.WinCount))
+	text.WriteString(fmt.Sprintf("净盈亏: %s\n", utils.FormatSignedAmount(stat.NetProfit)))
+	text.WriteString(fmt.Sprintf("最爱竞猜: %s\n", favouriteBetType))
+	text.WriteString(fmt.Sprintf("最长连胜: %d丨最长连败: %d\n", stat.LongestWinStreak, stat.LongestLossStreak))
+	text.WriteString(fmt.Sprintf("单笔最大赢: %s\n", utils.FormatAmount(stat.BiggestWin)))
+	text.WriteString(fmt.Sprintf("注册日期: %s\n", strings.Split(registerTime, " ")[0]))
+	text.WriteString(fmt.Sprintf("连续签到: %d天", signInStreak))
+
+	var inlineKeyboardButtons []tgbotapi.InlineKeyboardButton
+	for _, scope := range []struct {
+		name      string
+		allGroups bool
+	}{{"📍本群", false}, {"🌐全部群", true}} {
+		allGroupsValue := "0"
+		if scope.allGroups {
+			allGroupsValue = "1"
+		}
+		callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+			"chatGroupId": chatGroup.Id,
+			"tgUserId":    strconv.FormatInt(tgUserId, 10),
+			"allGroups":   allGroupsValue,
+		})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroup.Id,
+				"err":         err,
+			}).Error("内联键盘回调参数存入redis异常")
+			return "", nil, err
+		}
+		callbackDataQueryString := utils.MapToQueryString(map[string]string{
+			"callbackDataKey": callbackDataKey,
+		})
+
+		buttonDataText := scope.name
+		if scope.allGroups == allGroups {
+			buttonDataText = fmt.Sprintf("%s✅", buttonDataText)
+		}
+		inlineKeyboardButtons = append(inlineKeyboardButtons,
+			tgbotapi.NewInlineKeyboardButtonData(buttonDataText, fmt.Sprintf("%s%s", enums.CallbackProfileScope.Value, callbackDataQueryString)))
+	}
+
+	inlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(inlineKeyboardButtons)
+	return text.String(), &inlineKeyboardMarkup, nil
+}
@@ -1,17 +1,37 @@
 package bot
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"math"
+	"strings"
 	"telegram-dice-bot/internal/enums"
 	"telegram-dice-bot/internal/model"
 	"telegram-dice-bot/internal/utils"
 	"time"
 )
 
+const (
+	QuickThereMinDiceCount = 2
+	QuickThereMaxDiceCount = 5
+	QuickThereHouseEdge    = 0.03 // 倍率建议使用的抽水比例
+
+	// RedisIssueQuickThereRuleKey 期号开始时的快三规则快照
+	RedisIssueQuickThereRuleKey = "ISSUE_QUICK_THERE_RULE:CHAT_GROUP_ID:%s:ISSUE_NUMBER:%s"
+	IssueQuickThereRuleExpire   = 7 * 24 * time.Hour
+)
+
+// quickThereIssueRule 快三规则快照 骰子数量、大小分界修改后下一期生效,本期开奖及结算以快照为准
+type quickThereIssueRule struct {
+	DiceCount     int `json:"diceCount"`
+	SmallMaxTotal int `json:"smallMaxTotal"`
+}
+
 func quickThereTask(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber string) (nextIssueNumber string, err error) {
 	// 执行任务前对群组校验 如果只剩1个人那必然是自己
 	chatMembersLen, err := bot.GetChatMembersCount(tgbotapi.ChatMemberCountConfig{
@@ -35,22 +55,57 @@ func quickThereTask(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber st
 		return "", err
 	}
 
+	// 已封盘 本期下注看板不再更新
+	freezeBetBoard(bot, group, issueNumber)
+
 	currentTime := time.Now().Format("2006-01-02 15:04:05")
 
-	diceValues, err := rollDice(bot, group.TgChatGroupId, 3)
+	// 开奖来源修改后下一期即生效
+	latestGroup, err := model.QueryChatGroupById(db, group.Id)
+	if err == nil {
+		group.DrawSourceType = latestGroup.DrawSourceType
+	}
+
+	// 查询此群的快三配置 骰子数量、大小分界使用期号开始时的快照 修改后下一期才生效
+	quickThereConfig, err := model.QueryQuickThereConfigByChatGroupId(db, group.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ChatGroupId": group.Id,
+			"err":         err,
+		}).Error("查询群的快三配置异常")
+		return "", err
+	}
+	issueRule := loadQuickThereIssueRule(group, issueNumber, quickThereConfig)
+
+	drawResult, err := getDrawSource(group).Draw(bot, group, issueNumber, issueRule.DiceCount)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId":    group.Id,
+			"drawSourceType": group.DrawSourceType,
+			"err":            err,
+		}).Error("开奖异常")
+		blockedOrKicked(err, group.TgChatGroupId)
+		return "", err
+	}
+	err = getDrawSource(group).Announce(bot, group, issueNumber, drawResult)
 	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId":    group.Id,
+			"drawSourceType": group.DrawSourceType,
+			"err":            err,
+		}).Error("公布开奖点数异常")
 		blockedOrKicked(err, group.TgChatGroupId)
 		return "", err
 	}
+	diceValues := drawResult.Values
 	count := sumDiceValues(diceValues)
-	singleOrDouble, bigOrSmall := determineResult(count)
+	singleOrDouble, bigOrSmall := determineResult(count, issueRule.SmallMaxTotal)
 
-	time.Sleep(3 * time.Second)
 	triplet := 0
-	if diceValues[0] == diceValues[1] && diceValues[1] == diceValues[2] {
+	if isAllSameDiceValues(diceValues) {
 		triplet = 1
 	}
-	message, err := formatMessage(diceValues[0], diceValues[1], diceValues[2], count, singleOrDouble, bigOrSmall, triplet, issueNumber)
+	message, err := formatMessage(diceValues, count, singleOrDouble, bigOrSmall, triplet, issueNumber)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"issueNumber": issueNumber,
@@ -83,17 +138,20 @@ func quickThereTask(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber st
 
 	// 插入快三开奖表
 	lotteryRecord := &model.QuickThereLotteryRecord{
-		Id:           id,
-		ChatGroupId:  group.Id,
-		IssueNumber:  issueNumber,
-		ValueA:       diceValues[0],
-		ValueB:       diceValues[1],
-		ValueC:       diceValues[2],
-		Total:        count,
-		SingleDouble: singleOrDouble,
-		BigSmall:     bigOrSmall,
-		Triplet:      triplet,
-		CreateTime:   currentTime,
+		Id:             id,
+		ChatGroupId:    group.Id,
+		IssueNumber:    issueNumber,
+		ValueA:         diceValueAt(diceValues, 0),
+		ValueB:         diceValueAt(diceValues, 1),
+		ValueC:         diceValueAt(diceValues, 2),
+		DiceValues:     utils.JoinInts(diceValues, ","),
+		Total:          count,
+		SingleDouble:   singleOrDouble,
+		BigSmall:       bigOrSmall,
+		Triplet:        triplet,
+		TgChatGroupId:  group.TgChatGroupId,
+		DrawMessageIds: utils.JoinInts(drawResult.MessageIds, ","),
+		CreateTime:     currentTime,
 	}
 
 	err = lotteryRecord.Create(tx)
@@ -106,18 +164,52 @@ func quickThereTask(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber st
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		// 提交事务时出现异常，回滚事务
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": group.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("开奖记录事务提交异常")
 		tx.Rollback()
+		return "", err
 	}
+	redisDB.Del(redisDB.Context(), fmt.Sprintf(RedisIssueQuickThereRuleKey, group.Id, issueNumber))
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("开奖历史", enums.CallbackLotteryHistory.Value),
+			tgbotapi.NewInlineKeyboardButtonData("走势图", enums.CallbackLotteryTrend.Value),
 		),
 	)
 
-	msg := tgbotapi.NewMessage(group.TgChatGroupId, message)
-	msg.ReplyMarkup = keyboard
-	_, err = sendMessage(bot, &msg)
+	// 开奖卡片图片 原文本作为图片说明 绘制或发送失败时仍发送文本
+	textMsg := tgbotapi.NewMessage(group.TgChatGroupId, message)
+	textMsg.ReplyMarkup = keyboard
+	cardBytes, titleDrawn, err := renderResultCard(group.TgChatGroupTitle, issueNumber, diceValues, count, bigOrSmall, singleOrDouble, triplet)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": group.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Warn("开奖卡片绘制异常")
+		_, err = sendMessage(bot, &textMsg)
+	} else {
+		photoMsg := tgbotapi.NewPhoto(group.TgChatGroupId, tgbotapi.FileBytes{Name: fmt.Sprintf("%s.png", issueNumber), Bytes: cardBytes})
+		photoMsg.Caption = message
+		// 卡片字体缺字未绘制群名称时 在图片说明中补充群名称
+		if !titleDrawn && strings.TrimSpace(group.TgChatGroupTitle) != "" {
+			photoMsg.Caption = fmt.Sprintf("%s\n%s", strings.TrimSpace(group.TgChatGroupTitle), message)
+		}
+		photoMsg.ReplyMarkup = keyboard
+		_, err = sendMessage(bot, photoMsg)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": group.Id,
+				"issueNumber": issueNumber,
+				"err":         err,
+			}).Warn("开奖卡片发送异常 改为发送文本")
+			_, err = sendMessage(bot, &textMsg)
+		}
+	}
 	if err != nil {
 		blockedOrKicked(err, group.TgChatGroupId)
 		return "", err
@@ -126,6 +218,7 @@ func quickThereTask(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber st
 	nextIssueNumber = time.Now().Format("20060102150405")
 
 	lotteryDrawTipMsgConfig := tgbotapi.NewMessage(group.TgChatGroupId, fmt.Sprintf("第%s期 %d分钟后开奖", nextIssueNumber, group.GameDrawCycle))
+	lotteryDrawTipMsgConfig.ReplyMarkup = buildBetChipInlineKeyboardMarkup(nextIssueNumber)
 	_, err = sendMessage(bot, &lotteryDrawTipMsgConfig)
 	if err != nil {
 		blockedOrKicked(err, group.TgChatGroupId)
@@ -138,6 +231,8 @@ func quickThereTask(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber st
 		logrus.WithField("err", err).Warn("存储新期号和对话ID异常")
 	}
 
+	issueStart(bot, group, nextIssueNumber)
+
 	// 遍历下注记录，计算竞猜结果
 	go func() {
 		// 获取所有参与竞猜的用户下注记录
@@ -154,56 +249,184 @@ func quickThereTask(bot *tgbotapi.BotAPI, group *model.ChatGroup, issueNumber st
 			}).Error("获取用户下注记录异常")
 			return
 		}
-		// 查询此群的快三配置
-		quickThereConfig, err := model.QueryQuickThereConfigByChatGroupId(db, group.Id)
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"ChatGroupId": group.Id,
-				"err":         err,
-			}).Error("查询群的快三配置异常")
-			return
-		}
-
 		for _, betRecord := range quickThereBetRecords {
 			// 更新用户余额
 			updateBalanceByQuickThere(bot, quickThereConfig, betRecord, lotteryRecord)
 		}
+
+		// 全部结算后在群内发送本期结算汇总
+		if len(quickThereBetRecords) > 0 {
+			sendSettleSummary(bot, group.Id, issueNumber, lotteryRecord)
+		}
 	}()
 
 	return nextIssueNumber, nil
 }
 
-// rollDice 模拟多次掷骰子。
-func rollDice(bot *tgbotapi.BotAPI, chatID int64, numDice int) ([]int, error) {
-	diceValues := make([]int, numDice)
-	diceConfig := tgbotapi.NewDiceWithEmoji(chatID, "🎲")
+func sumDiceValues(diceValues []int) int {
+	sum := 0
+	for _, value := range diceValues {
+		sum += value
+	}
+	return sum
+}
 
-	for i := 0; i < numDice; i++ {
-		diceMsg, err := bot.Send(diceConfig)
-		if err != nil {
-			logrus.WithField("err", err).Error("发送骰子消息异常")
-			return nil, err
+// isAllSameDiceValues 所有骰子点数相同即为豹子
+func isAllSameDiceValues(diceValues []int) bool {
+	for _, value := range diceValues {
+		if value != diceValues[0] {
+			return false
+		}
+	}
+	return len(diceValues) > 1
+}
+
+func diceValueAt(diceValues []int, index int) int {
+	if index < len(diceValues) {
+		return diceValues[index]
+	}
+	return 0
+}
+
+// quickThereLotteryDiceValues 获取开奖记录的全部骰子点数 兼容只记录了ValueA/B/C的历史数据
+func quickThereLotteryDiceValues(lotteryRecord *model.QuickThereLotteryRecord) []int {
+	if diceValues := utils.SplitInts(lotteryRecord.DiceValues, ","); len(diceValues) > 0 {
+		return diceValues
+	}
+	return []int{lotteryRecord.ValueA, lotteryRecord.ValueB, lotteryRecord.ValueC}
+}
+
+// quickThereProbability 快三各竞猜类型的中奖概率
+type quickThereProbability struct {
+	Big     float64
+	Small   float64
+	Single  float64
+	Double  float64
+	Triplet float64
+}
+
+// calcQuickThereProbability 按骰子数量和大小分界精确计算各竞猜类型的中奖概率
+func calcQuickThereProbability(diceCount int, smallMaxTotal int) quickThereProbability {
+	// totalCounts[总点数] = 出现该总点数的组合数
+	totalCounts := map[int]int{0: 1}
+	for i := 0; i < diceCount; i++ {
+		nextTotalCounts := make(map[int]int)
+		for total, n := range totalCounts {
+			for value := 1; value <= 6; value++ {
+				nextTotalCounts[total+value] += n
+			}
 		}
-		diceValues[i] = diceMsg.Dice.Value
+		totalCounts = nextTotalCounts
 	}
 
-	return diceValues, nil
+	outcomes := math.Pow(6, float64(diceCount))
+	var probability quickThereProbability
+	for total, n := range totalCounts {
+		p := float64(n) / outcomes
+		if total <= smallMaxTotal {
+			probability.Small += p
+		} else {
+			probability.Big += p
+		}
+		if total%2 == 1 {
+			probability.Single += p
+		} else {
+			probability.Double += p
+		}
+	}
+	probability.Triplet = 6 / outcomes
+	return probability
 }
 
-func sumDiceValues(diceValues []int) int {
-	sum := 0
-	for _, value := range diceValues {
-		sum += value
+// buildQuickThereOddsSuggestion 根据真实概率给出倍率建议(倍率为含本金的返还倍数)
+func buildQuickThereOddsSuggestion(quickThereConfig *model.QuickThereConfig) string {
+	probability := calcQuickThereProbability(quickThereConfig.DiceCount, quickThereConfig.SmallMaxTotal)
+
+	// 简易倍率同时作用于大/小/单/双 以其中概率最高者计算
+	maxSimpleProbability := math.Max(math.Max(probability.Big, probability.Small), math.Max(probability.Single, probability.Double))
+	suggestSimpleOdds := math.Floor((1-QuickThereHouseEdge)/maxSimpleProbability*100) / 100
+	suggestTripletOdds := math.Floor((1-QuickThereHouseEdge)/probability.Triplet*100) / 100
+
+	return fmt.Sprintf("当前玩法: %d颗骰子 总点数%d-%d 小于等于%d为小\n"+
+		"中奖概率(公平倍率):\n"+
+		"大 %.2f%%(%.2f倍)丨小 %.2f%%(%.2f倍)\n"+
+		"单 %.2f%%(%.2f倍)丨双 %.2f%%(%.2f倍)\n"+
+		"豹子 %.2f%%(%.2f倍)\n"+
+		"建议倍率(抽水%.0f%%): 简易%.2f倍丨豹子%.2f倍\n"+
+		"当前倍率: 简易%s倍丨豹子%s倍",
+		quickThereConfig.DiceCount, quickThereConfig.DiceCount, quickThereConfig.DiceCount*6, quickThereConfig.SmallMaxTotal,
+		probability.Big*100, 1/probability.Big, probability.Small*100, 1/probability.Small,
+		probability.Single*100, 1/probability.Single, probability.Double*100, 1/probability.Double,
+		probability.Triplet*100, 1/probability.Triplet,
+		QuickThereHouseEdge*100, suggestSimpleOdds, suggestTripletOdds,
+		utils.FormatAmount(quickThereConfig.SimpleOdds), utils.FormatAmount(quickThereConfig.TripletOdds))
+}
+
+// snapshotQuickThereIssueRule 期号开始时保存快三规则快照 重启后恢复的期号保留原快照
+func snapshotQuickThereIssueRule(group *model.ChatGroup, issueNumber string) {
+	quickThereConfig, err := model.QueryQuickThereConfigByChatGroupId(db, group.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ChatGroupId": group.Id,
+			"err":         err,
+		}).Error("查询群的快三配置异常")
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(&quickThereIssueRule{
+		DiceCount:     quickThereConfig.DiceCount,
+		SmallMaxTotal: quickThereConfig.SmallMaxTotal,
+	})
+	redisKey := fmt.Sprintf(RedisIssueQuickThereRuleKey, group.Id, issueNumber)
+	err = redisDB.SetNX(redisDB.Context(), redisKey, string(jsonBytes), IssueQuickThereRuleExpire).Err()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("保存快三规则快照异常")
 	}
-	return sum
+}
+
+// loadQuickThereIssueRule 读取本期快三规则快照 快照缺失时使用当前配置
+func loadQuickThereIssueRule(group *model.ChatGroup, issueNumber string, quickThereConfig *model.QuickThereConfig) *quickThereIssueRule {
+	issueRule := &quickThereIssueRule{
+		DiceCount:     quickThereConfig.DiceCount,
+		SmallMaxTotal: quickThereConfig.SmallMaxTotal,
+	}
+
+	redisKey := fmt.Sprintf(RedisIssueQuickThereRuleKey, group.Id, issueNumber)
+	ruleJson, err := redisDB.Get(redisDB.Context(), redisKey).Result()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Warn("快三规则快照不存在 使用当前配置")
+		return issueRule
+	}
+	var snapshot quickThereIssueRule
+	err = json.Unmarshal([]byte(ruleJson), &snapshot)
+	if err != nil || snapshot.DiceCount < QuickThereMinDiceCount || snapshot.DiceCount > QuickThereMaxDiceCount {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"ruleJson": ruleJson,
+			"err":      err,
+		}).Warn("快三规则快照异常 使用当前配置")
+		return issueRule
+	}
+	return &snapshot
+}
+
+// defaultSmallMaxTotal 骰子数量对应的默认大小分界(总点数期望值向下取整)
+func defaultSmallMaxTotal(diceCount int) int {
+	return diceCount * 7 / 2
 }
 
 // determineResult 根据骰子值的总和确定结果（单/双，大/小）。
-func determineResult(count int) (string, string) {
+func determineResult(count int, smallMaxTotal int) (string, string) {
 	var singleOrDouble string
 	var bigOrSmall string
 
-	if count <= 10 {
+	if count <= smallMaxTotal {
 		bigOrSmall = enums.Small.Value
 	} else {
 		bigOrSmall = enums.Big.Value
@@ -218,7 +441,7 @@ func determineResult(count int) (string, string) {
 	return singleOrDouble, bigOrSmall
 }
 
-func formatMessage(valueA int, valueB int, valueC int, count int, singleOrDouble, bigOrSmall string, triplet int, issueNumber string) (string, error) {
+func formatMessage(diceValues []int, count int, singleOrDouble, bigOrSmall string, triplet int, issueNumber string) (string, error) {
 	tripletStr := ""
 	if triplet == 1 {
 		tripletStr = "【豹子】"
@@ -240,12 +463,12 @@ func formatMessage(valueA int, valueB int, valueC int, count int, singleOrDouble
 	}
 
 	return fmt.Sprintf(""+
-		"点数: %d %d %d %s\n"+
+		"点数: %s %s\n"+
 		"总点数: %d \n"+
 		"[单/双]: %s \n"+
 		"[大/小]: %s \n"+
 		"期号: %s ",
-		valueA, valueB, valueC, tripletStr,
+		utils.JoinInts(diceValues, " "), tripletStr,
 		count,
 		singleOrDoubleType.Name,
 		bigOrSmallType.Name,
@@ -257,8 +480,8 @@ func formatMessage(valueA int, valueB int, valueC int, count int, singleOrDouble
 func updateBalanceByQuickThere(bot *tgbotapi.BotAPI, quickThereConfig *model.QuickThereConfig, betRecord *model.QuickThereBetRecord, lotteryRecord *model.QuickThereLotteryRecord) {
 
 	// 查找该用户信息
-	chatGroupUser := &model.ChatGroupUser{Id: betRecord.ChatGroupUserId}
-	chatGroupUser, err := chatGroupUser.QueryById(db)
+	chatGroupUserQuery := &model.ChatGroupUser{Id: betRecord.ChatGroupUserId}
+	chatGroupUser, err := chatGroupUserQuery.QueryById(db)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		logrus.WithFields(logrus.Fields{
 			"ChatGroupUserId": betRecord.ChatGroupUserId,
@@ -295,22 +518,36 @@ func updateBalanceByQuickThere(bot *tgbotapi.BotAPI, quickThereConfig *model.Qui
 
 	tx := db.Begin()
 
+	// 加锁后在事务内重新查询余额 避免覆盖结算期间新一期下注的扣款 流水的变动前余额也以此为准
+	chatGroupUser, err = chatGroupUserQuery.QueryById(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ChatGroupUserId": betRecord.ChatGroupUserId,
+			"err":             err,
+		}).Error("查询该用户信息异常")
+		tx.Rollback()
+		return
+	}
+
+	balanceBefore := chatGroupUser.Balance
 	var betResultTypeName string
 	if betRecord.BetType == lotteryRecord.SingleDouble ||
 		betRecord.BetType == lotteryRecord.BigSmall {
-		betRecord.BetResultAmount = fmt.Sprintf("+%.2f", betRecord.BetAmount*quickThereConfig.SimpleOdds)
-		chatGroupUser.Balance += betRecord.BetAmount * quickThereConfig.SimpleOdds
+		payout := utils.RoundPayout(betRecord.BetAmount.Mul(quickThereConfig.SimpleOdds))
+		betRecord.BetResultAmount = decimal.NewNullDecimal(payout)
+		chatGroupUser.Balance = chatGroupUser.Balance.Add(payout)
 		betResultType := 1
 		betResultTypeName = "赢"
 		betRecord.BetResultType = &betResultType
 	} else if betRecord.BetType == enums.Triplet.Value && lotteryRecord.Triplet == 1 {
-		betRecord.BetResultAmount = fmt.Sprintf("+%.2f", betRecord.BetAmount*quickThereConfig.SimpleOdds)
-		chatGroupUser.Balance += betRecord.BetAmount * quickThereConfig.TripletOdds
+		payout := utils.RoundPayout(betRecord.BetAmount.Mul(quickThereConfig.TripletOdds))
+		betRecord.BetResultAmount = decimal.NewNullDecimal(payout)
+		chatGroupUser.Balance = chatGroupUser.Balance.Add(payout)
 		betResultType := 1
 		betResultTypeName = "赢"
 		betRecord.BetResultType = &betResultType
 	} else {
-		betRecord.BetResultAmount = fmt.Sprintf("-%.2f", betRecord.BetAmount)
+		betRecord.BetResultAmount = decimal.NewNullDecimal(betRecord.BetAmount.Neg())
 		betResultType := 0
 		betResultTypeName = "输"
 		betRecord.BetResultType = &betResultType
@@ -333,23 +570,43 @@ func updateBalanceByQuickThere(bot *tgbotapi.BotAPI, quickThereConfig *model.Qui
 		return
 	}
 
+	// 中奖时记录积分流水
+	if !chatGroupUser.Balance.Equal(balanceBefore) {
+		err = addBalanceLedger(tx, chatGroupUser, balanceBefore, enums.BetSettleLedger, &model.BalanceLedger{
+			RefId:       betRecord.Id,
+			IssueNumber: betRecord.IssueNumber,
+		})
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		// 提交事务时出现异常，回滚事务
+		logrus.WithFields(logrus.Fields{
+			"betRecordId": betRecord.Id,
+			"err":         err,
+		}).Error("结算事务提交异常")
 		tx.Rollback()
+		return
 	}
 
+	// 被邀请人流水达标时向邀请人发放奖励(异步执行,避免与当前用户锁嵌套)
+	go checkReferralReward(bot, ChatGroup, chatGroupUser)
+
 	lotteryType, _ := enums.GetGameLotteryType(betRecord.BetType)
 
 	// 消息提醒
 	sendMsg := tgbotapi.NewMessage(chatGroupUser.TgUserId,
-		fmt.Sprintf("您在【%s】第%s期下注%v积分猜【%s】,竞猜结果为【%s】,积分余额%.2f。",
+		fmt.Sprintf("您在【%s】第%s期下注%s积分猜【%s】,竞猜结果为【%s】,积分余额%s。",
 			ChatGroup.TgChatGroupTitle,
 			betRecord.IssueNumber,
-			betRecord.BetAmount,
+			utils.FormatAmount(betRecord.BetAmount),
 			lotteryType.Name,
 			betResultTypeName,
-			chatGroupUser.Balance))
+			utils.FormatAmount(chatGroupUser.Balance)))
 	_, err = sendMessage(bot, &sendMsg)
 	blockedOrKicked(err, chatGroupUser.TgUserId)
 	return
@@ -0,0 +1,326 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	RedisRankCacheKey = "RANK_CACHE:CHAT_GROUP_ID:%s:RANK_TYPE:%s"
+	RankCacheExpire   = 5 * time.Minute
+	RankLimit         = 10
+)
+
+var rankMedals = []string{"🥇", "🥈", "🥉"}
+
+// rankEntry 排行榜单行
+type rankEntry struct {
+	Name  string          `json:"name"`
+	Value decimal.Decimal `json:"value"`
+}
+
+// rankCache 排行榜缓存 过期后下次查询时重新统计
+type rankCache struct {
+	Entries    []*rankEntry `json:"entries"`
+	UpdateTime string       `json:"update_time"`
+}
+
+// betNetProfit 单笔已结算下注的净盈亏 赢为派彩减下注积分,输为负的下注积分
+func betNetProfit(betRecord *model.QuickThereBetRecord) decimal.Decimal {
+	if !betRecord.BetResultAmount.Valid {
+		return decimal.Zero
+	}
+	if betRecord.BetResultType != nil && *betRecord.BetResultType == enums.Win.Value {
+		return betRecord.BetResultAmount.Decimal.Sub(betRecord.BetAmount)
+	}
+	return betRecord.BetResultAmount.Decimal
+}
+
+// handleRankCommand 查看排行榜 默认展示富豪榜
+func handleRankCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	text, inlineKeyboardMarkup, err := buildRankMessage(chatGroup, enums.BalanceRank)
+	if err != nil {
+		return
+	}
+
+	msgConfig := tgbotapi.NewMessage(tgChatGroupId, text)
+	msgConfig.ReplyToMessageID = message.MessageID
+	msgConfig.ReplyMarkup = inlineKeyboardMarkup
+	sentMsg, err := sendMessage(bot, &msgConfig)
+	if err != nil {
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	}
+	go func(messageID int) {
+		time.Sleep(1 * time.Minute)
+		deleteMsg := tgbotapi.NewDeleteMessage(tgChatGroupId, messageID)
+		_, err := bot.Request(deleteMsg)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"err": err,
+			}).Error("删除消息异常")
+		}
+	}(sentMsg.MessageID)
+}
+
+// rankTabCallBack 切换排行榜
+func rankTabCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	callBackData, err := queryCallBackData(query, enums.CallbackRankTab)
+	if err != nil {
+		answerCallbackQuery(bot, query, "排行榜已过期,请重新发送 /rank", true)
+		return
+	}
+
+	rankType, b := enums.GetRankType(callBackData["rankType"])
+	if !b {
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, callBackData["chatGroupId"])
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": callBackData["chatGroupId"],
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	text, inlineKeyboardMarkup, err := buildRankMessage(chatGroup, rankType)
+	if err != nil {
+		answerCallbackQuery(bot, query, "排行榜查询失败,请稍后再试!", true)
+		return
+	}
+
+	answerCallbackQuery(bot, query, "", false)
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	editMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &editMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// buildRankMessage 组装排行榜文本及切换按钮
+func buildRankMessage(chatGroup *model.ChatGroup, rankType enums.RankType) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	cache, err := queryRankCache(chatGroup.Id, rankType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("【%s】%s Top%d\n", chatGroup.TgChatGroupTitle, rankType.Name, RankLimit))
+	if len(cache.Entries) == 0 {
+		text.WriteString("暂无数据\n")
+	}
+	for i, entry := range cache.Entries {
+		rankNo := fmt.Sprintf("%d.", i+1)
+		if i < len(rankMedals) {
+			rankNo = rankMedals[i]
+		}
+		value := utils.FormatAmount(entry.Value)
+		if rankType == enums.TodayProfitRank {
+			value = utils.FormatSignedAmount(entry.Value)
+		}
+		text.WriteString(fmt.Sprintf("%s %s  %s\n", rankNo, entry.Name, value))
+	}
+	text.WriteString(fmt.Sprintf("\n更新于 %s,每%d分钟刷新", cache.UpdateTime, int(RankCacheExpire.Minutes())))
+
+	var inlineKeyboardButtons []tgbotapi.InlineKeyboardButton
+	for _, value := range []enums.RankType{enums.BalanceRank, enums.TodayProfitRank, enums.WeekTurnoverRank, enums.BiggestWinRank} {
+		callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+			"chatGroupId": chatGroup.Id,
+			"rankType":    value.Value,
+		})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroup.Id,
+				"rankType":    value.Value,
+				"err":         err,
+			}).Error("内联键盘回调参数存入redis异常")
+			return "", nil, err
+		}
+		callbackDataQueryString := utils.MapToQueryString(map[string]string{
+			"callbackDataKey": callbackDataKey,
+		})
+
+		buttonDataText := value.Name
+		if value == rankType {
+			buttonDataText = fmt.Sprintf("%s✅", buttonDataText)
+		}
+		inlineKeyboardButtons = append(inlineKeyboardButtons,
+			tgbotapi.NewInlineKeyboardButtonData(buttonDataText, fmt.Sprintf("%s%s", enums.CallbackRankTab.Value, callbackDataQueryString)))
+	}
+
+	inlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(
+		inlineKeyboardButtons[:2],
+		inlineKeyboardButtons[2:],
+	)
+	return text.String(), &inlineKeyboardMarkup, nil
+}
+
+// queryRankCache 优先读取redis中的排行榜缓存 不存在时统计后写入缓存
+func queryRankCache(chatGroupId string, rankType enums.RankType) (*rankCache, error) {
+	redisKey := fmt.Sprintf(RedisRankCacheKey, chatGroupId, rankType.Value)
+	result := redisDB.Get(redisDB.Context(), redisKey)
+	if result.Err() == nil {
+		var cache rankCache
+		cacheString, _ := result.Result()
+		err := json.Unmarshal([]byte(cacheString), &cache)
+		if err == nil {
+			return &cache, nil
+		}
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("排行榜缓存解析异常")
+	} else if !errors.Is(result.Err(), redis.Nil) {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      result.Err(),
+		}).Error("排行榜缓存查询异常")
+	}
+
+	entries, err := calcRankEntries(chatGroupId, rankType)
+	if err != nil {
+		return nil, err
+	}
+	cache := &rankCache{
+		Entries:    entries,
+		UpdateTime: time.Now().Format("15:04:05"),
+	}
+
+	jsonBytes, err := json.Marshal(cache)
+	if err != nil {
+		return nil, err
+	}
+	err = redisDB.Set(redisDB.Context(), redisKey, string(jsonBytes), RankCacheExpire).Err()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("排行榜缓存写入异常")
+	}
+	return cache, nil
+}
+
+// calcRankEntries 从群用户及已结算下注记录统计排行榜
+func calcRankEntries(chatGroupId string, rankType enums.RankType) ([]*rankEntry, error) {
+	chatGroupUserQuery := &model.ChatGroupUser{ChatGroupId: chatGroupId}
+	chatGroupUsers, err := chatGroupUserQuery.ListByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("查询群用户异常")
+		return nil, err
+	}
+	chatGroupUserMap := make(map[string]*model.ChatGroupUser)
+	for _, chatGroupUser := range chatGroupUsers {
+		chatGroupUserMap[chatGroupUser.Id] = chatGroupUser
+	}
+
+	var entries []*rankEntry
+	switch rankType {
+	case enums.BalanceRank:
+		for _, chatGroupUser := range chatGroupUsers {
+			if chatGroupUser.IsLeft == 0 {
+				entries = append(entries, &rankEntry{Name: rankUserName(chatGroupUser), Value: chatGroupUser.Balance})
+			}
+		}
+	case enums.TodayProfitRank, enums.WeekTurnoverRank:
+		now := time.Now()
+		from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		to := from.AddDate(0, 0, 1)
+		if rankType == enums.WeekTurnoverRank {
+			// 本周从周一开始
+			from = from.AddDate(0, 0, -(int(from.Weekday())+6)%7)
+		}
+		betRecordQuery := &model.QuickThereBetRecord{
+			ChatGroupId:  chatGroupId,
+			SettleStatus: enums.Settled.Value,
+		}
+		betRecords, err := betRecordQuery.ListByChatGroupIdAndSettleStatusAndCreateTimeRange(db, from.Format("2006-01-02 15:04:05"), to.Format("2006-01-02 15:04:05"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroupId,
+				"err":         err,
+			}).Error("查询下注记录异常")
+			return nil, err
+		}
+		sums := make(map[string]decimal.Decimal)
+		for _, betRecord := range betRecords {
+			if rankType == enums.WeekTurnoverRank {
+				sums[betRecord.ChatGroupUserId] = sums[betRecord.ChatGroupUserId].Add(betRecord.BetAmount)
+			} else {
+				sums[betRecord.ChatGroupUserId] = sums[betRecord.ChatGroupUserId].Add(betNetProfit(betRecord))
+			}
+		}
+		for chatGroupUserId, sum := range sums {
+			chatGroupUser, ok := chatGroupUserMap[chatGroupUserId]
+			if !ok || (rankType == enums.TodayProfitRank && !sum.IsPositive()) {
+				continue
+			}
+			entries = append(entries, &rankEntry{Name: rankUserName(chatGroupUser), Value: sum})
+		}
+	case enums.BiggestWinRank:
+		betResultType := enums.Win.Value
+		betRecordQuery := &model.QuickThereBetRecord{
+			ChatGroupId:   chatGroupId,
+			BetResultType: &betResultType,
+		}
+		betRecords, err := betRecordQuery.ListTopProfitByChatGroupIdAndBetResultType(db, RankLimit)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroupId,
+				"err":         err,
+			}).Error("查询下注记录异常")
+			return nil, err
+		}
+		for _, betRecord := range betRecords {
+			chatGroupUser, ok := chatGroupUserMap[betRecord.ChatGroupUserId]
+			if !ok {
+				continue
+			}
+			entries = append(entries, &rankEntry{Name: rankUserName(chatGroupUser), Value: betNetProfit(betRecord)})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Value.GreaterThan(entries[j].Value)
+	})
+	if len(entries) > RankLimit {
+		entries = entries[:RankLimit]
+	}
+	return entries, nil
+}
+
+// rankUserName 排行榜展示的用户名 不带@避免提醒上榜用户
+func rankUserName(chatGroupUser *model.ChatGroupUser) string {
+	if chatGroupUser.Username != "" {
+		return chatGroupUser.Username
+	}
+	return fmt.Sprintf("%d", chatGroupUser.TgUserId)
+}
@@ -0,0 +1,592 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/common"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	DefaultRebateMinTurnover = 100
+	DefaultRebatePayHour     = 12
+	RebateMaxRate            = 100
+	RebateHistoryLimit       = 3
+	RebateCatchUpDays        = 7 // 停机等原因错过发放时间时最多补发的下注日天数
+)
+
+// DefaultRebateRate 默认反水比例 0.5%
+var DefaultRebateRate = decimal.New(5, -1)
+
+// rebateStat 群用户单日下注汇总
+type rebateStat struct {
+	Turnover decimal.Decimal // 下注流水
+	NetLoss  decimal.Decimal // 净输积分 赢多于输时为负
+}
+
+// queryOrCreateRebateConfig 查询群反水配置 不存在时按默认值(关闭)创建
+func queryOrCreateRebateConfig(chatGroupId string) (*model.RebateConfig, error) {
+	return queryOrCreateConfig(chatGroupId, "反水配置", model.QueryRebateConfigByChatGroupId, func() *model.RebateConfig {
+		return &model.RebateConfig{
+			ChatGroupId: chatGroupId,
+			Status:      enums.GameplayStatusOFF.Value,
+			RebateMode:  enums.TurnoverRebate.Value,
+			Rate:        DefaultRebateRate,
+			MinTurnover: decimal.NewFromInt(DefaultRebateMinTurnover),
+			PayHour:     DefaultRebatePayHour,
+			CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		}
+	})
+}
+
+func formatRebateRule(rebateConfig *model.RebateConfig) string {
+	rebateMode, b := enums.GetRebateMode(rebateConfig.RebateMode)
+	if !b {
+		rebateMode = enums.TurnoverRebate
+	}
+	return fmt.Sprintf("%s反水%s%%,前一日流水不低于%s,每日%d点发放",
+		rebateMode.Name,
+		rebateConfig.Rate.String(),
+		utils.FormatAmount(rebateConfig.MinTurnover),
+		rebateConfig.PayHour)
+}
+
+// buildRebateConfigInlineKeyboardRow 群配置中的反水按钮
+func buildRebateConfigInlineKeyboardRow(chatGroupId string, callbackDataQueryString string) ([]tgbotapi.InlineKeyboardButton, error) {
+	rebateConfig, err := queryOrCreateRebateConfig(chatGroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	rebateStatus, b := enums.GetGameplayStatus(rebateConfig.Status)
+	if !b {
+		rebateStatus = enums.GameplayStatusOFF
+	}
+
+	rebateMode, b := enums.GetRebateMode(rebateConfig.RebateMode)
+	if !b {
+		rebateMode = enums.TurnoverRebate
+	}
+
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("💸反水: %s", rebateStatus.Name), fmt.Sprintf("%s%s", enums.CallbackUpdateRebateStatus.Value, callbackDataQueryString)),
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📐%s", rebateMode.Name), fmt.Sprintf("%s%s", enums.CallbackUpdateRebateMode.Value, callbackDataQueryString)),
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📊比例: %s%%", rebateConfig.Rate.String()), fmt.Sprintf("%s%s", enums.CallbackUpdateRebateRule.Value, callbackDataQueryString)),
+	), nil
+}
+
+// updateRebateStatusCallBack 开启/关闭反水
+func updateRebateStatusCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	updateRebateConfigCallBack(bot, query, enums.CallbackUpdateRebateStatus, func(rebateConfig *model.RebateConfig) error {
+		rebateConfigUpdate := &model.RebateConfig{
+			ChatGroupId: rebateConfig.ChatGroupId,
+			Status:      enums.GameplayStatusON.Value,
+		}
+		if rebateConfig.Status == enums.GameplayStatusON.Value {
+			rebateConfigUpdate.Status = enums.GameplayStatusOFF.Value
+		} else {
+			// 重新开启时从前一日开始发放 关闭期间的下注日不补发
+			now := time.Now()
+			rebateConfigUpdate.PaidBetDate = time.Date(now.Year(), now.Month(), now.Day()-2, 0, 0, 0, 0, now.Location()).Format("2006-01-02")
+			err := rebateConfigUpdate.UpdatePaidBetDateByChatGroupId(db)
+			if err != nil {
+				return err
+			}
+		}
+		return rebateConfigUpdate.UpdateStatusByChatGroupId(db)
+	})
+}
+
+// updateRebateModeCallBack 切换反水计算方式 按流水/按净输
+func updateRebateModeCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	updateRebateConfigCallBack(bot, query, enums.CallbackUpdateRebateMode, func(rebateConfig *model.RebateConfig) error {
+		rebateConfigUpdate := &model.RebateConfig{
+			ChatGroupId: rebateConfig.ChatGroupId,
+			RebateMode:  enums.NetLossRebate.Value,
+		}
+		if rebateConfig.RebateMode == enums.NetLossRebate.Value {
+			rebateConfigUpdate.RebateMode = enums.TurnoverRebate.Value
+		}
+		return rebateConfigUpdate.UpdateRebateModeByChatGroupId(db)
+	})
+}
+
+// updateRebateConfigCallBack 校验管理员后修改反水配置并刷新群配置菜单
+func updateRebateConfigCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, callbackPrefix enums.CallbackPrefix, update func(rebateConfig *model.RebateConfig) error) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+	fromUser := query.From
+
+	callBackData, err := queryCallBackData(query, callbackPrefix)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	rebateConfig, err := queryOrCreateRebateConfig(chatGroupId)
+	if err != nil {
+		return
+	}
+
+	err = update(rebateConfig)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"callback":    callbackPrefix.Name,
+			"err":         err,
+		}).Error("更新反水配置异常")
+		return
+	}
+
+	inlineKeyboardMarkup, err := buildChatGroupInlineKeyboardMarkup(query, chatGroup)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"err": err,
+		}).Error("组装群组配置内联键盘异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("点击修改【%s】相关配置:", chatGroup.TgChatGroupTitle))
+	sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// updateRebateRuleCallBack 修改反水规则
+func updateRebateRuleCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateRebateRule)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	rebateConfig, err := queryOrCreateRebateConfig(callBackData["chatGroupId"])
+	if err != nil {
+		return
+	}
+
+	groupConfigInputCallBack(bot, query, enums.CallbackUpdateRebateRule, enums.WaitRebateRule,
+		fmt.Sprintf("当前规则: %s\n"+
+			"请输入反水规则: 反水比例(%%,最多两位小数,不超过%d) 最低流水 发放时间(0-23点),以空格分隔\n"+
+			"例子: 0.5 100 12\n即前一日下注流水不低于100积分的用户,每日12点按0.5%%发放反水(按净输计算时以前一日净输积分为基数)",
+			formatRebateRule(rebateConfig), RebateMaxRate))
+}
+
+// updateRebateRule 设置反水规则
+func updateRebateRule(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCache *common.BotPrivateChatCache) {
+	tgUserId := message.From.ID
+	chatId := message.Chat.ID
+
+	// 校验当前对话人是否为该群管理员
+	err := checkGroupAdmin(botPrivateChatCache.ChatGroupId, tgUserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"tgUserId":    tgUserId,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	rebateConfigUpdate, err := parseRebateRule(message.Text)
+	if err != nil {
+		sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("反水规则不合法(%s),例子: 0.5 100 12", err.Error()))
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	}
+
+	rebateConfig, err := queryOrCreateRebateConfig(botPrivateChatCache.ChatGroupId)
+	if err != nil {
+		return
+	}
+
+	rebateConfigUpdate.ChatGroupId = botPrivateChatCache.ChatGroupId
+	err = rebateConfigUpdate.UpdateRuleByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"err":         err,
+		}).Error("设置反水规则异常")
+		return
+	}
+
+	rebateConfigUpdate.RebateMode = rebateConfig.RebateMode
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!反水规则: %s。", formatRebateRule(rebateConfigUpdate)))
+	sendMsg.ReplyToMessageID = message.MessageID
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+	// 删除bot与当前对话人的cache
+	redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
+	redisDB.Del(redisDB.Context(), redisKey)
+}
+
+// parseRebateRule 解析反水规则 反水比例 最低流水 发放时间
+func parseRebateRule(text string) (*model.RebateConfig, error) {
+	parts := strings.Fields(text)
+	if len(parts) != 3 {
+		return nil, errors.New("需输入3项")
+	}
+	rate, err := utils.ParseAmount(parts[0])
+	if err != nil || rate.GreaterThan(decimal.NewFromInt(RebateMaxRate)) {
+		return nil, fmt.Errorf("反水比例需大于0且不超过%d", RebateMaxRate)
+	}
+	minTurnover, err := utils.ParseAmount(parts[1])
+	if err != nil {
+		return nil, errors.New("最低流水错误")
+	}
+	payHour, err := strconv.Atoi(parts[2])
+	if err != nil || payHour < 0 || payHour > 23 {
+		return nil, errors.New("发放时间需为0-23")
+	}
+	return &model.RebateConfig{
+		Rate:        rate,
+		MinTurnover: minTurnover,
+		PayHour:     payHour,
+	}, nil
+}
+
+// initRebateTask 启动时及每个整点发放所有已到发放时间且未发放的反水
+func initRebateTask(bot *tgbotapi.BotAPI) {
+	go func() {
+		payRebates(bot, time.Now())
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Hour).Add(time.Hour)
+			time.Sleep(next.Sub(now))
+			payRebates(bot, next)
+		}
+	}()
+}
+
+// payRebates 按群配置逐日发放未发放的反水
+// 下注日的次日到达发放时间后发放;停机错过的下注日在启动或下个整点补发(最多补发RebateCatchUpDays天),
+// 同一用户同一下注日由唯一索引保证只发放一次
+func payRebates(bot *tgbotapi.BotAPI, now time.Time) {
+	rebateConfigs, err := model.ListRebateConfigByStatus(db, enums.GameplayStatusON.Value)
+	if err != nil {
+		logrus.WithField("err", err).Error("查询反水配置异常")
+		return
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, rebateConfig := range rebateConfigs {
+		// 最近一个已到发放时间的下注日
+		lastDueDate := today.AddDate(0, 0, -1)
+		if now.Hour() < rebateConfig.PayHour {
+			lastDueDate = lastDueDate.AddDate(0, 0, -1)
+		}
+
+		betDate := lastDueDate
+		if rebateConfig.PaidBetDate != "" {
+			paidBetDate, err := time.ParseInLocation("2006-01-02", rebateConfig.PaidBetDate, now.Location())
+			if err == nil {
+				betDate = paidBetDate.AddDate(0, 0, 1)
+			}
+		}
+		if earliestBetDate := lastDueDate.AddDate(0, 0, 1-RebateCatchUpDays); betDate.Before(earliestBetDate) {
+			betDate = earliestBetDate
+		}
+
+		for ; !betDate.After(lastDueDate); betDate = betDate.AddDate(0, 0, 1) {
+			if !payChatGroupRebate(bot, rebateConfig, betDate, betDate.AddDate(0, 0, 1)) {
+				break
+			}
+			rebateConfig.PaidBetDate = betDate.Format("2006-01-02")
+			err = rebateConfig.UpdatePaidBetDateByChatGroupId(db)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"chatGroupId": rebateConfig.ChatGroupId,
+					"paidBetDate": rebateConfig.PaidBetDate,
+					"err":         err,
+				}).Error("更新反水发放日期异常")
+				break
+			}
+		}
+	}
+}
+
+// payChatGroupRebate 汇总群内[from, to)的下注并逐个用户发放反水 返回该下注日是否已全部发放
+// 仍有未开奖的下注时等待开奖后再发放,发放失败的用户在下次执行时重试
+func payChatGroupRebate(bot *tgbotapi.BotAPI, rebateConfig *model.RebateConfig, from time.Time, to time.Time) bool {
+	betDate := from.Format("2006-01-02")
+
+	chatGroup, err := model.QueryChatGroupById(db, rebateConfig.ChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": rebateConfig.ChatGroupId,
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return false
+	}
+	if chatGroup.ChatGroupStatus != enums.GroupNormal.Value {
+		return false
+	}
+
+	unsettledQuery := &model.QuickThereBetRecord{
+		ChatGroupId:  chatGroup.Id,
+		SettleStatus: enums.Unsettled.Value,
+	}
+	unsettledCount, err := unsettledQuery.CountByChatGroupIdAndSettleStatusAndCreateTimeRange(db,
+		from.Format("2006-01-02 15:04:05"), to.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"betDate":     betDate,
+			"err":         err,
+		}).Error("反水查询未开奖下注异常")
+		return false
+	}
+	if unsettledCount > 0 {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId":    chatGroup.Id,
+			"betDate":        betDate,
+			"unsettledCount": unsettledCount,
+		}).Warn("仍有未开奖的下注 暂缓发放反水")
+		return false
+	}
+
+	betRecordQuery := &model.QuickThereBetRecord{
+		ChatGroupId:  chatGroup.Id,
+		SettleStatus: enums.Settled.Value,
+	}
+	betRecords, err := betRecordQuery.ListByChatGroupIdAndSettleStatusAndCreateTimeRange(db,
+		from.Format("2006-01-02 15:04:05"), to.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"betDate":     betDate,
+			"err":         err,
+		}).Error("反水查询下注记录异常")
+		return false
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"chatGroupId": chatGroup.Id,
+		"betDate":     betDate,
+		"betCount":    len(betRecords),
+	}).Info("开始发放反水")
+
+	paid := true
+	for chatGroupUserId, stat := range sumRebateStats(betRecords) {
+		amount := calcRebateAmount(rebateConfig, stat)
+		if !amount.IsPositive() {
+			continue
+		}
+		if !payUserRebate(bot, chatGroup, rebateConfig, chatGroupUserId, betDate, stat, amount) {
+			paid = false
+		}
+	}
+	return paid
+}
+
+// sumRebateStats 按群用户汇总下注流水及净输
+// 中奖记录的结果积分为派彩(下注时已扣除本金),未中奖为-下注积分
+func sumRebateStats(betRecords []*model.QuickThereBetRecord) map[string]*rebateStat {
+	stats := make(map[string]*rebateStat)
+	for _, betRecord := range betRecords {
+		stat, ok := stats[betRecord.ChatGroupUserId]
+		if !ok {
+			stat = &rebateStat{Turnover: decimal.Zero, NetLoss: decimal.Zero}
+			stats[betRecord.ChatGroupUserId] = stat
+		}
+		stat.Turnover = stat.Turnover.Add(betRecord.BetAmount)
+		if betRecord.BetResultType != nil && *betRecord.BetResultType == enums.Win.Value && betRecord.BetResultAmount.Valid {
+			stat.NetLoss = stat.NetLoss.Add(betRecord.BetAmount).Sub(betRecord.BetResultAmount.Decimal)
+		} else {
+			stat.NetLoss = stat.NetLoss.Add(betRecord.BetAmount)
+		}
+	}
+	return stats
+}
+
+// calcRebateAmount 流水未达最低流水不反水,按净输计算时净输不为正不反水,金额按分向下截断
+func calcRebateAmount(rebateConfig *model.RebateConfig, stat *rebateStat) decimal.Decimal {
+	if stat.Turnover.LessThan(rebateConfig.MinTurnover) {
+		return decimal.Zero
+	}
+	base := stat.Turnover
+	if rebateConfig.RebateMode == enums.NetLossRebate.Value {
+		base = stat.NetLoss
+	}
+	if !base.IsPositive() {
+		return decimal.Zero
+	}
+	return utils.RoundPayout(base.Mul(rebateConfig.Rate).Div(decimal.NewFromInt(100)))
+}
+
+// payUserRebate 发放单个用户反水 同一下注日已发放过则跳过 返回是否已发放
+func payUserRebate(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, rebateConfig *model.RebateConfig, chatGroupUserId string, betDate string, stat *rebateStat, amount decimal.Decimal) bool {
+	chatGroupUserQuery := &model.ChatGroupUser{Id: chatGroupUserId}
+	chatGroupUser, err := chatGroupUserQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUserId,
+			"err":             err,
+		}).Error("群用户查询异常")
+		return false
+	}
+
+	// 获取用户对应的互斥锁
+	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, chatGroup.TgChatGroupId, chatGroupUser.TgUserId)
+	userLock := getUserLock(userLockKey)
+	userLock.Lock()
+	defer userLock.Unlock()
+
+	rebateRecordQuery := &model.RebateRecord{ChatGroupUserId: chatGroupUserId, BetDate: betDate}
+	_, err = rebateRecordQuery.QueryByChatGroupUserIdAndBetDate(db)
+	if err == nil {
+		return true
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUserId,
+			"betDate":         betDate,
+			"err":             err,
+		}).Error("查询反水记录异常")
+		return false
+	}
+
+	// 加锁后重新查询余额
+	chatGroupUser, err = chatGroupUserQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUserId,
+			"err":             err,
+		}).Error("群用户查询异常")
+		return false
+	}
+
+	tx := db.Begin()
+	balanceBefore := chatGroupUser.Balance
+	chatGroupUser.Balance = chatGroupUser.Balance.Add(amount)
+	result := tx.Save(&chatGroupUser)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             result.Error,
+		}).Error("更新用户余额异常")
+		tx.Rollback()
+		return false
+	}
+
+	rebateRecord := &model.RebateRecord{
+		ChatGroupId:     chatGroup.Id,
+		ChatGroupUserId: chatGroupUser.Id,
+		TgUserId:        chatGroupUser.TgUserId,
+		BetDate:         betDate,
+		RebateMode:      rebateConfig.RebateMode,
+		Rate:            rebateConfig.Rate,
+		Turnover:        stat.Turnover,
+		NetLoss:         stat.NetLoss,
+		Amount:          amount,
+		CreateTime:      time.Now().Format("2006-01-02 15:04:05"),
+	}
+	err = rebateRecord.Create(tx)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		tx.Rollback()
+		return true
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"betDate":         betDate,
+			"err":             err,
+		}).Error("保存反水记录异常")
+		tx.Rollback()
+		return false
+	}
+
+	err = addBalanceLedger(tx, chatGroupUser, balanceBefore, enums.RebateLedger, &model.BalanceLedger{RefId: rebateRecord.Id})
+	if err != nil {
+		tx.Rollback()
+		return false
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("反水事务提交异常")
+		tx.Rollback()
+		return false
+	}
+
+	rebateMode, b := enums.GetRebateMode(rebateConfig.RebateMode)
+	if !b {
+		rebateMode = enums.TurnoverRebate
+	}
+
+	// 消息提醒
+	sendMsg := tgbotapi.NewMessage(chatGroupUser.TgUserId,
+		fmt.Sprintf("您在【%s】%s的下注流水为%s积分,净输%s积分,%s反水%s%%,获得反水%s积分,积分余额%s。",
+			chatGroup.TgChatGroupTitle,
+			betDate,
+			utils.FormatAmount(stat.Turnover),
+			utils.FormatAmount(stat.NetLoss),
+			rebateMode.Name,
+			rebateConfig.Rate.String(),
+			utils.FormatAmount(amount),
+			utils.FormatAmount(chatGroupUser.Balance)))
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatGroupUser.TgUserId)
+	return true
+}
+
+// buildRebateHistoryText 用户近期反水发放记录
+func buildRebateHistoryText(chatGroupUserId string) string {
+	rebateRecordQuery := &model.RebateRecord{ChatGroupUserId: chatGroupUserId}
+	rebateRecords, err := rebateRecordQuery.ListByChatGroupUserId(db, RebateHistoryLimit)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUserId,
+			"err":             err,
+		}).Error("查询反水记录异常")
+		return ""
+	}
+	if len(rebateRecords) == 0 {
+		return ""
+	}
+
+	text := fmt.Sprintf("近%d次反水记录:\n", RebateHistoryLimit)
+	for _, record := range rebateRecords {
+		rebateMode, b := enums.GetRebateMode(record.RebateMode)
+		if !b {
+			rebateMode = enums.TurnoverRebate
+		}
+		text += fmt.Sprintf("%s 流水%s %s反水%s%% +%s\n",
+			record.BetDate,
+			utils.FormatAmount(record.Turnover),
+			rebateMode.Name,
+			record.Rate.String(),
+			utils.FormatAmount(record.Amount))
+	}
+	return text
+}
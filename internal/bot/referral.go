@@ -0,0 +1,525 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strings"
+	"telegram-dice-bot/internal/common"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	DefaultReferralBonus       = 1000
+	DefaultReferralMinTurnover = 500
+)
+
+// queryOrCreateReferralConfig 查询群邀请奖励配置 不存在时按默认值(关闭)创建
+func queryOrCreateReferralConfig(chatGroupId string) (*model.ReferralConfig, error) {
+	return queryOrCreateConfig(chatGroupId, "邀请奖励配置", model.QueryReferralConfigByChatGroupId, func() *model.ReferralConfig {
+		return &model.ReferralConfig{
+			ChatGroupId: chatGroupId,
+			Status:      enums.GameplayStatusOFF.Value,
+			Bonus:       decimal.NewFromInt(DefaultReferralBonus),
+			MinTurnover: decimal.NewFromInt(DefaultReferralMinTurnover),
+			CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		}
+	})
+}
+
+func formatReferralRule(referralConfig *model.ReferralConfig) string {
+	return fmt.Sprintf("被邀请人通过专属链接入群、注册并累计下注%s积分后,邀请人获得%s积分",
+		utils.FormatAmount(referralConfig.MinTurnover),
+		utils.FormatAmount(referralConfig.Bonus))
+}
+
+// buildReferralConfigInlineKeyboardRow 群配置中的邀请奖励按钮
+func buildReferralConfigInlineKeyboardRow(chatGroupId string, callbackDataQueryString string) ([]tgbotapi.InlineKeyboardButton, error) {
+	referralConfig, err := queryOrCreateReferralConfig(chatGroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	referralStatus, b := enums.GetGameplayStatus(referralConfig.Status)
+	if !b {
+		referralStatus = enums.GameplayStatusOFF
+	}
+
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🤝邀请奖励: %s", referralStatus.Name), fmt.Sprintf("%s%s", enums.CallbackUpdateReferralStatus.Value, callbackDataQueryString)),
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🎁奖励积分: %s", utils.FormatAmount(referralConfig.Bonus)), fmt.Sprintf("%s%s", enums.CallbackUpdateReferralRule.Value, callbackDataQueryString)),
+	), nil
+}
+
+// updateReferralStatusCallBack 开启/关闭邀请奖励
+func updateReferralStatusCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	updateChatGroupStatusCallBack(bot, query, enums.CallbackUpdateReferralStatus, func(chatGroup *model.ChatGroup) (int, error) {
+		referralConfig, err := queryOrCreateReferralConfig(chatGroup.Id)
+		if err != nil {
+			return 0, err
+		}
+		return referralConfig.Status, nil
+	}, func(chatGroup *model.ChatGroup, status int) error {
+		referralConfigUpdate := &model.ReferralConfig{
+			ChatGroupId: chatGroup.Id,
+			Status:      status,
+		}
+		return referralConfigUpdate.UpdateStatusByChatGroupId(db)
+	})
+}
+
+// updateReferralRuleCallBack 修改邀请奖励规则
+func updateReferralRuleCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateReferralRule)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	referralConfig, err := queryOrCreateReferralConfig(callBackData["chatGroupId"])
+	if err != nil {
+		return
+	}
+
+	groupConfigInputCallBack(bot, query, enums.CallbackUpdateReferralRule, enums.WaitReferralRule,
+		fmt.Sprintf("当前规则: %s\n"+
+			"请输入邀请奖励规则: 奖励积分 被邀请人需达到的下注流水,以空格分隔\n"+
+			"例子: 1000 500\n即被邀请人入群注册后累计下注500积分,邀请人获得1000积分",
+			formatReferralRule(referralConfig)))
+}
+
+// updateReferralRule 设置邀请奖励规则
+func updateReferralRule(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCache *common.BotPrivateChatCache) {
+	tgUserId := message.From.ID
+	chatId := message.Chat.ID
+
+	// 校验当前对话人是否为该群管理员
+	err := checkGroupAdmin(botPrivateChatCache.ChatGroupId, tgUserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"tgUserId":    tgUserId,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	referralConfigUpdate, err := parseReferralRule(message.Text)
+	if err != nil {
+		sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("邀请奖励规则不合法(%s),例子: 1000 500", err.Error()))
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	}
+
+	_, err = queryOrCreateReferralConfig(botPrivateChatCache.ChatGroupId)
+	if err != nil {
+		return
+	}
+
+	referralConfigUpdate.ChatGroupId = botPrivateChatCache.ChatGroupId
+	err = referralConfigUpdate.UpdateRuleByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"err":         err,
+		}).Error("设置邀请奖励规则异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!邀请奖励规则: %s。", formatReferralRule(referralConfigUpdate)))
+	sendMsg.ReplyToMessageID = message.MessageID
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+	// 删除bot与当前对话人的cache
+	redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
+	redisDB.Del(redisDB.Context(), redisKey)
+}
+
+// parseReferralRule 解析邀请奖励规则 奖励积分 下注流水
+func parseReferralRule(text string) (*model.ReferralConfig, error) {
+	parts := strings.Fields(text)
+	if len(parts) != 2 {
+		return nil, errors.New("需输入2项")
+	}
+	bonus, err := utils.ParseAmount(parts[0])
+	if err != nil {
+		return nil, errors.New("奖励积分错误")
+	}
+	minTurnover, err := utils.ParseAmount(parts[1])
+	if err != nil {
+		return nil, errors.New("下注流水错误")
+	}
+	return &model.ReferralConfig{
+		Bonus:       bonus,
+		MinTurnover: minTurnover,
+	}, nil
+}
+
+// handleInviteCommand 获取专属邀请链接及邀请统计
+func handleInviteCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	fromUser := message.From
+	messageId := message.MessageID
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	referralConfig, err := queryOrCreateReferralConfig(chatGroup.Id)
+	if err != nil {
+		return
+	}
+	if referralConfig.Status != enums.GameplayStatusON.Value {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "本群未开启邀请奖励。")
+		return
+	}
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    fromUser.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "您还未注册，使用 /register 进行注册。")
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    fromUser.ID,
+			"ChatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("群用户查询异常")
+		return
+	}
+
+	referralLink, err := queryOrCreateReferralLink(bot, chatGroup, chatGroupUser)
+	if err != nil {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "生成邀请链接失败,请联系管理员确认机器人拥有邀请用户权限。")
+		return
+	}
+
+	referralRecordQuery := &model.ReferralRecord{InviterChatGroupUserId: chatGroupUser.Id}
+	referralRecords, err := referralRecordQuery.ListByInviterChatGroupUserId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("查询邀请记录异常")
+		return
+	}
+
+	rewardedCount := 0
+	rewardedBonus := decimal.Zero
+	for _, record := range referralRecords {
+		if record.Status == enums.ReferralRewarded.Value {
+			rewardedCount++
+			rewardedBonus = rewardedBonus.Add(record.Bonus)
+		}
+	}
+
+	replyAutoDeleteMessage(bot, tgChatGroupId, messageId, fmt.Sprintf("您的专属邀请链接: %s\n%s。\n已邀请%d人,已达标%d人,累计获得%s积分。",
+		referralLink.InviteLink,
+		formatReferralRule(referralConfig),
+		len(referralRecords),
+		rewardedCount,
+		utils.FormatAmount(rewardedBonus)))
+}
+
+// queryOrCreateReferralLink 查询用户专属邀请链接 不存在时通过 createChatInviteLink 生成
+func queryOrCreateReferralLink(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, chatGroupUser *model.ChatGroupUser) (*model.ReferralLink, error) {
+	referralLinkQuery := &model.ReferralLink{ChatGroupUserId: chatGroupUser.Id}
+	referralLink, err := referralLinkQuery.QueryByChatGroupUserId(db)
+	if err == nil {
+		return referralLink, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("查询邀请链接异常")
+		return nil, err
+	}
+
+	resp, err := bot.Request(tgbotapi.CreateChatInviteLinkConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatGroup.TgChatGroupId},
+		Name:       fmt.Sprintf("ref-%d", chatGroupUser.TgUserId),
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": chatGroup.TgChatGroupId,
+			"tgUserId":      chatGroupUser.TgUserId,
+			"err":           err,
+		}).Error("生成邀请链接异常")
+		return nil, err
+	}
+
+	var chatInviteLink tgbotapi.ChatInviteLink
+	err = json.Unmarshal(resp.Result, &chatInviteLink)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": chatGroup.TgChatGroupId,
+			"err":           err,
+		}).Error("解析邀请链接异常")
+		return nil, err
+	}
+
+	referralLink = &model.ReferralLink{
+		ChatGroupId:     chatGroup.Id,
+		ChatGroupUserId: chatGroupUser.Id,
+		TgUserId:        chatGroupUser.TgUserId,
+		InviteLink:      chatInviteLink.InviteLink,
+		CreateTime:      time.Now().Format("2006-01-02 15:04:05"),
+	}
+	err = referralLink.Create(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("保存邀请链接异常")
+		return nil, err
+	}
+	return referralLink, nil
+}
+
+// handleGroupChatMember 群成员状态变更
+// 入群服务消息(handleGroupNewMembers)不携带邀请链接,通过 chat_member 更新中的 invite_link 识别邀请人
+func handleGroupChatMember(bot *tgbotapi.BotAPI, chatMemberUpdated *tgbotapi.ChatMemberUpdated) {
+	oldMember := chatMemberUpdated.OldChatMember
+	newMember := chatMemberUpdated.NewChatMember
+	joined := (oldMember.HasLeft() || oldMember.WasKicked()) && (newMember.Status == "member" || newMember.Status == "restricted")
+	if !joined || chatMemberUpdated.InviteLink == nil {
+		return
+	}
+	recordReferral(chatMemberUpdated.Chat.ID, newMember.User, chatMemberUpdated.InviteLink.InviteLink)
+}
+
+// recordReferral 记录通过专属链接入群的邀请关系
+// 防刷规则: 忽略机器人及自己邀请自己;被邀请人在该群已有邀请记录(退群重进)或已注册过(老成员回流)均不记录
+func recordReferral(tgChatGroupId int64, invitee *tgbotapi.User, inviteLink string) {
+	if invitee == nil || invitee.IsBot {
+		return
+	}
+
+	referralLinkQuery := &model.ReferralLink{InviteLink: inviteLink}
+	referralLink, err := referralLinkQuery.QueryByInviteLink(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"inviteLink": inviteLink,
+			"err":        err,
+		}).Error("查询邀请链接异常")
+		return
+	}
+
+	logFields := logrus.Fields{
+		"chatGroupId":     referralLink.ChatGroupId,
+		"inviterTgUserId": referralLink.TgUserId,
+		"inviteeTgUserId": invitee.ID,
+	}
+
+	if referralLink.TgUserId == invitee.ID {
+		logrus.WithFields(logFields).Warn("忽略自己邀请自己")
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, referralLink.ChatGroupId)
+	if err != nil || chatGroup.TgChatGroupId != tgChatGroupId {
+		logrus.WithFields(logFields).Warn("邀请链接与入群群组不一致")
+		return
+	}
+
+	referralConfig, err := queryOrCreateReferralConfig(chatGroup.Id)
+	if err != nil || referralConfig.Status != enums.GameplayStatusON.Value {
+		return
+	}
+
+	referralRecordQuery := &model.ReferralRecord{
+		ChatGroupId:     chatGroup.Id,
+		InviteeTgUserId: invitee.ID,
+	}
+	_, err = referralRecordQuery.QueryByChatGroupIdAndInviteeTgUserId(db)
+	if err == nil {
+		logrus.WithFields(logFields).Warn("被邀请人退群重进 不重复记录邀请")
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logFields).WithField("err", err).Error("查询邀请记录异常")
+		return
+	}
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    invitee.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+	_, err = chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if err == nil {
+		logrus.WithFields(logFields).Warn("被邀请人已在该群注册过 不记录邀请")
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logFields).WithField("err", err).Error("群用户查询异常")
+		return
+	}
+
+	referralRecord := &model.ReferralRecord{
+		ChatGroupId:            chatGroup.Id,
+		InviterChatGroupUserId: referralLink.ChatGroupUserId,
+		InviterTgUserId:        referralLink.TgUserId,
+		InviteeTgUserId:        invitee.ID,
+		InviteLink:             inviteLink,
+		Status:                 enums.ReferralPending.Value,
+		Bonus:                  decimal.Zero,
+		CreateTime:             time.Now().Format("2006-01-02 15:04:05"),
+	}
+	err = referralRecord.Create(db)
+	if err != nil {
+		logrus.WithFields(logFields).WithField("err", err).Error("保存邀请记录异常")
+		return
+	}
+	logrus.WithFields(logFields).Info("记录邀请关系")
+}
+
+// checkReferralReward 被邀请人结算下注后检查是否达到流水要求 达标则向邀请人发放奖励
+func checkReferralReward(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, invitee *model.ChatGroupUser) {
+	referralRecordQuery := &model.ReferralRecord{
+		ChatGroupId:     chatGroup.Id,
+		InviteeTgUserId: invitee.TgUserId,
+	}
+	referralRecord, err := referralRecordQuery.QueryByChatGroupIdAndInviteeTgUserId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId":     chatGroup.Id,
+			"inviteeTgUserId": invitee.TgUserId,
+			"err":             err,
+		}).Error("查询邀请记录异常")
+		return
+	}
+	if referralRecord.Status != enums.ReferralPending.Value {
+		return
+	}
+
+	referralConfig, err := queryOrCreateReferralConfig(chatGroup.Id)
+	if err != nil || referralConfig.Status != enums.GameplayStatusON.Value {
+		return
+	}
+
+	// 仅统计入群后已开奖的下注
+	betRecordQuery := &model.QuickThereBetRecord{ChatGroupUserId: invitee.Id}
+	betRecords, err := betRecordQuery.ListByChatGroupUserIdAndCreateTimeFrom(db, referralRecord.CreateTime)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": invitee.Id,
+			"err":             err,
+		}).Error("查询下注记录异常")
+		return
+	}
+	turnover := decimal.Zero
+	for _, betRecord := range betRecords {
+		if betRecord.SettleStatus == enums.Settled.Value {
+			turnover = turnover.Add(betRecord.BetAmount)
+		}
+	}
+	if turnover.LessThan(referralConfig.MinTurnover) {
+		return
+	}
+
+	inviterQuery := &model.ChatGroupUser{Id: referralRecord.InviterChatGroupUserId}
+	inviter, err := inviterQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": referralRecord.InviterChatGroupUserId,
+			"err":             err,
+		}).Error("查询邀请人异常")
+		return
+	}
+	if inviter.IsLeft == 1 {
+		// 邀请人已退群 保留待达标状态 回群后下次结算再发放
+		return
+	}
+
+	// 获取邀请人对应的互斥锁
+	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, chatGroup.TgChatGroupId, inviter.TgUserId)
+	userLock := getUserLock(userLockKey)
+	userLock.Lock()
+	defer userLock.Unlock()
+
+	// 加锁后重新查询余额
+	inviter, err = inviterQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": referralRecord.InviterChatGroupUserId,
+			"err":             err,
+		}).Error("查询邀请人异常")
+		return
+	}
+
+	tx := db.Begin()
+	referralRecord.Status = enums.ReferralRewarded.Value
+	referralRecord.Bonus = referralConfig.Bonus
+	referralRecord.RewardTime = time.Now().Format("2006-01-02 15:04:05")
+	updated, err := referralRecord.UpdateRewardedById(tx, enums.ReferralPending.Value)
+	if err != nil || !updated {
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"referralRecordId": referralRecord.Id,
+				"err":              err,
+			}).Error("更新邀请记录异常")
+		}
+		tx.Rollback()
+		return
+	}
+
+	balanceBefore := inviter.Balance
+	inviter.Balance = inviter.Balance.Add(referralConfig.Bonus)
+	result := tx.Save(&inviter)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": inviter.Id,
+			"err":             result.Error,
+		}).Error("更新用户余额异常")
+		tx.Rollback()
+		return
+	}
+
+	err = addBalanceLedger(tx, inviter, balanceBefore, enums.ReferralLedger, &model.BalanceLedger{RefId: referralRecord.Id})
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("邀请奖励事务提交异常")
+		tx.Rollback()
+		return
+	}
+
+	inviteeName := invitee.Username
+	if inviteeName == "" {
+		inviteeName = fmt.Sprintf("%d", invitee.TgUserId)
+	}
+
+	// 消息提醒
+	sendMsg := tgbotapi.NewMessage(inviter.TgUserId,
+		fmt.Sprintf("您在【%s】邀请的用户%s下注流水已达%s积分,获得邀请奖励%s积分,积分余额%s。",
+			chatGroup.TgChatGroupTitle,
+			inviteeName,
+			utils.FormatAmount(referralConfig.MinTurnover),
+			utils.FormatAmount(referralConfig.Bonus),
+			utils.FormatAmount(inviter.Balance)))
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, inviter.TgUserId)
+}
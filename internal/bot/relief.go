@@ -0,0 +1,346 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/common"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	DefaultReliefAmount          = 500
+	DefaultReliefThreshold       = 10
+	DefaultReliefDailyLimit      = 1
+	DefaultReliefCooldownMinutes = 60
+	ReliefMaxDailyLimit          = 10
+	ReliefMaxCooldownMinutes     = 1440
+	ReliefHistoryLimit           = 3
+)
+
+// queryOrCreateReliefConfig 查询群救济金配置 不存在时按默认值(关闭)创建
+func queryOrCreateReliefConfig(chatGroupId string) (*model.ReliefConfig, error) {
+	return queryOrCreateConfig(chatGroupId, "救济金配置", model.QueryReliefConfigByChatGroupId, func() *model.ReliefConfig {
+		return &model.ReliefConfig{
+			ChatGroupId:     chatGroupId,
+			Status:          enums.GameplayStatusOFF.Value,
+			Amount:          decimal.NewFromInt(DefaultReliefAmount),
+			Threshold:       decimal.NewFromInt(DefaultReliefThreshold),
+			DailyLimit:      DefaultReliefDailyLimit,
+			CooldownMinutes: DefaultReliefCooldownMinutes,
+			CreateTime:      time.Now().Format("2006-01-02 15:04:05"),
+		}
+	})
+}
+
+func formatReliefRule(reliefConfig *model.ReliefConfig) string {
+	return fmt.Sprintf("余额低于%s可领取%s积分,每日%d次,间隔%d分钟",
+		utils.FormatAmount(reliefConfig.Threshold),
+		utils.FormatAmount(reliefConfig.Amount),
+		reliefConfig.DailyLimit,
+		reliefConfig.CooldownMinutes)
+}
+
+// buildReliefConfigInlineKeyboardRow 群配置中的救济金按钮
+func buildReliefConfigInlineKeyboardRow(chatGroupId string, callbackDataQueryString string) ([]tgbotapi.InlineKeyboardButton, error) {
+	reliefConfig, err := queryOrCreateReliefConfig(chatGroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	reliefStatus, b := enums.GetGameplayStatus(reliefConfig.Status)
+	if !b {
+		reliefStatus = enums.GameplayStatusOFF
+	}
+
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🆘救济金: %s", reliefStatus.Name), fmt.Sprintf("%s%s", enums.CallbackUpdateReliefStatus.Value, callbackDataQueryString)),
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("💊救济金额: %s", utils.FormatAmount(reliefConfig.Amount)), fmt.Sprintf("%s%s", enums.CallbackUpdateReliefRule.Value, callbackDataQueryString)),
+	), nil
+}
+
+// updateReliefStatusCallBack 开启/关闭救济金
+func updateReliefStatusCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	updateChatGroupStatusCallBack(bot, query, enums.CallbackUpdateReliefStatus, func(chatGroup *model.ChatGroup) (int, error) {
+		reliefConfig, err := queryOrCreateReliefConfig(chatGroup.Id)
+		if err != nil {
+			return 0, err
+		}
+		return reliefConfig.Status, nil
+	}, func(chatGroup *model.ChatGroup, status int) error {
+		reliefConfigUpdate := &model.ReliefConfig{
+			ChatGroupId: chatGroup.Id,
+			Status:      status,
+		}
+		return reliefConfigUpdate.UpdateStatusByChatGroupId(db)
+	})
+}
+
+// updateReliefRuleCallBack 修改救济金规则
+func updateReliefRuleCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateReliefRule)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	reliefConfig, err := queryOrCreateReliefConfig(callBackData["chatGroupId"])
+	if err != nil {
+		return
+	}
+
+	groupConfigInputCallBack(bot, query, enums.CallbackUpdateReliefRule, enums.WaitReliefRule,
+		fmt.Sprintf("当前规则: %s\n"+
+			"请输入救济金规则: 发放积分 余额门槛 每日次数(1-%d) 间隔分钟(0-%d),以空格分隔\n"+
+			"例子: 500 10 1 60\n即余额低于10积分可领取500积分,每日1次,两次领取间隔60分钟",
+			formatReliefRule(reliefConfig), ReliefMaxDailyLimit, ReliefMaxCooldownMinutes))
+}
+
+// updateReliefRule 设置救济金规则
+func updateReliefRule(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCache *common.BotPrivateChatCache) {
+	tgUserId := message.From.ID
+	chatId := message.Chat.ID
+
+	// 校验当前对话人是否为该群管理员
+	err := checkGroupAdmin(botPrivateChatCache.ChatGroupId, tgUserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"tgUserId":    tgUserId,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	reliefConfigUpdate, err := parseReliefRule(message.Text)
+	if err != nil {
+		sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("救济金规则不合法(%s),例子: 500 10 1 60", err.Error()))
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	}
+
+	_, err = queryOrCreateReliefConfig(botPrivateChatCache.ChatGroupId)
+	if err != nil {
+		return
+	}
+
+	reliefConfigUpdate.ChatGroupId = botPrivateChatCache.ChatGroupId
+	err = reliefConfigUpdate.UpdateRuleByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"err":         err,
+		}).Error("设置救济金规则异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!救济金规则: %s。", formatReliefRule(reliefConfigUpdate)))
+	sendMsg.ReplyToMessageID = message.MessageID
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+	// 删除bot与当前对话人的cache
+	redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
+	redisDB.Del(redisDB.Context(), redisKey)
+}
+
+// parseReliefRule 解析救济金规则 发放积分 余额门槛 每日次数 间隔分钟
+func parseReliefRule(text string) (*model.ReliefConfig, error) {
+	parts := strings.Fields(text)
+	if len(parts) != 4 {
+		return nil, errors.New("需输入4项")
+	}
+	amount, err := utils.ParseAmount(parts[0])
+	if err != nil {
+		return nil, errors.New("发放积分错误")
+	}
+	threshold, err := utils.ParseAmount(parts[1])
+	if err != nil {
+		return nil, errors.New("余额门槛错误")
+	}
+	dailyLimit, err := strconv.Atoi(parts[2])
+	if err != nil || dailyLimit < 1 || dailyLimit > ReliefMaxDailyLimit {
+		return nil, fmt.Errorf("每日次数需为1-%d", ReliefMaxDailyLimit)
+	}
+	cooldownMinutes, err := strconv.Atoi(parts[3])
+	if err != nil || cooldownMinutes < 0 || cooldownMinutes > ReliefMaxCooldownMinutes {
+		return nil, fmt.Errorf("间隔分钟需为0-%d", ReliefMaxCooldownMinutes)
+	}
+	return &model.ReliefConfig{
+		Amount:          amount,
+		Threshold:       threshold,
+		DailyLimit:      dailyLimit,
+		CooldownMinutes: cooldownMinutes,
+	}, nil
+}
+
+// handleReliefCommand 领取救济金
+func handleReliefCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	fromUser := message.From
+	messageId := message.MessageID
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	reliefConfig, err := queryOrCreateReliefConfig(chatGroup.Id)
+	if err != nil {
+		return
+	}
+	if reliefConfig.Status != enums.GameplayStatusON.Value {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "本群未开启救济金。")
+		return
+	}
+
+	// 获取用户对应的互斥锁
+	userLockKey := fmt.Sprintf(ChatGroupUserLockKey, tgChatGroupId, fromUser.ID)
+	userLock := getUserLock(userLockKey)
+	userLock.Lock()
+	defer userLock.Unlock()
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    fromUser.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "您还未注册，使用 /register 进行注册。")
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    fromUser.ID,
+			"ChatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("群用户查询异常")
+		return
+	}
+
+	if !chatGroupUser.Balance.LessThan(reliefConfig.Threshold) {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, fmt.Sprintf("积分余额低于%s才能领取救济金哦!您的积分余额为%s。",
+			utils.FormatAmount(reliefConfig.Threshold), utils.FormatAmount(chatGroupUser.Balance)))
+		return
+	}
+
+	now := time.Now()
+	todayMidnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	reliefRecordQuery := &model.ReliefRecord{ChatGroupUserId: chatGroupUser.Id}
+	todayRecords, err := reliefRecordQuery.ListByChatGroupUserIdAndCreateTimeFrom(db, todayMidnight.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("查询救济金领取记录异常")
+		return
+	}
+
+	if len(todayRecords) >= reliefConfig.DailyLimit {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, fmt.Sprintf("今日救济金已领取%d次,明天再来吧!", len(todayRecords)))
+		return
+	}
+
+	// 冷却时间以最近一次领取为准 不受日期切换影响
+	latestRecords, err := reliefRecordQuery.ListByChatGroupUserId(db, 1)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("查询救济金领取记录异常")
+		return
+	}
+	if len(latestRecords) > 0 {
+		lastClaimTime, err := time.ParseInLocation("2006-01-02 15:04:05", latestRecords[0].CreateTime, now.Location())
+		if err == nil {
+			nextClaimTime := lastClaimTime.Add(time.Duration(reliefConfig.CooldownMinutes) * time.Minute)
+			if now.Before(nextClaimTime) {
+				replyAutoDeleteMessage(bot, tgChatGroupId, messageId, fmt.Sprintf("救济金冷却中,请于%s后再领取。", nextClaimTime.Format("2006-01-02 15:04:05")))
+				return
+			}
+		}
+	}
+
+	tx := db.Begin()
+	balanceBefore := chatGroupUser.Balance
+	chatGroupUser.Balance = chatGroupUser.Balance.Add(reliefConfig.Amount)
+	result := tx.Save(&chatGroupUser)
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             result.Error,
+		}).Error("更新用户余额异常")
+		tx.Rollback()
+		return
+	}
+
+	reliefRecord := &model.ReliefRecord{
+		ChatGroupId:     chatGroup.Id,
+		ChatGroupUserId: chatGroupUser.Id,
+		TgUserId:        chatGroupUser.TgUserId,
+		Amount:          reliefConfig.Amount,
+		BalanceBefore:   balanceBefore,
+		CreateTime:      now.Format("2006-01-02 15:04:05"),
+	}
+	err = reliefRecord.Create(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("保存救济金领取记录异常")
+		tx.Rollback()
+		return
+	}
+
+	err = addBalanceLedger(tx, chatGroupUser, balanceBefore, enums.ReliefLedger, &model.BalanceLedger{RefId: reliefRecord.Id})
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("救济金事务提交异常")
+		tx.Rollback()
+		return
+	}
+
+	replyAutoDeleteMessage(bot, tgChatGroupId, messageId, fmt.Sprintf("领取成功!获得救济金%s积分,积分余额%s,今日还可领取%d次。",
+		utils.FormatAmount(reliefConfig.Amount),
+		utils.FormatAmount(chatGroupUser.Balance),
+		reliefConfig.DailyLimit-len(todayRecords)-1))
+}
+
+// buildReliefHistoryText 用户近期救济金领取记录
+func buildReliefHistoryText(chatGroupUserId string) string {
+	reliefRecordQuery := &model.ReliefRecord{ChatGroupUserId: chatGroupUserId}
+	reliefRecords, err := reliefRecordQuery.ListByChatGroupUserId(db, ReliefHistoryLimit)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUserId,
+			"err":             err,
+		}).Error("查询救济金领取记录异常")
+		return ""
+	}
+	if len(reliefRecords) == 0 {
+		return ""
+	}
+
+	text := fmt.Sprintf("近%d次救济金领取记录:\n", ReliefHistoryLimit)
+	for _, record := range reliefRecords {
+		text += fmt.Sprintf("%s 救济金 +%s\n", record.CreateTime, utils.FormatAmount(record.Amount))
+	}
+	return text
+}
@@ -0,0 +1,331 @@
+package bot
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"telegram-dice-bot/internal/enums"
+)
+
+const (
+	// ResultCardFontPath [可选]开奖卡片使用的中文字体文件路径(TTF/OTF) 未配置时使用内置中文字体子集 两者均不可用时无法启动
+	ResultCardFontPath = "RESULT_CARD_FONT_PATH"
+
+	resultCardWidth    = 640
+	resultCardHeight   = 360
+	resultCardDiceSize = 84
+	resultCardDiceGap  = 20
+)
+
+var (
+	resultCardBackgroundColor = color.RGBA{R: 22, G: 78, B: 56, A: 255}
+	resultCardTextColor       = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	resultCardSubTextColor    = color.RGBA{R: 190, G: 220, B: 205, A: 255}
+	resultCardDiceColor       = color.RGBA{R: 250, G: 250, B: 250, A: 255}
+	resultCardPipColor        = color.RGBA{R: 30, G: 30, B: 30, A: 255}
+	resultCardRedPipColor     = color.RGBA{R: 210, G: 40, B: 40, A: 255}
+	resultCardTripletColor    = color.RGBA{R: 235, G: 180, B: 30, A: 255}
+)
+
+// resultCardPips 骰子各点数的点位 以骰面九宫格坐标(0-2)表示
+var resultCardPips = map[int][][2]int{
+	1: {{1, 1}},
+	2: {{0, 0}, {2, 2}},
+	3: {{0, 0}, {1, 1}, {2, 2}},
+	4: {{0, 0}, {2, 0}, {0, 2}, {2, 2}},
+	5: {{0, 0}, {2, 0}, {1, 1}, {0, 2}, {2, 2}},
+	6: {{0, 0}, {2, 0}, {0, 1}, {2, 1}, {0, 2}, {2, 2}},
+}
+
+// resultCardFontFS 内置中文字体子集 由 fonts/README.md 中的步骤生成 构建时嵌入程序
+//
+//go:embed fonts
+var resultCardFontFS embed.FS
+
+// 字体只在首次绘制时解析一次
+var (
+	resultCardFontOnce     sync.Once
+	resultCardCustomFont   *opentype.Font
+	resultCardEmbeddedFont *opentype.Font
+	resultCardGoFont       *opentype.Font
+)
+
+func loadResultCardFonts() {
+	resultCardFontOnce.Do(func() {
+		var err error
+		resultCardGoFont, err = opentype.Parse(gobold.TTF)
+		if err != nil {
+			logrus.WithField("err", err).Error("内置字体解析异常")
+		}
+
+		resultCardEmbeddedFont = loadResultCardEmbeddedFont()
+
+		fontPath := os.Getenv(ResultCardFontPath)
+		if fontPath == "" {
+			return
+		}
+		fontBytes, err := os.ReadFile(fontPath)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"fontPath": fontPath,
+				"err":      err,
+			}).Error("开奖卡片字体读取异常")
+			return
+		}
+		resultCardCustomFont, err = opentype.Parse(fontBytes)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"fontPath": fontPath,
+				"err":      err,
+			}).Error("开奖卡片字体解析异常")
+			resultCardCustomFont = nil
+		}
+	})
+}
+
+// loadResultCardEmbeddedFont 解析内置的中文字体子集 未生成字体文件时返回 nil
+func loadResultCardEmbeddedFont() *opentype.Font {
+	fontPaths, err := fs.Glob(resultCardFontFS, "fonts/*.[ot]tf")
+	if err != nil || len(fontPaths) == 0 {
+		return nil
+	}
+	fontBytes, err := resultCardFontFS.ReadFile(fontPaths[0])
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"fontPath": fontPaths[0],
+			"err":      err,
+		}).Error("内置开奖卡片字体读取异常")
+		return nil
+	}
+	f, err := opentype.Parse(fontBytes)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"fontPath": fontPaths[0],
+			"err":      err,
+		}).Error("内置开奖卡片字体解析异常")
+		return nil
+	}
+	return f
+}
+
+// initResultCardFont 启动时检查开奖卡片中文字体 内置字体未生成且未配置字体文件时终止启动,避免卡片缺少群名称及中文标签
+func initResultCardFont() {
+	loadResultCardFonts()
+	if resultCardCustomFont == nil && resultCardEmbeddedFont == nil {
+		logrus.Fatal("未找到开奖卡片中文字体,请按 internal/bot/fonts/README.md 生成内置字体后重新构建,或配置 ", ResultCardFontPath)
+	}
+}
+
+// resultCardFaces 按优先级返回指定字号的字体 配置的字体优先 其次内置中文字体子集 内置 Go 字体兜底
+func resultCardFaces(size float64) ([]font.Face, error) {
+	loadResultCardFonts()
+	var faces []font.Face
+	for _, f := range []*opentype.Font{resultCardCustomFont, resultCardEmbeddedFont, resultCardGoFont} {
+		if f == nil {
+			continue
+		}
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, err
+		}
+		faces = append(faces, face)
+	}
+	if len(faces) == 0 {
+		return nil, errors.New("无可用字体")
+	}
+	return faces, nil
+}
+
+// resultCardFaceForRune 返回第一个包含该字符字形的字体 均不包含时返回 nil
+func resultCardFaceForRune(faces []font.Face, r rune) font.Face {
+	for _, face := range faces {
+		if _, ok := face.GlyphAdvance(r); ok {
+			return face
+		}
+	}
+	return nil
+}
+
+// resultCardCanRender 判断文本的所有字符是否都能绘制 避免出现缺字方块
+func resultCardCanRender(faces []font.Face, text string) bool {
+	for _, r := range text {
+		if r != ' ' && resultCardFaceForRune(faces, r) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// resultCardLabel 有中文字体时使用中文标签 否则使用英文标签
+func resultCardLabel(faces []font.Face, name string, fallback string) string {
+	if resultCardCanRender(faces, name) {
+		return name
+	}
+	return fallback
+}
+
+func measureResultCardText(faces []font.Face, text string) int {
+	width := fixed.Int26_6(0)
+	for _, r := range text {
+		if face := resultCardFaceForRune(faces, r); face != nil {
+			advance, _ := face.GlyphAdvance(r)
+			width += advance
+		}
+	}
+	return width.Ceil()
+}
+
+// drawResultCardText 逐字选择可用字体绘制文本 x 为文本水平中心,y 为基线
+func drawResultCardText(img *image.RGBA, faces []font.Face, text string, x, y int, c color.Color) {
+	dot := fixed.P(x-measureResultCardText(faces, text)/2, y)
+	for _, r := range text {
+		face := resultCardFaceForRune(faces, r)
+		if face == nil {
+			continue
+		}
+		drawer := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: dot}
+		drawer.DrawString(string(r))
+		dot = drawer.Dot
+	}
+}
+
+// fillResultCardRoundRect 绘制实心圆角矩形
+func fillResultCardRoundRect(img *image.RGBA, rect image.Rectangle, radius int, c color.RGBA) {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			// 四个角以内切圆裁剪
+			cx, cy := x, y
+			if x < rect.Min.X+radius {
+				cx = rect.Min.X + radius
+			} else if x >= rect.Max.X-radius {
+				cx = rect.Max.X - radius - 1
+			}
+			if y < rect.Min.Y+radius {
+				cy = rect.Min.Y + radius
+			} else if y >= rect.Max.Y-radius {
+				cy = rect.Max.Y - radius - 1
+			}
+			if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= radius*radius {
+				img.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// drawResultCardDice 绘制一颗骰子 1点和4点使用红色点
+func drawResultCardDice(img *image.RGBA, rect image.Rectangle, value int) {
+	fillResultCardRoundRect(img, rect, rect.Dx()/6, resultCardDiceColor)
+	pipColor := resultCardPipColor
+	if value == 1 || value == 4 {
+		pipColor = resultCardRedPipColor
+	}
+	pipRadius := rect.Dx() / 11
+	if value == 1 {
+		pipRadius = rect.Dx() / 7
+	}
+	step := rect.Dx() / 4
+	for _, pip := range resultCardPips[value] {
+		center := image.Point{X: rect.Min.X + step*(pip[0]+1), Y: rect.Min.Y + step*(pip[1]+1)}
+		drawRoadMapCircle(img, center, pipRadius, 0, pipColor)
+	}
+}
+
+// resultCardBadge 卡片底部的结果标签
+type resultCardBadge struct {
+	text string
+	c    color.RGBA
+}
+
+// drawResultCardBadge 绘制居中的圆角标签
+func drawResultCardBadge(img *image.RGBA, faces []font.Face, text string, centerX, centerY int, c color.RGBA) {
+	width := measureResultCardText(faces, text) + 32
+	rect := image.Rect(centerX-width/2, centerY-22, centerX+width/2, centerY+22)
+	fillResultCardRoundRect(img, rect, 22, c)
+	drawResultCardText(img, faces, text, centerX, centerY+9, resultCardTextColor)
+}
+
+// renderResultCard 绘制快三开奖卡片PNG 包含群名称、期号、骰子、总点数、大小单双及豹子标记
+// titleDrawn 表示群名称是否已绘制 字体缺字时由调用方在图片说明中补充群名称
+func renderResultCard(groupTitle string, issueNumber string, diceValues []int, total int, bigSmall string, singleDouble string, triplet int) (cardBytes []byte, titleDrawn bool, err error) {
+	titleFaces, err := resultCardFaces(26)
+	if err != nil {
+		return nil, false, err
+	}
+	textFaces, err := resultCardFaces(20)
+	if err != nil {
+		return nil, false, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, resultCardWidth, resultCardHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: resultCardBackgroundColor}, image.Point{}, draw.Src)
+
+	// 群名称 字体不支持时不绘制
+	if groupTitle = strings.TrimSpace(groupTitle); groupTitle != "" && resultCardCanRender(titleFaces, groupTitle) {
+		drawResultCardText(img, titleFaces, groupTitle, resultCardWidth/2, 44, resultCardTextColor)
+		titleDrawn = true
+	}
+	issueText := fmt.Sprintf("第%s期", issueNumber)
+	if !resultCardCanRender(textFaces, issueText) {
+		issueText = fmt.Sprintf("No.%s", issueNumber)
+	}
+	drawResultCardText(img, textFaces, issueText, resultCardWidth/2, 78, resultCardSubTextColor)
+
+	// 骰子 居中排列
+	diceRowWidth := len(diceValues)*resultCardDiceSize + (len(diceValues)-1)*resultCardDiceGap
+	diceLeft := (resultCardWidth - diceRowWidth) / 2
+	for i, value := range diceValues {
+		x := diceLeft + i*(resultCardDiceSize+resultCardDiceGap)
+		drawResultCardDice(img, image.Rect(x, 100, x+resultCardDiceSize, 100+resultCardDiceSize), value)
+	}
+
+	// 总点数
+	totalText := fmt.Sprintf("%s %d", resultCardLabel(titleFaces, "总点数", "TOTAL"), total)
+	drawResultCardText(img, titleFaces, totalText, resultCardWidth/2, 232, resultCardTextColor)
+
+	// 大小、单双、豹子标签
+	bigSmallType, _ := enums.GetGameLotteryType(bigSmall)
+	singleDoubleType, _ := enums.GetGameLotteryType(singleDouble)
+	bigSmallColor := roadMapSmallColor
+	bigSmallFallback := "SMALL"
+	if bigSmall == enums.Big.Value {
+		bigSmallColor = roadMapBigColor
+		bigSmallFallback = "BIG"
+	}
+	singleDoubleFallback := "EVEN"
+	if singleDouble == enums.Single.Value {
+		singleDoubleFallback = "ODD"
+	}
+	badges := []resultCardBadge{
+		{resultCardLabel(titleFaces, bigSmallType.Name, bigSmallFallback), bigSmallColor},
+		{resultCardLabel(titleFaces, singleDoubleType.Name, singleDoubleFallback), color.RGBA{R: 120, G: 90, B: 200, A: 255}},
+	}
+	if triplet == 1 {
+		badges = append(badges, resultCardBadge{resultCardLabel(titleFaces, enums.Triplet.Name, "TRIPLET"), resultCardTripletColor})
+	}
+	badgeGap := 150
+	badgeLeft := resultCardWidth/2 - badgeGap*(len(badges)-1)/2
+	for i, badge := range badges {
+		drawResultCardBadge(img, titleFaces, badge.text, badgeLeft+i*badgeGap, 300, badge.c)
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), titleDrawn, nil
+}
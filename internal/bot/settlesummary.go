@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"html"
+	"sort"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+)
+
+// SettleSummaryMaxWinners 结算汇总最多展示的中奖明细条数 超出部分只计入合计
+const SettleSummaryMaxWinners = 30
+
+// sendSettleSummary 本期全部下注结算后在群内发送结算汇总 中奖用户使用HTML提及以便未私聊机器人的用户也能收到通知
+func sendSettleSummary(bot *tgbotapi.BotAPI, chatGroupId string, issueNumber string, lotteryRecord *model.QuickThereLotteryRecord) {
+	// 结算汇总开关修改后下一期即生效
+	chatGroup, err := model.QueryChatGroupById(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+	if chatGroup.SettleSummaryStatus != enums.GameplayStatusON.Value {
+		return
+	}
+
+	text, err := buildSettleSummaryText(chatGroup, issueNumber, lotteryRecord)
+	if err != nil || text == "" {
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatGroup.TgChatGroupId, text)
+	sendMsg.ParseMode = tgbotapi.ModeHTML
+	sendMsg.DisableWebPagePreview = true
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatGroup.TgChatGroupId)
+}
+
+// buildSettleSummaryText 组装结算汇总 中奖明细按派彩从高到低排列,并统计本期下注总额及已结算下注的庄家盈亏
+func buildSettleSummaryText(chatGroup *model.ChatGroup, issueNumber string, lotteryRecord *model.QuickThereLotteryRecord) (string, error) {
+	betRecordQuery := &model.QuickThereBetRecord{
+		ChatGroupId: chatGroup.Id,
+		IssueNumber: issueNumber,
+	}
+	betRecords, err := betRecordQuery.ListByChatGroupIdAndIssueNumber(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"issueNumber": issueNumber,
+			"err":         err,
+		}).Error("获取用户下注记录异常")
+		return "", err
+	}
+	if len(betRecords) == 0 {
+		return "", nil
+	}
+
+	totalBetAmount := decimal.Zero
+	// settledBetAmount 已结算下注的本金 庄家盈亏只按已结算下注计算
+	settledBetAmount := decimal.Zero
+	totalPayout := decimal.Zero
+	unsettledCount := 0
+	var winRecords []*model.QuickThereBetRecord
+	var chatGroupUserIds []string
+	for _, betRecord := range betRecords {
+		totalBetAmount = totalBetAmount.Add(betRecord.BetAmount)
+		if betRecord.SettleStatus != enums.Settled.Value {
+			unsettledCount++
+			continue
+		}
+		settledBetAmount = settledBetAmount.Add(betRecord.BetAmount)
+		if betRecord.BetResultType != nil && *betRecord.BetResultType == enums.Win.Value && betRecord.BetResultAmount.Valid {
+			totalPayout = totalPayout.Add(betRecord.BetResultAmount.Decimal)
+			winRecords = append(winRecords, betRecord)
+			chatGroupUserIds = append(chatGroupUserIds, betRecord.ChatGroupUserId)
+		}
+	}
+	sort.SliceStable(winRecords, func(i, j int) bool {
+		return winRecords[i].BetResultAmount.Decimal.GreaterThan(winRecords[j].BetResultAmount.Decimal)
+	})
+
+	chatGroupUsers := make(map[string]*model.ChatGroupUser)
+	if len(chatGroupUserIds) > 0 {
+		users, err := model.ListChatGroupUserByIds(db, chatGroupUserIds)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupId": chatGroup.Id,
+				"err":         err,
+			}).Error("查询中奖用户异常")
+			return "", err
+		}
+		for _, user := range users {
+			chatGroupUsers[user.Id] = user
+		}
+	}
+
+	bigSmall, _ := enums.GetGameLotteryType(lotteryRecord.BigSmall)
+	singleDouble, _ := enums.GetGameLotteryType(lotteryRecord.SingleDouble)
+	diceValues := quickThereLotteryDiceValues(lotteryRecord)
+	tripletStr := ""
+	if lotteryRecord.Triplet == 1 {
+		tripletStr = " 豹子"
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<b>第%s期 结算汇总</b>\n", issueNumber))
+	text.WriteString(fmt.Sprintf("开奖: %s=%d %s %s%s\n\n",
+		utils.JoinInts(diceValues, "+"),
+		sumDiceValues(diceValues),
+		bigSmall.Name,
+		singleDouble.Name,
+		tripletStr))
+
+	if len(winRecords) == 0 {
+		text.WriteString("本期无人中奖\n")
+	} else {
+		text.WriteString(fmt.Sprintf("🏆中奖名单(%d笔):\n", len(winRecords)))
+		for i, betRecord := range winRecords {
+			if i >= SettleSummaryMaxWinners {
+				text.WriteString(fmt.Sprintf("...等共%d笔\n", len(winRecords)))
+				break
+			}
+			betType, _ := enums.GetGameLotteryType(betRecord.BetType)
+			text.WriteString(fmt.Sprintf("%s %s %s → 派彩%s\n",
+				settleSummaryMention(chatGroupUsers[betRecord.ChatGroupUserId]),
+				betType.Name,
+				utils.FormatAmount(betRecord.BetAmount),
+				utils.FormatAmount(betRecord.BetResultAmount.Decimal)))
+		}
+	}
+
+	text.WriteString(fmt.Sprintf("\n下注: %d笔 共%s积分\n", len(betRecords), utils.FormatAmount(totalBetAmount)))
+	text.WriteString(fmt.Sprintf("派彩: %s积分\n", utils.FormatAmount(totalPayout)))
+	if unsettledCount > 0 {
+		text.WriteString(fmt.Sprintf("庄家盈亏(不含未结算): %s\n", utils.FormatSignedAmount(settledBetAmount.Sub(totalPayout))))
+		text.WriteString(fmt.Sprintf("另有%d笔结算异常待处理", unsettledCount))
+	} else {
+		text.WriteString(fmt.Sprintf("庄家盈亏: %s", utils.FormatSignedAmount(settledBetAmount.Sub(totalPayout))))
+	}
+	return text.String(), nil
+}
+
+// settleSummaryMention 生成HTML用户提及 用户名需转义
+func settleSummaryMention(chatGroupUser *model.ChatGroupUser) string {
+	if chatGroupUser == nil {
+		return "未知用户"
+	}
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, chatGroupUser.TgUserId, html.EscapeString(rankUserName(chatGroupUser)))
+}
+
+// updateSettleSummaryStatusCallBack 开启/关闭群内结算汇总
+func updateSettleSummaryStatusCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	updateChatGroupStatusCallBack(bot, query, enums.CallbackUpdateSettleSummaryStatus, func(chatGroup *model.ChatGroup) (int, error) {
+		return chatGroup.SettleSummaryStatus, nil
+	}, func(chatGroup *model.ChatGroup, status int) error {
+		chatGroupUpdate := &model.ChatGroup{
+			Id:                  chatGroup.Id,
+			SettleSummaryStatus: status,
+		}
+		if err := chatGroupUpdate.UpdateSettleSummaryStatusById(db); err != nil {
+			return err
+		}
+		chatGroup.SettleSummaryStatus = status
+		return nil
+	})
+}
@@ -0,0 +1,728 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/common"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	DefaultRegisterReward       = 1000
+	DefaultSignInRewardLadder   = "1000"
+	SignInRewardLadderMaxDays   = 31
+	SignInRewardLadderSeparator = ","
+	DefaultDiceRewardTable      = "1=100,2=200,3=300,4=400,5=500,6=1000"
+	DefaultSlotRewardTable      = "64=5000,1=2000,22=2000,43=2000,*=100"
+	RollRewardTableOtherKey     = "*"
+	SignInRecordLimit           = 10
+	// RedisSignInRollKey 当日掷骰签到已掷出的骰子 签到保存失败时重试沿用该结果,避免重新掷骰
+	RedisSignInRollKey = "SIGN_IN_ROLL:CHAT_GROUP_ID:%s:TG_USER_ID:%d:DATE:%s"
+	SignInRollExpire   = 48 * time.Hour
+)
+
+// signInRoll 掷骰签到的骰子结果
+type signInRoll struct {
+	DiceEmoji     string `json:"diceEmoji"`
+	DiceValue     int    `json:"diceValue"`
+	DiceMessageId int    `json:"diceMessageId"`
+}
+
+// slotSymbols 🎰结果值对应的图案 结果值-1 按四进制从低位到高位依次为左中右三列
+var slotSymbols = []string{"BAR", "🍇", "🍋", "7️⃣"}
+
+// queryOrCreateSignInConfig 查询群签到配置 不存在时按默认值创建(兼容历史群)
+func queryOrCreateSignInConfig(chatGroupId string) (*model.SignInConfig, error) {
+	return queryOrCreateConfig(chatGroupId, "签到配置", model.QuerySignInConfigByChatGroupId, func() *model.SignInConfig {
+		return newDefaultSignInConfig(chatGroupId)
+	})
+}
+
+func newDefaultSignInConfig(chatGroupId string) *model.SignInConfig {
+	return &model.SignInConfig{
+		ChatGroupId:    chatGroupId,
+		RegisterReward: decimal.NewFromInt(DefaultRegisterReward),
+		RewardLadder:   DefaultSignInRewardLadder,
+		CreateTime:     time.Now().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// parseSignInRewardLadder 解析签到奖励阶梯 例: 100,200,300,400,500,600,1000
+func parseSignInRewardLadder(rewardLadder string) ([]decimal.Decimal, error) {
+	rewardLadder = strings.ReplaceAll(rewardLadder, "，", SignInRewardLadderSeparator)
+	var ladder []decimal.Decimal
+	for _, str := range strings.Split(rewardLadder, SignInRewardLadderSeparator) {
+		if strings.TrimSpace(str) == "" {
+			continue
+		}
+		reward, err := utils.ParseAmount(str)
+		if err != nil {
+			return nil, err
+		}
+		ladder = append(ladder, reward)
+	}
+	if len(ladder) == 0 || len(ladder) > SignInRewardLadderMaxDays {
+		return nil, fmt.Errorf("签到奖励阶梯需配置1-%d天", SignInRewardLadderMaxDays)
+	}
+	return ladder, nil
+}
+
+// signInReward 连续签到第 streak 天的奖励 超出阶梯天数后按最后一档发放
+func signInReward(ladder []decimal.Decimal, streak int) decimal.Decimal {
+	if streak > len(ladder) {
+		streak = len(ladder)
+	}
+	if streak < 1 {
+		streak = 1
+	}
+	return ladder[streak-1]
+}
+
+// nextSignInStreak 今日签到后的连续签到天数 昨日已签到则累加,断签(或首次签到)从第1天重新计算
+func nextSignInStreak(chatGroupUser *model.ChatGroupUser, now time.Time) int {
+	yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
+	if strings.HasPrefix(chatGroupUser.SignInTime, yesterday) {
+		return chatGroupUser.SignInStreak + 1
+	}
+	return 1
+}
+
+// buildTomorrowSignInRewardText 明日签到奖励提示
+func buildTomorrowSignInRewardText(signInConfig *model.SignInConfig, ladder []decimal.Decimal, streak int) string {
+	if diceEmoji, _, ok := signInDiceEmoji(signInConfig.SignInMode); ok {
+		return fmt.Sprintf("明日签到将由%s结果决定奖励", diceEmoji)
+	}
+	return fmt.Sprintf("明日签到可得%s积分", utils.FormatAmount(signInReward(ladder, streak)))
+}
+
+// signedInToday 今日是否已签到
+func signedInToday(chatGroupUser *model.ChatGroupUser, now time.Time) bool {
+	return strings.HasPrefix(chatGroupUser.SignInTime, now.Format("2006-01-02"))
+}
+
+// currentSignInStreak 当前连续签到天数 今日及昨日均未签到视为已断签
+func currentSignInStreak(chatGroupUser *model.ChatGroupUser, now time.Time) int {
+	yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
+	if signedInToday(chatGroupUser, now) || strings.HasPrefix(chatGroupUser.SignInTime, yesterday) {
+		return chatGroupUser.SignInStreak
+	}
+	return 0
+}
+
+func formatSignInRewardLadder(ladder []decimal.Decimal) string {
+	if len(ladder) == 1 {
+		return utils.FormatAmount(ladder[0])
+	}
+	return fmt.Sprintf("%s~%s(%d天)", utils.FormatAmount(ladder[0]), utils.FormatAmount(ladder[len(ladder)-1]), len(ladder))
+}
+
+// signInDiceEmoji 掷骰签到模式对应的骰子类型及最大结果值
+func signInDiceEmoji(signInMode string) (string, int, bool) {
+	switch signInMode {
+	case enums.DiceSignIn.Value:
+		return "🎲", 6, true
+	case enums.SlotSignIn.Value:
+		return "🎰", 64, true
+	}
+	return "", 0, false
+}
+
+// rollRewardTableOf 当前签到模式使用的奖励表 未配置时使用默认值
+func rollRewardTableOf(signInConfig *model.SignInConfig) string {
+	switch signInConfig.SignInMode {
+	case enums.DiceSignIn.Value:
+		if signInConfig.DiceRewardTable != "" {
+			return signInConfig.DiceRewardTable
+		}
+		return DefaultDiceRewardTable
+	case enums.SlotSignIn.Value:
+		if signInConfig.SlotRewardTable != "" {
+			return signInConfig.SlotRewardTable
+		}
+		return DefaultSlotRewardTable
+	}
+	return ""
+}
+
+// parseRollRewardTable 解析掷骰签到奖励表 例: 1=100,2=200,*=50
+// key 为骰子结果值(1-maxValue),* 表示其余结果(map中以0保存),未配置*时须覆盖全部结果值
+func parseRollRewardTable(rewardTable string, maxValue int) (map[int]decimal.Decimal, error) {
+	rewardTable = strings.ReplaceAll(rewardTable, "，", SignInRewardLadderSeparator)
+	table := make(map[int]decimal.Decimal)
+	for _, item := range strings.Split(rewardTable, SignInRewardLadderSeparator) {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		pair := strings.SplitN(item, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("格式错误: %s", item)
+		}
+		key := strings.TrimSpace(pair[0])
+		value := 0
+		if key != RollRewardTableOtherKey {
+			n, err := strconv.Atoi(key)
+			if err != nil || n < 1 || n > maxValue {
+				return nil, fmt.Errorf("结果值需为1-%d: %s", maxValue, key)
+			}
+			value = n
+		}
+		reward, err := utils.ParseAmount(pair[1])
+		if err != nil {
+			return nil, fmt.Errorf("奖励积分错误: %s", item)
+		}
+		table[value] = reward
+	}
+	if _, ok := table[0]; !ok {
+		for value := 1; value <= maxValue; value++ {
+			if _, ok := table[value]; !ok {
+				return nil, fmt.Errorf("缺少结果值%d的奖励(可用*配置其余结果)", value)
+			}
+		}
+	}
+	return table, nil
+}
+
+// rollReward 按骰子结果查询奖励
+func rollReward(table map[int]decimal.Decimal, diceValue int) decimal.Decimal {
+	if reward, ok := table[diceValue]; ok {
+		return reward
+	}
+	return table[0]
+}
+
+func formatRollRewardTable(table map[int]decimal.Decimal, maxValue int) string {
+	text := ""
+	for value := 1; value <= maxValue; value++ {
+		if reward, ok := table[value]; ok {
+			text += fmt.Sprintf("%d=%s\n", value, utils.FormatAmount(reward))
+		}
+	}
+	if reward, ok := table[0]; ok {
+		text += fmt.Sprintf("其余=%s\n", utils.FormatAmount(reward))
+	}
+	return text
+}
+
+// formatDiceValue 骰子结果展示 🎰展示三列图案
+func formatDiceValue(diceEmoji string, diceValue int) string {
+	if diceEmoji != "🎰" || diceValue < 1 || diceValue > 64 {
+		return fmt.Sprintf("%s%d", diceEmoji, diceValue)
+	}
+	n := diceValue - 1
+	symbols := ""
+	for i := 0; i < 3; i++ {
+		symbols += slotSymbols[n%4]
+		n /= 4
+	}
+	return fmt.Sprintf("%s%s(%d)", diceEmoji, symbols, diceValue)
+}
+
+// buildSignInConfigInlineKeyboardRows 群配置中的注册及签到奖励按钮
+func buildSignInConfigInlineKeyboardRows(chatGroupId string, callbackDataQueryString string) ([][]tgbotapi.InlineKeyboardButton, error) {
+	signInConfig, err := queryOrCreateSignInConfig(chatGroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	ladderText := signInConfig.RewardLadder
+	ladder, err := parseSignInRewardLadder(signInConfig.RewardLadder)
+	if err == nil {
+		ladderText = formatSignInRewardLadder(ladder)
+	}
+
+	signInMode, b := enums.GetSignInMode(signInConfig.SignInMode)
+	if !b {
+		signInMode = enums.FixedSignIn
+	}
+
+	modeRow := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🎯签到模式: %s", signInMode.Name), fmt.Sprintf("%s%s", enums.CallbackUpdateSignInMode.Value, callbackDataQueryString)),
+	)
+	if _, _, ok := signInDiceEmoji(signInMode.Value); ok {
+		modeRow = append(modeRow, tgbotapi.NewInlineKeyboardButtonData("📋掷骰奖励表", fmt.Sprintf("%s%s", enums.CallbackUpdateRollRewardTable.Value, callbackDataQueryString)))
+	}
+
+	return [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🎁注册奖励: %s", utils.FormatAmount(signInConfig.RegisterReward)), fmt.Sprintf("%s%s", enums.CallbackUpdateRegisterReward.Value, callbackDataQueryString)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📅签到奖励: %s", ladderText), fmt.Sprintf("%s%s", enums.CallbackUpdateSignInRewardLadder.Value, callbackDataQueryString)),
+		),
+		modeRow,
+	}, nil
+}
+
+func updateRegisterRewardCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	groupConfigInputCallBack(bot, query, enums.CallbackUpdateRegisterReward, enums.WaitRegisterReward,
+		"请输入新用户注册奖励积分(最多两位小数)")
+}
+
+func updateSignInRewardLadderCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	groupConfigInputCallBack(bot, query, enums.CallbackUpdateSignInRewardLadder, enums.WaitSignInRewardLadder,
+		fmt.Sprintf("请输入连续签到奖励阶梯,按天以逗号分隔(1-%d天)\n例子: 100,200,300,400,500,600,1000\n即第1天100积分…第7天1000积分,超过阶梯天数按最后一档发放,断签后从第1天重新计算", SignInRewardLadderMaxDays))
+}
+
+// groupConfigInputCallBack 管理员修改群配置 记录私聊状态等待输入
+func groupConfigInputCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, callbackPrefix enums.CallbackPrefix, chatStatus enums.BotPrivateChatStatus, tipText string) {
+	chatId := query.Message.Chat.ID
+	fromUser := query.From
+
+	callBackData, err := queryCallBackData(query, callbackPrefix)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	// 设置当前机器人状态
+	err = PrivateChatCacheAddRedis(fromUser.ID, &common.BotPrivateChatCache{
+		ChatStatus:  chatStatus.Value,
+		ChatGroupId: chatGroupId,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"fromUserId":  fromUser.ID,
+			"ChatStatus":  chatStatus.Value,
+			"ChatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("BotChatStatus 设置异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, tipText)
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatId)
+}
+
+// updateRegisterReward 设置注册奖励
+func updateRegisterReward(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCache *common.BotPrivateChatCache) {
+	tgUserId := message.From.ID
+	chatId := message.Chat.ID
+
+	// 校验当前对话人是否为该群管理员
+	err := checkGroupAdmin(botPrivateChatCache.ChatGroupId, tgUserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"tgUserId":    tgUserId,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	registerReward, err := utils.ParseAmount(message.Text)
+	if err != nil {
+		sendMsg := tgbotapi.NewMessage(chatId, "注册奖励不合法,范围(0-9999999999],最多两位小数")
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	}
+
+	_, err = queryOrCreateSignInConfig(botPrivateChatCache.ChatGroupId)
+	if err != nil {
+		return
+	}
+
+	signInConfig := &model.SignInConfig{
+		ChatGroupId:    botPrivateChatCache.ChatGroupId,
+		RegisterReward: registerReward,
+	}
+	err = signInConfig.UpdateRegisterRewardByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId":    botPrivateChatCache.ChatGroupId,
+			"registerReward": registerReward,
+			"err":            err,
+		}).Error("设置注册奖励异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!新用户注册奖励为%s积分。", utils.FormatAmount(registerReward)))
+	sendMsg.ReplyToMessageID = message.MessageID
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+	// 删除bot与当前对话人的cache
+	redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
+	redisDB.Del(redisDB.Context(), redisKey)
+}
+
+// updateSignInRewardLadder 设置连续签到奖励阶梯
+func updateSignInRewardLadder(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCache *common.BotPrivateChatCache) {
+	tgUserId := message.From.ID
+	chatId := message.Chat.ID
+
+	// 校验当前对话人是否为该群管理员
+	err := checkGroupAdmin(botPrivateChatCache.ChatGroupId, tgUserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"tgUserId":    tgUserId,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	ladder, err := parseSignInRewardLadder(message.Text)
+	if err != nil {
+		sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("签到奖励阶梯不合法(%s),例子: 100,200,300,400,500,600,1000", err.Error()))
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	}
+
+	_, err = queryOrCreateSignInConfig(botPrivateChatCache.ChatGroupId)
+	if err != nil {
+		return
+	}
+
+	rewards := make([]string, len(ladder))
+	ladderText := ""
+	for i, reward := range ladder {
+		rewards[i] = utils.FormatAmount(reward)
+		ladderText += fmt.Sprintf("第%d天: %s积分\n", i+1, rewards[i])
+	}
+
+	signInConfig := &model.SignInConfig{
+		ChatGroupId:  botPrivateChatCache.ChatGroupId,
+		RewardLadder: strings.Join(rewards, SignInRewardLadderSeparator),
+	}
+	err = signInConfig.UpdateRewardLadderByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId":  botPrivateChatCache.ChatGroupId,
+			"rewardLadder": signInConfig.RewardLadder,
+			"err":          err,
+		}).Error("设置签到奖励阶梯异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!连续签到奖励如下:\n%s之后每天: %s积分\n断签后从第1天重新计算。", ladderText, rewards[len(rewards)-1]))
+	sendMsg.ReplyToMessageID = message.MessageID
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+	// 删除bot与当前对话人的cache
+	redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
+	redisDB.Del(redisDB.Context(), redisKey)
+}
+
+// updateSignInModeCallBack 切换签到模式 固定奖励 -> 🎲骰子 -> 🎰老虎机
+func updateSignInModeCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+	fromUser := query.From
+
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateSignInMode)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	signInConfig, err := queryOrCreateSignInConfig(chatGroupId)
+	if err != nil {
+		return
+	}
+
+	signInConfigUpdate := &model.SignInConfig{ChatGroupId: chatGroupId}
+	switch signInConfig.SignInMode {
+	case enums.FixedSignIn.Value:
+		signInConfigUpdate.SignInMode = enums.DiceSignIn.Value
+	case enums.DiceSignIn.Value:
+		signInConfigUpdate.SignInMode = enums.SlotSignIn.Value
+	default:
+		signInConfigUpdate.SignInMode = enums.FixedSignIn.Value
+	}
+
+	err = signInConfigUpdate.UpdateSignInModeByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"signInMode":  signInConfigUpdate.SignInMode,
+			"err":         err,
+		}).Error("更新签到模式异常")
+		return
+	}
+
+	inlineKeyboardMarkup, err := buildChatGroupInlineKeyboardMarkup(query, chatGroup)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"err": err,
+		}).Error("组装群组配置内联键盘异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("点击修改【%s】相关配置:", chatGroup.TgChatGroupTitle))
+	sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// updateRollRewardTableCallBack 修改当前掷骰签到模式的奖励表
+func updateRollRewardTableCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateRollRewardTable)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	signInConfig, err := queryOrCreateSignInConfig(callBackData["chatGroupId"])
+	if err != nil {
+		return
+	}
+
+	diceEmoji, maxValue, ok := signInDiceEmoji(signInConfig.SignInMode)
+	if !ok {
+		answerCallbackQuery(bot, query, "当前为固定奖励签到模式,无需配置掷骰奖励表", true)
+		return
+	}
+
+	tipText := fmt.Sprintf("请输入%s签到奖励表,格式为 结果值=奖励积分,以逗号分隔,*表示其余结果\n", diceEmoji)
+	if signInConfig.SignInMode == enums.SlotSignIn.Value {
+		tipText += "🎰结果值1-64,其中64为7️⃣7️⃣7️⃣,1为BAR BAR BAR,22为🍇🍇🍇,43为🍋🍋🍋\n"
+	}
+	tipText += fmt.Sprintf("例子: %s\n", rollRewardTableOf(&model.SignInConfig{SignInMode: signInConfig.SignInMode}))
+
+	table, err := parseRollRewardTable(rollRewardTableOf(signInConfig), maxValue)
+	if err == nil {
+		tipText += "当前奖励表:\n" + formatRollRewardTable(table, maxValue)
+	}
+
+	groupConfigInputCallBack(bot, query, enums.CallbackUpdateRollRewardTable, enums.WaitRollRewardTable, tipText)
+}
+
+// updateRollRewardTable 设置掷骰签到奖励表
+func updateRollRewardTable(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCache *common.BotPrivateChatCache) {
+	tgUserId := message.From.ID
+	chatId := message.Chat.ID
+
+	// 校验当前对话人是否为该群管理员
+	err := checkGroupAdmin(botPrivateChatCache.ChatGroupId, tgUserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"tgUserId":    tgUserId,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	signInConfig, err := queryOrCreateSignInConfig(botPrivateChatCache.ChatGroupId)
+	if err != nil {
+		return
+	}
+
+	diceEmoji, maxValue, ok := signInDiceEmoji(signInConfig.SignInMode)
+	if !ok {
+		sendMsg := tgbotapi.NewMessage(chatId, "当前为固定奖励签到模式,无需配置掷骰奖励表")
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	}
+
+	table, err := parseRollRewardTable(message.Text, maxValue)
+	if err != nil {
+		sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("奖励表不合法(%s),例子: %s", err.Error(), rollRewardTableOf(&model.SignInConfig{SignInMode: signInConfig.SignInMode})))
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	}
+
+	var items []string
+	for value := 1; value <= maxValue; value++ {
+		if reward, ok := table[value]; ok {
+			items = append(items, fmt.Sprintf("%d=%s", value, utils.FormatAmount(reward)))
+		}
+	}
+	if reward, ok := table[0]; ok {
+		items = append(items, fmt.Sprintf("%s=%s", RollRewardTableOtherKey, utils.FormatAmount(reward)))
+	}
+
+	signInConfigUpdate := &model.SignInConfig{ChatGroupId: botPrivateChatCache.ChatGroupId}
+	if signInConfig.SignInMode == enums.DiceSignIn.Value {
+		signInConfigUpdate.DiceRewardTable = strings.Join(items, SignInRewardLadderSeparator)
+		err = signInConfigUpdate.UpdateDiceRewardTableByChatGroupId(db)
+	} else {
+		signInConfigUpdate.SlotRewardTable = strings.Join(items, SignInRewardLadderSeparator)
+		err = signInConfigUpdate.UpdateSlotRewardTableByChatGroupId(db)
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"signInMode":  signInConfig.SignInMode,
+			"err":         err,
+		}).Error("设置掷骰签到奖励表异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!%s签到奖励表如下:\n%s", diceEmoji, formatRollRewardTable(table, maxValue)))
+	sendMsg.ReplyToMessageID = message.MessageID
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+	// 删除bot与当前对话人的cache
+	redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
+	redisDB.Del(redisDB.Context(), redisKey)
+}
+
+// handleSignLogCommand 查询本人近期签到记录(含掷骰结果及骰子消息链接)
+func handleSignLogCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	messageId := message.MessageID
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		TgUserId:    message.From.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+	chatGroupUser, err := chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "您还未注册，使用 /register 进行注册。")
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    message.From.ID,
+			"ChatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("群用户查询异常")
+		return
+	}
+
+	signInRecordQuery := &model.SignInRecord{ChatGroupUserId: chatGroupUser.Id}
+	signInRecords, err := signInRecordQuery.ListByChatGroupUserId(db, SignInRecordLimit)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("查询签到记录异常")
+		return
+	}
+
+	if len(signInRecords) == 0 {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "暂无签到记录")
+		return
+	}
+
+	text := fmt.Sprintf("近%d次签到记录如下:\n", SignInRecordLimit)
+	for _, record := range signInRecords {
+		text += fmt.Sprintf("%s 连续%d天 +%s", record.CreateTime, record.Streak, utils.FormatAmount(record.Reward))
+		if record.DiceEmoji != "" {
+			text += fmt.Sprintf(" %s %s", formatDiceValue(record.DiceEmoji, record.DiceValue), buildMessageLink(record.TgChatGroupId, message.Chat, record.DiceMessageId))
+		}
+		text += "\n"
+	}
+	replyAutoDeleteMessage(bot, tgChatGroupId, messageId, text)
+}
+
+// rollSignInDice 掷骰签到 当日已掷出但未保存成功的结果直接沿用,否则发送骰子并立即记录结果
+// 返回骰子结果及本次是否新发送了骰子
+func rollSignInDice(bot *tgbotapi.BotAPI, message *tgbotapi.Message, chatGroup *model.ChatGroup, diceEmoji string, now time.Time) (*signInRoll, bool, error) {
+	redisKey := fmt.Sprintf(RedisSignInRollKey, chatGroup.Id, message.From.ID, now.Format("2006-01-02"))
+	rollJson, err := redisDB.Get(redisDB.Context(), redisKey).Result()
+	if err == nil {
+		roll := &signInRoll{}
+		err = json.Unmarshal([]byte(rollJson), roll)
+		if err == nil {
+			return roll, false, nil
+		}
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("签到骰子结果解析异常")
+	} else if !errors.Is(err, redis.Nil) {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("redis获取签到骰子结果异常")
+		return nil, false, err
+	}
+
+	diceConfig := tgbotapi.NewDiceWithEmoji(message.Chat.ID, diceEmoji)
+	diceConfig.ReplyToMessageID = message.MessageID
+	diceMsg, err := bot.Send(diceConfig)
+	if err != nil {
+		logrus.WithField("err", err).Error("发送签到骰子消息异常")
+		blockedOrKicked(err, message.Chat.ID)
+		return nil, false, err
+	}
+
+	roll := &signInRoll{
+		DiceEmoji:     diceEmoji,
+		DiceValue:     diceMsg.Dice.Value,
+		DiceMessageId: diceMsg.MessageID,
+	}
+	rollBytes, _ := json.Marshal(roll)
+	err = redisDB.Set(redisDB.Context(), redisKey, rollBytes, SignInRollExpire).Err()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("redis记录签到骰子结果异常")
+	}
+	return roll, true, nil
+}
+
+// clearSignInRoll 签到保存成功后删除已记录的骰子结果
+func clearSignInRoll(chatGroup *model.ChatGroup, tgUserId int64, now time.Time) {
+	redisKey := fmt.Sprintf(RedisSignInRollKey, chatGroup.Id, tgUserId, now.Format("2006-01-02"))
+	err := redisDB.Del(redisDB.Context(), redisKey).Err()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"redisKey": redisKey,
+			"err":      err,
+		}).Error("redis删除签到骰子结果异常")
+	}
+}
@@ -0,0 +1,436 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+// transferBetweenChatGroupUsers 同一群内由转出用户向转入用户转让积分并记录双方流水
+// 按群转让规则校验后,超过审批门槛的转让仅记录为待审批,由管理员审批通过后再执行
+func transferBetweenChatGroupUsers(chatGroup *model.ChatGroup, sendGroupUser *model.ChatGroupUser, groupUser *model.ChatGroupUser, amount decimal.Decimal) (transferRecord *model.TransferRecord, sender *model.ChatGroupUser, receiver *model.ChatGroupUser, tipMsg string, err error) {
+	transferConfig, err := queryOrCreateTransferConfig(chatGroup.Id)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+
+	house, err := queryTransferHouseUser(transferConfig, transferConfig.FeeMode)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	tgUserIds := []int64{sendGroupUser.TgUserId, groupUser.TgUserId}
+	if house != nil {
+		tgUserIds = append(tgUserIds, house.TgUserId)
+	}
+	unlock := lockChatGroupUsers(chatGroup.TgChatGroupId, tgUserIds...)
+	defer unlock()
+
+	tx := db.Begin()
+
+	// 重新查询用户信息
+	receiver, err = groupUser.QueryById(tx)
+	if err == nil {
+		sender, err = sendGroupUser.QueryById(tx)
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"sendChatGroupUserId": sendGroupUser.Id,
+			"chatGroupUserId":     groupUser.Id,
+			"err":                 err,
+		}).Error("查询用户信息异常")
+		tx.Rollback()
+		return nil, nil, nil, "", err
+	}
+
+	tipMsg, err = checkTransferRule(tx, transferConfig, sender, amount)
+	if err != nil || tipMsg != "" {
+		tx.Rollback()
+		return nil, sender, receiver, tipMsg, err
+	}
+
+	fee := calcTransferFee(transferConfig, amount)
+	if sender.Balance.LessThan(amount.Add(fee)) {
+		tx.Rollback()
+		return nil, sender, receiver, fmt.Sprintf("积分余额不足,本次转让需%s积分(含手续费%s),您的积分余额为%s。", utils.FormatAmount(amount.Add(fee)), utils.FormatAmount(fee), utils.FormatAmount(sender.Balance)), nil
+	}
+
+	transferRecord = &model.TransferRecord{
+		ChatGroupId:             chatGroup.Id,
+		SenderChatGroupUserId:   sender.Id,
+		SenderTgUserId:          sender.TgUserId,
+		ReceiverChatGroupUserId: receiver.Id,
+		ReceiverTgUserId:        receiver.TgUserId,
+		Amount:                  amount,
+		Fee:                     fee,
+		FeeMode:                 transferConfig.FeeMode,
+		Status:                  enums.TransferCompleted.Value,
+		CreateTime:              time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	if transferConfig.ApprovalThreshold.IsPositive() && amount.GreaterThan(transferConfig.ApprovalThreshold) {
+		// 超过审批门槛 仅记录待审批 审批通过时再扣除积分
+		transferRecord.Status = enums.TransferPending.Value
+	}
+
+	err = transferRecord.Create(tx)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"sendChatGroupUserId": sender.Id,
+			"chatGroupUserId":     receiver.Id,
+			"err":                 err,
+		}).Error("保存转让记录异常")
+		tx.Rollback()
+		return nil, nil, nil, "", err
+	}
+
+	if transferRecord.Status == enums.TransferCompleted.Value {
+		if house != nil {
+			house, err = house.QueryById(tx)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"chatGroupUserId": transferConfig.HouseChatGroupUserId,
+					"err":             err,
+				}).Error("查询收费账户异常")
+				tx.Rollback()
+				return nil, nil, nil, "", err
+			}
+		}
+		err = executeTransfer(tx, transferRecord, sender, receiver, house)
+		if err != nil {
+			tx.Rollback()
+			return nil, nil, nil, "", err
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("转让积分事务提交异常")
+		tx.Rollback()
+		return nil, nil, nil, "", err
+	}
+
+	return transferRecord, sender, receiver, "", nil
+}
+
+// executeTransfer 执行转让: 转出方扣除转让积分及手续费,转入方增加转让积分,手续费归收费账户或销毁
+// house 为空表示销毁手续费
+func executeTransfer(tx *gorm.DB, transferRecord *model.TransferRecord, sender *model.ChatGroupUser, receiver *model.ChatGroupUser, house *model.ChatGroupUser) error {
+	if house != nil {
+		if house.Id == sender.Id {
+			house = sender
+		} else if house.Id == receiver.Id {
+			house = receiver
+		}
+	}
+
+	// 记录双方积分流水
+	senderBalanceBefore := sender.Balance
+	sender.Balance = sender.Balance.Sub(transferRecord.Amount)
+	err := addBalanceLedger(tx, sender, senderBalanceBefore, enums.TransferOutLedger, &model.BalanceLedger{RefId: receiver.Id})
+	if err != nil {
+		return err
+	}
+	receiverBalanceBefore := receiver.Balance
+	receiver.Balance = receiver.Balance.Add(transferRecord.Amount)
+	err = addBalanceLedger(tx, receiver, receiverBalanceBefore, enums.TransferInLedger, &model.BalanceLedger{RefId: sender.Id})
+	if err != nil {
+		return err
+	}
+
+	if transferRecord.Fee.IsPositive() {
+		senderBalanceBefore = sender.Balance
+		sender.Balance = sender.Balance.Sub(transferRecord.Fee)
+		err = addBalanceLedger(tx, sender, senderBalanceBefore, enums.TransferFeeLedger, &model.BalanceLedger{RefId: transferRecord.Id})
+		if err != nil {
+			return err
+		}
+		if house != nil {
+			houseBalanceBefore := house.Balance
+			house.Balance = house.Balance.Add(transferRecord.Fee)
+			err = addBalanceLedger(tx, house, houseBalanceBefore, enums.TransferFeeLedger, &model.BalanceLedger{RefId: transferRecord.Id})
+			if err != nil {
+				return err
+			}
+			// 记录手续费归入的账户 收费账户可能被修改,对账时以此为准
+			transferRecord.HouseChatGroupUserId = house.Id
+			err = transferRecord.UpdateHouseChatGroupUserIdById(tx)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"transferRecordId": transferRecord.Id,
+					"err":              err,
+				}).Error("更新转让手续费收费账户异常")
+				return err
+			}
+		}
+	}
+
+	chatGroupUsers := []*model.ChatGroupUser{sender, receiver}
+	if house != nil && house != sender && house != receiver {
+		chatGroupUsers = append(chatGroupUsers, house)
+	}
+	for _, chatGroupUser := range chatGroupUsers {
+		if result := tx.Save(chatGroupUser); result.Error != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupUserId": chatGroupUser.Id,
+				"err":             result.Error,
+			}).Error("更新转让用户积分异常")
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// handleGiveCommand 群内转让积分 回复某人消息发送 /give 100,或 /give @username 100
+func handleGiveCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	fromUser := message.From
+	messageId := message.MessageID
+
+	args := strings.Fields(message.CommandArguments())
+	var username string
+	var amountStr string
+	if len(args) == 2 && strings.HasPrefix(args[0], "@") {
+		username = strings.TrimPrefix(args[0], "@")
+		amountStr = args[1]
+	} else if len(args) == 1 && message.ReplyToMessage != nil && message.ReplyToMessage.From != nil {
+		amountStr = args[0]
+	} else {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "请回复对方消息发送 /give 100,或发送 /give @username 100")
+		return
+	}
+
+	amount, err := utils.ParseAmount(amountStr)
+	if err != nil {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "积分不合法,可转让积分范围(0-9999999999],最多两位小数")
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	// 查询发起转让用户信息
+	sendChatGroupUser := &model.ChatGroupUser{
+		TgUserId:    fromUser.ID,
+		ChatGroupId: chatGroup.Id,
+	}
+	sendGroupUser, err := sendChatGroupUser.QueryByTgUserIdAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "您还未注册，使用 /register 进行注册。")
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"TgUserId":    fromUser.ID,
+			"ChatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("群用户查询异常")
+		return
+	}
+
+	// 查询被转让用户信息
+	chatGroupUserQuery := &model.ChatGroupUser{ChatGroupId: chatGroup.Id}
+	var groupUser *model.ChatGroupUser
+	if username != "" {
+		chatGroupUserQuery.Username = username
+		groupUser, err = chatGroupUserQuery.QueryByUsernameAndChatGroupId(db)
+	} else {
+		chatGroupUserQuery.TgUserId = message.ReplyToMessage.From.ID
+		groupUser, err = chatGroupUserQuery.QueryByTgUserIdAndChatGroupId(db)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "对方还未在本群注册,无法转让。")
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"username":    username,
+			"ChatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("群用户查询异常")
+		return
+	}
+
+	if sendGroupUser.Id == groupUser.Id {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, "不可对自己转让积分!")
+		return
+	}
+
+	transferConfig, err := queryOrCreateTransferConfig(chatGroup.Id)
+	if err != nil {
+		return
+	}
+	fee := calcTransferFee(transferConfig, amount)
+	if sendGroupUser.Balance.LessThan(amount.Add(fee)) {
+		replyAutoDeleteMessage(bot, tgChatGroupId, messageId, fmt.Sprintf("积分余额不足,本次转让需%s积分(含手续费%s),您的积分余额为%s。", utils.FormatAmount(amount.Add(fee)), utils.FormatAmount(fee), utils.FormatAmount(sendGroupUser.Balance)))
+		return
+	}
+
+	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+		"chatGroupId":         chatGroup.Id,
+		"sendChatGroupUserId": sendGroupUser.Id,
+		"chatGroupUserId":     groupUser.Id,
+		"amount":              amount.String(),
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("内联键盘回调参数存入redis异常")
+		return
+	}
+
+	callbackDataQueryString := utils.MapToQueryString(map[string]string{
+		"callbackDataKey": callbackDataKey,
+	})
+
+	confirmText := fmt.Sprintf("确认向【%s】转让%s积分?", giveUserName(groupUser), utils.FormatAmount(amount))
+	if fee.IsPositive() {
+		confirmText = fmt.Sprintf("确认向【%s】转让%s积分?(需额外支付手续费%s积分)", giveUserName(groupUser), utils.FormatAmount(amount), utils.FormatAmount(fee))
+	}
+	sendMsg := tgbotapi.NewMessage(tgChatGroupId, confirmText)
+	sendMsg.ReplyToMessageID = messageId
+	sendMsg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅确认转让", fmt.Sprintf("%s%s", enums.CallbackGiveConfirm.Value, callbackDataQueryString)),
+			tgbotapi.NewInlineKeyboardButtonData("❌取消", fmt.Sprintf("%s%s", enums.CallbackGiveCancel.Value, callbackDataQueryString)),
+		),
+	)
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, tgChatGroupId)
+}
+
+// giveCallBack 群内转让积分确认/取消 仅发起人可操作,按钮只能生效一次
+func giveCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, callbackPrefix enums.CallbackPrefix) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	callBackData, err := queryCallBackData(query, callbackPrefix)
+	if err != nil {
+		answerCallbackQuery(bot, query, "操作已过期!", true)
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, callBackData["chatGroupId"])
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": callBackData["chatGroupId"],
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	sendChatGroupUser := &model.ChatGroupUser{Id: callBackData["sendChatGroupUserId"]}
+	sendGroupUser, err := sendChatGroupUser.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": sendChatGroupUser.Id,
+			"err":             err,
+		}).Error("查询用户信息异常")
+		return
+	}
+	if sendGroupUser.TgUserId != query.From.ID {
+		answerCallbackQuery(bot, query, "仅转让发起人可操作!", true)
+		return
+	}
+
+	chatGroupUser := &model.ChatGroupUser{Id: callBackData["chatGroupUserId"]}
+	groupUser, err := chatGroupUser.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": chatGroupUser.Id,
+			"err":             err,
+		}).Error("查询用户信息异常")
+		return
+	}
+
+	amount, err := decimal.NewFromString(callBackData["amount"])
+	if err != nil {
+		logrus.WithField("amount", callBackData["amount"]).Error("转让积分解析异常")
+		return
+	}
+
+	// 原子删除回调参数以认领本次点击 只有删除成功的点击才会处理,重复点击或删除失败均不会再次转让
+	queryStringToMap, err := utils.QueryStringToMap(query.Data[len(callbackPrefix.Value):])
+	if err != nil {
+		return
+	}
+	callbackDataKey := queryStringToMap["callbackDataKey"]
+	claimed, err := ButtonCallBackDataDelRedis(callbackDataKey)
+	if err != nil {
+		answerCallbackQuery(bot, query, "操作失败,请稍后再试!", true)
+		return
+	} else if !claimed {
+		answerCallbackQuery(bot, query, "该转让已处理!", true)
+		return
+	}
+
+	var text string
+	if callbackPrefix == enums.CallbackGiveCancel {
+		text = fmt.Sprintf("已取消向【%s】转让%s积分。", giveUserName(groupUser), utils.FormatAmount(amount))
+	} else {
+		transferRecord, sender, receiver, tipMsg, err := transferBetweenChatGroupUsers(chatGroup, sendGroupUser, groupUser, amount)
+		if err != nil {
+			// 转让未提交 恢复回调参数以便重试
+			err = ButtonCallBackDataRestoreRedis(callbackDataKey, callBackData)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"callbackDataKey": callbackDataKey,
+					"err":             err,
+				}).Error("恢复转让回调参数异常")
+			}
+			answerCallbackQuery(bot, query, "转让失败,请稍后再试!", true)
+			return
+		} else if tipMsg != "" {
+			text = fmt.Sprintf("向【%s】转让%s积分失败: %s", giveUserName(groupUser), utils.FormatAmount(amount), tipMsg)
+		} else if transferRecord.Status == enums.TransferPending.Value {
+			text = fmt.Sprintf("【%s】向【%s】转让%s积分超过审批门槛,已提交管理员审批,审批通过后到账。", giveUserName(sender), giveUserName(receiver), utils.FormatAmount(amount))
+			notifyTransferApproval(bot, chatGroup, transferRecord, sender, receiver)
+		} else {
+			text = fmt.Sprintf("转让成功!【%s】向【%s】转让%s积分%s。", giveUserName(sender), giveUserName(receiver), utils.FormatAmount(amount), formatTransferFee(transferRecord))
+			notifyTransferCompleted(bot, chatGroup, transferRecord, sender, receiver)
+		}
+	}
+
+	answerCallbackQuery(bot, query, "", false)
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	_, err = sendMessage(bot, &editMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// formatTransferFee 转让成功提示中的手续费说明
+func formatTransferFee(transferRecord *model.TransferRecord) string {
+	if !transferRecord.Fee.IsPositive() {
+		return ""
+	}
+	return fmt.Sprintf(",手续费%s积分", utils.FormatAmount(transferRecord.Fee))
+}
+
+// notifyTransferCompleted 转让完成后私聊通知双方
+func notifyTransferCompleted(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, transferRecord *model.TransferRecord, sender *model.ChatGroupUser, receiver *model.ChatGroupUser) {
+	sendNotifyMsg := tgbotapi.NewMessage(sender.TgUserId, fmt.Sprintf("【%s】您向用户【%s】转让%s积分%s,您的积分余额为%s。", chatGroup.TgChatGroupTitle, giveUserName(receiver), utils.FormatAmount(transferRecord.Amount), formatTransferFee(transferRecord), utils.FormatAmount(sender.Balance)))
+	_, err := sendMessage(bot, &sendNotifyMsg)
+	blockedOrKicked(err, sender.TgUserId)
+
+	receiveNotifyMsg := tgbotapi.NewMessage(receiver.TgUserId, fmt.Sprintf("【%s】您收到用户【%s】转让的%s积分,您的积分余额为%s。", chatGroup.TgChatGroupTitle, giveUserName(sender), utils.FormatAmount(transferRecord.Amount), utils.FormatAmount(receiver.Balance)))
+	_, err = sendMessage(bot, &receiveNotifyMsg)
+	blockedOrKicked(err, receiver.TgUserId)
+}
+
+func giveUserName(chatGroupUser *model.ChatGroupUser) string {
+	if chatGroupUser.Username != "" {
+		return fmt.Sprintf("@%s", chatGroupUser.Username)
+	}
+	return fmt.Sprintf("%d", chatGroupUser.TgUserId)
+}
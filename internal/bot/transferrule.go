@@ -0,0 +1,782 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/common"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"telegram-dice-bot/internal/utils"
+	"time"
+)
+
+const (
+	TransferMaxFeeRate       = 50
+	TransferApprovalPageSize = 10
+)
+
+// queryOrCreateTransferConfig 查询群转让规则 不存在时按默认值(不限制、无手续费、无需审批)创建
+func queryOrCreateTransferConfig(chatGroupId string) (*model.TransferConfig, error) {
+	return queryOrCreateConfig(chatGroupId, "转让规则", model.QueryTransferConfigByChatGroupId, func() *model.TransferConfig {
+		return &model.TransferConfig{
+			ChatGroupId:       chatGroupId,
+			DailyAmountLimit:  decimal.Zero,
+			FeeRate:           decimal.Zero,
+			FeeMode:           enums.BurnTransferFee.Value,
+			MinTurnover:       decimal.Zero,
+			ApprovalThreshold: decimal.Zero,
+			CreateTime:        time.Now().Format("2006-01-02 15:04:05"),
+		}
+	})
+}
+
+// queryTransferHouseUser 查询手续费收费账户 手续费去向为销毁或未设置收费账户时返回空
+func queryTransferHouseUser(transferConfig *model.TransferConfig, feeMode string) (*model.ChatGroupUser, error) {
+	if feeMode != enums.HouseTransferFee.Value || transferConfig.HouseChatGroupUserId == "" {
+		return nil, nil
+	}
+	houseQuery := &model.ChatGroupUser{Id: transferConfig.HouseChatGroupUserId}
+	house, err := houseQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupUserId": transferConfig.HouseChatGroupUserId,
+			"err":             err,
+		}).Error("查询收费账户异常")
+		return nil, err
+	}
+	return house, nil
+}
+
+// calcTransferFee 按比例计算手续费 不足一分的部分不收取
+func calcTransferFee(transferConfig *model.TransferConfig, amount decimal.Decimal) decimal.Decimal {
+	return utils.RoundPayout(amount.Mul(transferConfig.FeeRate).Div(decimal.NewFromInt(100)))
+}
+
+// checkTransferRule 校验转出方是否满足转让规则 不满足时返回提示信息
+// 每日次数及积分统计当日已完成和待审批的转让,已拒绝的不计入
+func checkTransferRule(tx *gorm.DB, transferConfig *model.TransferConfig, sender *model.ChatGroupUser, amount decimal.Decimal) (string, error) {
+	now := time.Now()
+
+	if transferConfig.MinAccountAgeHours > 0 {
+		createTime, err := time.ParseInLocation("2006-01-02 15:04:05", sender.CreateTime, time.Local)
+		if err == nil && now.Sub(createTime) < time.Duration(transferConfig.MinAccountAgeHours)*time.Hour {
+			return fmt.Sprintf("注册满%d小时后才可转让积分。", transferConfig.MinAccountAgeHours), nil
+		}
+	}
+
+	if transferConfig.MinTurnover.IsPositive() {
+		betRecordQuery := &model.QuickThereBetRecord{ChatGroupUserId: sender.Id}
+		betRecords, err := betRecordQuery.ListByChatGroupUserIdAndCreateTimeFrom(tx, sender.CreateTime)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupUserId": sender.Id,
+				"err":             err,
+			}).Error("查询下注记录异常")
+			return "", err
+		}
+		turnover := decimal.Zero
+		for _, betRecord := range betRecords {
+			if betRecord.SettleStatus == enums.Settled.Value {
+				turnover = turnover.Add(betRecord.BetAmount)
+			}
+		}
+		if turnover.LessThan(transferConfig.MinTurnover) {
+			return fmt.Sprintf("累计下注流水达到%s积分后才可转让积分,您当前的流水为%s积分。", utils.FormatAmount(transferConfig.MinTurnover), utils.FormatAmount(turnover)), nil
+		}
+	}
+
+	if transferConfig.DailyCountLimit > 0 || transferConfig.DailyAmountLimit.IsPositive() {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		transferRecordQuery := &model.TransferRecord{SenderChatGroupUserId: sender.Id}
+		transferRecords, err := transferRecordQuery.ListBySenderChatGroupUserIdAndCreateTimeFrom(tx, today.Format("2006-01-02 15:04:05"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupUserId": sender.Id,
+				"err":             err,
+			}).Error("查询转让记录异常")
+			return "", err
+		}
+		todayCount := 0
+		todayAmount := decimal.Zero
+		for _, transferRecord := range transferRecords {
+			if transferRecord.Status != enums.TransferRejected.Value {
+				todayCount++
+				todayAmount = todayAmount.Add(transferRecord.Amount)
+			}
+		}
+		if transferConfig.DailyCountLimit > 0 && todayCount >= transferConfig.DailyCountLimit {
+			return fmt.Sprintf("今日转让次数已达上限(%d次)。", transferConfig.DailyCountLimit), nil
+		}
+		if transferConfig.DailyAmountLimit.IsPositive() && todayAmount.Add(amount).GreaterThan(transferConfig.DailyAmountLimit) {
+			return fmt.Sprintf("超过每日转让积分上限%s,您今日已转让%s积分。", utils.FormatAmount(transferConfig.DailyAmountLimit), utils.FormatAmount(todayAmount)), nil
+		}
+	}
+
+	return "", nil
+}
+
+func formatTransferLimit(value decimal.Decimal, unit string) string {
+	if !value.IsPositive() {
+		return "不限"
+	}
+	return fmt.Sprintf("%s%s", utils.FormatAmount(value), unit)
+}
+
+func formatTransferRule(transferConfig *model.TransferConfig) string {
+	return fmt.Sprintf("每日转出次数: %s\n每日转出积分: %s\n手续费: %s%%\n注册时长要求: %s\n累计流水要求: %s\n审批门槛: %s",
+		formatTransferLimit(decimal.NewFromInt(int64(transferConfig.DailyCountLimit)), "次"),
+		formatTransferLimit(transferConfig.DailyAmountLimit, "积分"),
+		utils.FormatAmount(transferConfig.FeeRate),
+		formatTransferLimit(decimal.NewFromInt(int64(transferConfig.MinAccountAgeHours)), "小时"),
+		formatTransferLimit(transferConfig.MinTurnover, "积分"),
+		formatTransferLimit(transferConfig.ApprovalThreshold, "积分"))
+}
+
+// buildTransferConfigInlineKeyboardRows 群配置中的转让规则按钮
+func buildTransferConfigInlineKeyboardRows(chatGroupId string, callbackDataQueryString string) ([][]tgbotapi.InlineKeyboardButton, error) {
+	transferConfig, err := queryOrCreateTransferConfig(chatGroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	feeMode, b := enums.GetTransferFeeMode(transferConfig.FeeMode)
+	if !b {
+		feeMode = enums.BurnTransferFee
+	}
+
+	houseName := "未设置"
+	house, err := queryTransferHouseUser(transferConfig, enums.HouseTransferFee.Value)
+	if err == nil && house != nil {
+		houseName = giveUserName(house)
+	}
+
+	transferRecordQuery := &model.TransferRecord{
+		ChatGroupId: chatGroupId,
+		Status:      enums.TransferPending.Value,
+	}
+	pendingCount, err := transferRecordQuery.CountByChatGroupIdAndStatus(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("查询待审批转让数量异常")
+		return nil, err
+	}
+
+	return [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("💱转让手续费: %s%%", utils.FormatAmount(transferConfig.FeeRate)), fmt.Sprintf("%s%s", enums.CallbackUpdateTransferRule.Value, callbackDataQueryString)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("💸手续费去向: %s", feeMode.Name), fmt.Sprintf("%s%s", enums.CallbackUpdateTransferFeeMode.Value, callbackDataQueryString)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🏦收费账户: %s", houseName), fmt.Sprintf("%s%s", enums.CallbackUpdateTransferHouseAccount.Value, callbackDataQueryString)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⏳待审批转让(%d)", pendingCount), fmt.Sprintf("%s%s", enums.CallbackTransferApprovalList.Value, callbackDataQueryString)),
+		),
+	}, nil
+}
+
+// updateTransferRuleCallBack 修改转让规则
+func updateTransferRuleCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateTransferRule)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	transferConfig, err := queryOrCreateTransferConfig(callBackData["chatGroupId"])
+	if err != nil {
+		return
+	}
+
+	groupConfigInputCallBack(bot, query, enums.CallbackUpdateTransferRule, enums.WaitTransferRule,
+		fmt.Sprintf("当前规则:\n%s\n\n"+
+			"请输入转让规则: 每日转出次数 每日转出积分 手续费比例(%%) 注册时长(小时) 累计下注流水 审批门槛,以空格分隔,0表示不限制\n"+
+			"例子: 5 10000 2 24 1000 5000\n"+
+			"即每人每日最多转出5次、共10000积分,转出方额外支付2%%手续费,注册满24小时且累计下注1000积分后才可转让,单笔超过5000积分需管理员审批",
+			formatTransferRule(transferConfig)))
+}
+
+// updateTransferRule 设置转让规则
+func updateTransferRule(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCache *common.BotPrivateChatCache) {
+	tgUserId := message.From.ID
+	chatId := message.Chat.ID
+
+	// 校验当前对话人是否为该群管理员
+	err := checkGroupAdmin(botPrivateChatCache.ChatGroupId, tgUserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"tgUserId":    tgUserId,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	transferConfigUpdate, err := parseTransferRule(message.Text)
+	if err != nil {
+		sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("转让规则不合法(%s),例子: 5 10000 2 24 1000 5000", err.Error()))
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	}
+
+	_, err = queryOrCreateTransferConfig(botPrivateChatCache.ChatGroupId)
+	if err != nil {
+		return
+	}
+
+	transferConfigUpdate.ChatGroupId = botPrivateChatCache.ChatGroupId
+	err = transferConfigUpdate.UpdateRuleByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"err":         err,
+		}).Error("设置转让规则异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!转让规则:\n%s", formatTransferRule(transferConfigUpdate)))
+	sendMsg.ReplyToMessageID = message.MessageID
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+	// 删除bot与当前对话人的cache
+	redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
+	redisDB.Del(redisDB.Context(), redisKey)
+}
+
+// parseTransferLimitAmount 解析可为0(不限制)的积分
+func parseTransferLimitAmount(s string) (decimal.Decimal, error) {
+	if s == "0" {
+		return decimal.Zero, nil
+	}
+	return utils.ParseAmount(s)
+}
+
+// parseTransferRule 解析转让规则 每日次数 每日积分 手续费比例 注册时长 累计流水 审批门槛
+func parseTransferRule(text string) (*model.TransferConfig, error) {
+	parts := strings.Fields(text)
+	if len(parts) != 6 {
+		return nil, errors.New("需输入6项")
+	}
+	dailyCountLimit, err := strconv.Atoi(parts[0])
+	if err != nil || dailyCountLimit < 0 {
+		return nil, errors.New("每日转出次数错误")
+	}
+	dailyAmountLimit, err := parseTransferLimitAmount(parts[1])
+	if err != nil {
+		return nil, errors.New("每日转出积分错误")
+	}
+	feeRate, err := parseTransferLimitAmount(parts[2])
+	if err != nil || feeRate.GreaterThan(decimal.NewFromInt(TransferMaxFeeRate)) {
+		return nil, fmt.Errorf("手续费比例需为0-%d", TransferMaxFeeRate)
+	}
+	minAccountAgeHours, err := strconv.Atoi(parts[3])
+	if err != nil || minAccountAgeHours < 0 {
+		return nil, errors.New("注册时长错误")
+	}
+	minTurnover, err := parseTransferLimitAmount(parts[4])
+	if err != nil {
+		return nil, errors.New("累计下注流水错误")
+	}
+	approvalThreshold, err := parseTransferLimitAmount(parts[5])
+	if err != nil {
+		return nil, errors.New("审批门槛错误")
+	}
+	return &model.TransferConfig{
+		DailyCountLimit:    dailyCountLimit,
+		DailyAmountLimit:   dailyAmountLimit,
+		FeeRate:            feeRate,
+		MinAccountAgeHours: minAccountAgeHours,
+		MinTurnover:        minTurnover,
+		ApprovalThreshold:  approvalThreshold,
+	}, nil
+}
+
+// updateTransferFeeModeCallBack 切换手续费去向 归收费账户前需先设置收费账户
+func updateTransferFeeModeCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+	fromUser := query.From
+
+	callBackData, err := queryCallBackData(query, enums.CallbackUpdateTransferFeeMode)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	transferConfig, err := queryOrCreateTransferConfig(chatGroupId)
+	if err != nil {
+		return
+	}
+
+	transferConfigUpdate := &model.TransferConfig{
+		ChatGroupId: chatGroupId,
+		FeeMode:     enums.HouseTransferFee.Value,
+	}
+	if transferConfig.FeeMode == enums.HouseTransferFee.Value {
+		transferConfigUpdate.FeeMode = enums.BurnTransferFee.Value
+	} else if transferConfig.HouseChatGroupUserId == "" {
+		answerCallbackQuery(bot, query, "请先设置收费账户!", true)
+		return
+	}
+
+	err = transferConfigUpdate.UpdateFeeModeByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"feeMode":     transferConfigUpdate.FeeMode,
+			"err":         err,
+		}).Error("更新转让手续费去向异常")
+		return
+	}
+
+	inlineKeyboardMarkup, err := buildChatGroupInlineKeyboardMarkup(query, chatGroup)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"err": err,
+		}).Error("组装群组配置内联键盘异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("点击修改【%s】相关配置:", chatGroup.TgChatGroupTitle))
+	sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// updateTransferHouseAccountCallBack 修改手续费收费账户
+func updateTransferHouseAccountCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	groupConfigInputCallBack(bot, query, enums.CallbackUpdateTransferHouseAccount, enums.WaitTransferHouseAccount,
+		"请输入收费账户的用户名(该用户需已在本群注册),手续费去向为【归收费账户】时手续费将转入该账户\n例子: @UserName")
+}
+
+// updateTransferHouseAccount 设置手续费收费账户
+func updateTransferHouseAccount(bot *tgbotapi.BotAPI, message *tgbotapi.Message, botPrivateChatCache *common.BotPrivateChatCache) {
+	tgUserId := message.From.ID
+	chatId := message.Chat.ID
+
+	// 校验当前对话人是否为该群管理员
+	err := checkGroupAdmin(botPrivateChatCache.ChatGroupId, tgUserId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"tgUserId":    tgUserId,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	chatGroupUserQuery := &model.ChatGroupUser{
+		ChatGroupId: botPrivateChatCache.ChatGroupId,
+		Username:    strings.TrimPrefix(strings.TrimSpace(message.Text), "@"),
+	}
+	house, err := chatGroupUserQuery.QueryByUsernameAndChatGroupId(db)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendMsg := tgbotapi.NewMessage(chatId, "未查询到该用户，未注册或用户名已更改!")
+		sendMsg.ReplyToMessageID = message.MessageID
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatId)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ChatGroupId": chatGroupUserQuery.ChatGroupId,
+			"Username":    chatGroupUserQuery.Username,
+			"err":         err,
+		}).Error("群id+用户名查找群成员异常")
+		return
+	}
+
+	_, err = queryOrCreateTransferConfig(botPrivateChatCache.ChatGroupId)
+	if err != nil {
+		return
+	}
+
+	transferConfigUpdate := &model.TransferConfig{
+		ChatGroupId:          botPrivateChatCache.ChatGroupId,
+		HouseChatGroupUserId: house.Id,
+	}
+	err = transferConfigUpdate.UpdateHouseChatGroupUserIdByChatGroupId(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": botPrivateChatCache.ChatGroupId,
+			"err":         err,
+		}).Error("设置转让手续费收费账户异常")
+		return
+	}
+
+	sendMsg := tgbotapi.NewMessage(chatId, fmt.Sprintf("设置成功!收费账户: %s。", giveUserName(house)))
+	sendMsg.ReplyToMessageID = message.MessageID
+	_, err = sendMessage(bot, &sendMsg)
+	if err != nil {
+		blockedOrKicked(err, chatId)
+		return
+	}
+	// 删除bot与当前对话人的cache
+	redisKey := fmt.Sprintf(RedisBotPrivateChatCacheKey, tgUserId)
+	redisDB.Del(redisDB.Context(), redisKey)
+}
+
+// buildTransferApprovalInlineKeyboardMarkup 待审批转让列表 每笔转让一行通过/拒绝按钮
+func buildTransferApprovalInlineKeyboardMarkup(chatGroup *model.ChatGroup) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	transferRecordQuery := &model.TransferRecord{
+		ChatGroupId: chatGroup.Id,
+		Status:      enums.TransferPending.Value,
+	}
+	transferRecords, err := transferRecordQuery.ListByChatGroupIdAndStatus(db, TransferApprovalPageSize)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("查询待审批转让异常")
+		return "", nil, err
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("【%s】待审批转让:\n", chatGroup.TgChatGroupTitle))
+	if len(transferRecords) == 0 {
+		text.WriteString("暂无待审批的转让")
+	}
+
+	var inlineKeyboardRows [][]tgbotapi.InlineKeyboardButton
+	for i, transferRecord := range transferRecords {
+		senderQuery := &model.ChatGroupUser{Id: transferRecord.SenderChatGroupUserId}
+		sender, err := senderQuery.QueryById(db)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupUserId": transferRecord.SenderChatGroupUserId,
+				"err":             err,
+			}).Error("查询用户信息异常")
+			return "", nil, err
+		}
+		receiverQuery := &model.ChatGroupUser{Id: transferRecord.ReceiverChatGroupUserId}
+		receiver, err := receiverQuery.QueryById(db)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatGroupUserId": transferRecord.ReceiverChatGroupUserId,
+				"err":             err,
+			}).Error("查询用户信息异常")
+			return "", nil, err
+		}
+		text.WriteString(fmt.Sprintf("%d. %s → %s %s积分%s (%s)\n",
+			i+1, giveUserName(sender), giveUserName(receiver),
+			utils.FormatAmount(transferRecord.Amount), formatTransferFee(transferRecord), transferRecord.CreateTime))
+
+		callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+			"chatGroupId":      chatGroup.Id,
+			"transferRecordId": transferRecord.Id,
+		})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"transferRecordId": transferRecord.Id,
+				"err":              err,
+			}).Error("内联键盘回调参数存入redis异常")
+			return "", nil, err
+		}
+		callbackDataQueryString := utils.MapToQueryString(map[string]string{
+			"callbackDataKey": callbackDataKey,
+		})
+		inlineKeyboardRows = append(inlineKeyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅通过 %d", i+1), fmt.Sprintf("%s%s", enums.CallbackTransferApprove.Value, callbackDataQueryString)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("❌拒绝 %d", i+1), fmt.Sprintf("%s%s", enums.CallbackTransferReject.Value, callbackDataQueryString)),
+		))
+	}
+
+	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+		"chatGroupId": chatGroup.Id,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("内联键盘回调参数存入redis异常")
+		return "", nil, err
+	}
+	callbackDataQueryString := utils.MapToQueryString(map[string]string{
+		"callbackDataKey": callbackDataKey,
+	})
+	inlineKeyboardRows = append(inlineKeyboardRows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️返回", fmt.Sprintf("%s%s", enums.CallbackChatGroupConfig.Value, callbackDataQueryString)),
+	))
+
+	inlineKeyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(inlineKeyboardRows...)
+	return text.String(), &inlineKeyboardMarkup, nil
+}
+
+// transferApprovalListCallBack 查看待审批转让
+func transferApprovalListCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+	fromUser := query.From
+
+	callBackData, err := queryCallBackData(query, enums.CallbackTransferApprovalList)
+	if err != nil {
+		logrus.Error("内联键盘回调参数redis查询异常")
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	text, inlineKeyboardMarkup, err := buildTransferApprovalInlineKeyboardMarkup(chatGroup)
+	if err != nil {
+		return
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// reviewTransferCallBack 管理员审批转让 通过时重新校验余额后执行转让,拒绝时仅更新状态
+func reviewTransferCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, callbackPrefix enums.CallbackPrefix) {
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+	fromUser := query.From
+
+	callBackData, err := queryCallBackData(query, callbackPrefix)
+	if err != nil {
+		answerCallbackQuery(bot, query, "操作已过期!", true)
+		return
+	}
+
+	chatGroupId := callBackData["chatGroupId"]
+
+	// 校验当前对话人是否为该群管理员
+	err = checkGroupAdmin(chatGroupId, fromUser.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"fromUserID":  fromUser.ID,
+		}).Error("当前对话人非该群管理员")
+		return
+	}
+
+	chatGroup, err := model.QueryChatGroupById(db, chatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroupId,
+			"err":         err,
+		}).Error("群配置信息查询异常")
+		return
+	}
+
+	transferRecordQuery := &model.TransferRecord{Id: callBackData["transferRecordId"]}
+	transferRecord, err := transferRecordQuery.QueryById(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"transferRecordId": transferRecordQuery.Id,
+			"err":              err,
+		}).Error("查询转让记录异常")
+		return
+	}
+
+	transferRecord.ReviewerTgUserId = fromUser.ID
+	transferRecord.ReviewTime = time.Now().Format("2006-01-02 15:04:05")
+
+	var tipMsg string
+	if transferRecord.Status != enums.TransferPending.Value {
+		tipMsg = "该转让已处理!"
+	} else if callbackPrefix == enums.CallbackTransferReject {
+		tipMsg, err = rejectTransfer(bot, chatGroup, transferRecord)
+	} else {
+		tipMsg, err = approveTransfer(bot, chatGroup, transferRecord)
+	}
+	if err != nil {
+		answerCallbackQuery(bot, query, "审批失败,请稍后再试!", true)
+		return
+	}
+	answerCallbackQuery(bot, query, tipMsg, tipMsg != "")
+
+	text, inlineKeyboardMarkup, err := buildTransferApprovalInlineKeyboardMarkup(chatGroup)
+	if err != nil {
+		return
+	}
+
+	sendMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	sendMsg.ReplyMarkup = inlineKeyboardMarkup
+	_, err = sendMessage(bot, &sendMsg)
+	blockedOrKicked(err, chatID)
+}
+
+// rejectTransfer 拒绝转让 未扣除积分 仅通知转出方
+func rejectTransfer(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, transferRecord *model.TransferRecord) (string, error) {
+	transferRecord.Status = enums.TransferRejected.Value
+	updated, err := transferRecord.UpdateReviewedById(db, enums.TransferPending.Value)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"transferRecordId": transferRecord.Id,
+			"err":              err,
+		}).Error("更新转让记录异常")
+		return "", err
+	} else if !updated {
+		return "该转让已处理!", nil
+	}
+
+	sendNotifyMsg := tgbotapi.NewMessage(transferRecord.SenderTgUserId, fmt.Sprintf("【%s】您发起的%s积分转让未通过管理员审批,积分未扣除。", chatGroup.TgChatGroupTitle, utils.FormatAmount(transferRecord.Amount)))
+	_, err = sendMessage(bot, &sendNotifyMsg)
+	blockedOrKicked(err, transferRecord.SenderTgUserId)
+	return "", nil
+}
+
+// approveTransfer 通过转让 加锁后重新校验转出方余额再执行转让
+func approveTransfer(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, transferRecord *model.TransferRecord) (string, error) {
+	transferConfig, err := queryOrCreateTransferConfig(chatGroup.Id)
+	if err != nil {
+		return "", err
+	}
+	house, err := queryTransferHouseUser(transferConfig, transferRecord.FeeMode)
+	if err != nil {
+		return "", err
+	}
+
+	tgUserIds := []int64{transferRecord.SenderTgUserId, transferRecord.ReceiverTgUserId}
+	if house != nil {
+		tgUserIds = append(tgUserIds, house.TgUserId)
+	}
+	unlock := lockChatGroupUsers(chatGroup.TgChatGroupId, tgUserIds...)
+	defer unlock()
+
+	tx := db.Begin()
+
+	transferRecord.Status = enums.TransferCompleted.Value
+	updated, err := transferRecord.UpdateReviewedById(tx, enums.TransferPending.Value)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"transferRecordId": transferRecord.Id,
+			"err":              err,
+		}).Error("更新转让记录异常")
+		tx.Rollback()
+		return "", err
+	} else if !updated {
+		tx.Rollback()
+		return "该转让已处理!", nil
+	}
+
+	// 重新查询用户信息
+	senderQuery := &model.ChatGroupUser{Id: transferRecord.SenderChatGroupUserId}
+	receiverQuery := &model.ChatGroupUser{Id: transferRecord.ReceiverChatGroupUserId}
+	sender, err := senderQuery.QueryById(tx)
+	var receiver *model.ChatGroupUser
+	if err == nil {
+		receiver, err = receiverQuery.QueryById(tx)
+	}
+	if err == nil && house != nil {
+		house, err = house.QueryById(tx)
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"transferRecordId": transferRecord.Id,
+			"err":              err,
+		}).Error("查询转让用户信息异常")
+		tx.Rollback()
+		return "", err
+	}
+
+	if sender.Balance.LessThan(transferRecord.Amount.Add(transferRecord.Fee)) {
+		tx.Rollback()
+		return fmt.Sprintf("转出方积分余额不足(余额%s),无法通过,可拒绝该转让。", utils.FormatAmount(sender.Balance)), nil
+	}
+
+	err = executeTransfer(tx, transferRecord, sender, receiver, house)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithField("err", err).Error("审批转让事务提交异常")
+		tx.Rollback()
+		return "", err
+	}
+
+	notifyTransferCompleted(bot, chatGroup, transferRecord, sender, receiver)
+	return "", nil
+}
+
+// notifyTransferApproval 转让超过审批门槛时私聊通知群管理员审批
+func notifyTransferApproval(bot *tgbotapi.BotAPI, chatGroup *model.ChatGroup, transferRecord *model.TransferRecord, sender *model.ChatGroupUser, receiver *model.ChatGroupUser) {
+	chatGroupAdmins, err := model.ListChatGroupAdminByChatGroupId(db, chatGroup.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("查询群管理员异常")
+		return
+	}
+
+	callbackDataKey, err := ButtonCallBackDataAddRedis(map[string]string{
+		"chatGroupId":      chatGroup.Id,
+		"transferRecordId": transferRecord.Id,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"transferRecordId": transferRecord.Id,
+			"err":              err,
+		}).Error("内联键盘回调参数存入redis异常")
+		return
+	}
+	callbackDataQueryString := utils.MapToQueryString(map[string]string{
+		"callbackDataKey": callbackDataKey,
+	})
+
+	for _, chatGroupAdmin := range chatGroupAdmins {
+		sendMsg := tgbotapi.NewMessage(chatGroupAdmin.AdminTgUserId, fmt.Sprintf("【%s】用户%s申请向%s转让%s积分%s,超过审批门槛,请审批。\n也可在群配置【⏳待审批转让】中处理。",
+			chatGroup.TgChatGroupTitle, giveUserName(sender), giveUserName(receiver),
+			utils.FormatAmount(transferRecord.Amount), formatTransferFee(transferRecord)))
+		sendMsg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅通过", fmt.Sprintf("%s%s", enums.CallbackTransferApprove.Value, callbackDataQueryString)),
+				tgbotapi.NewInlineKeyboardButtonData("❌拒绝", fmt.Sprintf("%s%s", enums.CallbackTransferReject.Value, callbackDataQueryString)),
+			),
+		)
+		_, err = sendMessage(bot, &sendMsg)
+		blockedOrKicked(err, chatGroupAdmin.AdminTgUserId)
+	}
+}
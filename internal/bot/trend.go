@@ -0,0 +1,299 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+	"telegram-dice-bot/internal/enums"
+	"telegram-dice-bot/internal/model"
+	"time"
+)
+
+const (
+	// TrendDefaultIssueCount 走势图默认期数
+	TrendDefaultIssueCount = 60
+	// TrendMaxIssueCount 走势图最大期数
+	TrendMaxIssueCount = 120
+	// roadMapRows 路单行数
+	roadMapRows = 6
+	// roadMapCellSize 路单格子边长(像素)
+	roadMapCellSize = 24
+	// roadMapMaxBigRoadCols 大路最多展示的列数 超出时只展示最近的列
+	roadMapMaxBigRoadCols = 30
+	roadMapPadding        = 12
+)
+
+var (
+	roadMapBackgroundColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	roadMapGridColor       = color.RGBA{R: 220, G: 220, B: 220, A: 255}
+	roadMapBigColor        = color.RGBA{R: 220, G: 50, B: 47, A: 255}
+	roadMapSmallColor      = color.RGBA{R: 38, G: 110, B: 210, A: 255}
+	roadMapTripletColor    = color.RGBA{R: 40, G: 160, B: 70, A: 255}
+	roadMapSingleDotColor  = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+// roadMapBigRoadCell 大路中一格 同一列为连续相同的大小结果 TripletCount 为落在该格之后开出的豹子数
+type roadMapBigRoadCell struct {
+	Col          int
+	Row          int
+	BigSmall     string
+	TripletCount int
+}
+
+func handleTrendCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	tgChatGroupId := message.Chat.ID
+	messageId := message.MessageID
+
+	issueCount := TrendDefaultIssueCount
+	if arg := strings.TrimSpace(message.CommandArguments()); arg != "" {
+		count, err := strconv.Atoi(arg)
+		if err != nil || count <= 0 || count > TrendMaxIssueCount {
+			replyAutoDeleteMessage(bot, tgChatGroupId, messageId, fmt.Sprintf("期数需为1-%d的整数,例子: /trend %d", TrendMaxIssueCount, TrendDefaultIssueCount))
+			return
+		}
+		issueCount = count
+	}
+
+	sendTrendImage(bot, tgChatGroupId, messageId, issueCount)
+}
+
+// lotteryTrendCallBack 开奖结果消息上的走势图按钮
+func lotteryTrendCallBack(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	answerCallbackQuery(bot, query, "", false)
+	sendTrendImage(bot, query.Message.Chat.ID, 0, TrendDefaultIssueCount)
+}
+
+// sendTrendImage 生成最近 issueCount 期的珠盘路/大路走势图并发送到群 1分钟后自动删除
+func sendTrendImage(bot *tgbotapi.BotAPI, tgChatGroupId int64, replyToMessageId int, issueCount int) {
+	chatGroup, err := model.QueryChatGroupByTgChatId(db, tgChatGroupId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tgChatGroupId": tgChatGroupId,
+			"err":           err,
+		}).Error("群配置查询异常")
+		return
+	}
+
+	quickThereLotteryRecordQuery := &model.QuickThereLotteryRecord{ChatGroupId: chatGroup.Id}
+	quickThereLotteryRecords, err := quickThereLotteryRecordQuery.ListRecentByChatGroupId(db, issueCount)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("快三开奖记录查询异常")
+		return
+	}
+	if len(quickThereLotteryRecords) == 0 {
+		replyAutoDeleteMessage(bot, tgChatGroupId, replyToMessageId, "暂无开奖记录")
+		return
+	}
+
+	// 查询结果按期号倒序 走势图按开奖先后排列
+	for i, j := 0, len(quickThereLotteryRecords)-1; i < j; i, j = i+1, j-1 {
+		quickThereLotteryRecords[i], quickThereLotteryRecords[j] = quickThereLotteryRecords[j], quickThereLotteryRecords[i]
+	}
+
+	imageBytes, err := renderRoadMap(quickThereLotteryRecords)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"chatGroupId": chatGroup.Id,
+			"err":         err,
+		}).Error("走势图生成异常")
+		return
+	}
+
+	photoConfig := tgbotapi.NewPhoto(tgChatGroupId, tgbotapi.FileBytes{Name: "trend.png", Bytes: imageBytes})
+	photoConfig.ReplyToMessageID = replyToMessageId
+	photoConfig.Caption = fmt.Sprintf("近%d期走势图(%s期~%s期)\n"+
+		"珠盘路: 🔴大 🔵小 🟢豹子,圆心白点为单\n"+
+		"大路: 同列为连续相同的大小,🟢斜线为豹子",
+		len(quickThereLotteryRecords),
+		quickThereLotteryRecords[0].IssueNumber,
+		quickThereLotteryRecords[len(quickThereLotteryRecords)-1].IssueNumber)
+	sentMsg, err := sendMessage(bot, &photoConfig)
+	if err != nil {
+		blockedOrKicked(err, tgChatGroupId)
+		return
+	}
+	go func(messageID int) {
+		time.Sleep(1 * time.Minute)
+		deleteMsg := tgbotapi.NewDeleteMessage(tgChatGroupId, messageID)
+		_, err := bot.Request(deleteMsg)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"err": err,
+			}).Error("删除消息异常")
+		}
+	}(sentMsg.MessageID)
+}
+
+// buildBigRoad 按大路规则排列开奖结果
+// 与上一格大小相同则向下延伸,到底或下方已占用时向右拐(长龙);大小变化时另起一列;豹子不占格,记在上一格上
+func buildBigRoad(records []*model.QuickThereLotteryRecord) []*roadMapBigRoadCell {
+	var cells []*roadMapBigRoadCell
+	occupied := make(map[[2]int]bool)
+	pendingTriplet := 0
+	streakStartCol := -1
+	// 当前长龙是否已经向右拐
+	turned := false
+
+	for _, record := range records {
+		if record.Triplet == 1 {
+			if len(cells) == 0 {
+				pendingTriplet++
+			} else {
+				cells[len(cells)-1].TripletCount++
+			}
+			continue
+		}
+
+		var cell *roadMapBigRoadCell
+		if len(cells) == 0 || cells[len(cells)-1].BigSmall != record.BigSmall {
+			streakStartCol++
+			// 新列首格被上一条长龙占用时顺延到右侧空列
+			for occupied[[2]int{streakStartCol, 0}] {
+				streakStartCol++
+			}
+			turned = false
+			cell = &roadMapBigRoadCell{Col: streakStartCol, Row: 0, BigSmall: record.BigSmall}
+		} else {
+			last := cells[len(cells)-1]
+			if !turned && last.Row+1 < roadMapRows && !occupied[[2]int{last.Col, last.Row + 1}] {
+				cell = &roadMapBigRoadCell{Col: last.Col, Row: last.Row + 1, BigSmall: record.BigSmall}
+			} else {
+				turned = true
+				cell = &roadMapBigRoadCell{Col: last.Col + 1, Row: last.Row, BigSmall: record.BigSmall}
+			}
+		}
+		cell.TripletCount = pendingTriplet
+		pendingTriplet = 0
+		occupied[[2]int{cell.Col, cell.Row}] = true
+		cells = append(cells, cell)
+	}
+	return cells
+}
+
+// renderRoadMap 绘制珠盘路(上)与大路(下)PNG
+func renderRoadMap(records []*model.QuickThereLotteryRecord) ([]byte, error) {
+	beadCols := (len(records) + roadMapRows - 1) / roadMapRows
+
+	bigRoadCells := buildBigRoad(records)
+	bigRoadCols := 0
+	for _, cell := range bigRoadCells {
+		if cell.Col+1 > bigRoadCols {
+			bigRoadCols = cell.Col + 1
+		}
+	}
+	bigRoadColOffset := 0
+	if bigRoadCols > roadMapMaxBigRoadCols {
+		bigRoadColOffset = bigRoadCols - roadMapMaxBigRoadCols
+		bigRoadCols = roadMapMaxBigRoadCols
+	}
+
+	cols := beadCols
+	if bigRoadCols > cols {
+		cols = bigRoadCols
+	}
+	sectionHeight := roadMapRows * roadMapCellSize
+	width := cols*roadMapCellSize + roadMapPadding*2
+	height := sectionHeight*2 + roadMapPadding*3
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: roadMapBackgroundColor}, image.Point{}, draw.Src)
+
+	beadOrigin := image.Point{X: roadMapPadding, Y: roadMapPadding}
+	bigRoadOrigin := image.Point{X: roadMapPadding, Y: roadMapPadding*2 + sectionHeight}
+	drawRoadMapGrid(img, beadOrigin, cols)
+	drawRoadMapGrid(img, bigRoadOrigin, cols)
+
+	// 珠盘路 按列从上到下依次排列
+	radius := roadMapCellSize/2 - 3
+	for i, record := range records {
+		center := roadMapCellCenter(beadOrigin, i/roadMapRows, i%roadMapRows)
+		fillColor := roadMapBigSmallColor(record.BigSmall)
+		if record.Triplet == 1 {
+			fillColor = roadMapTripletColor
+		}
+		drawRoadMapCircle(img, center, radius, 0, fillColor)
+		if record.SingleDouble == enums.Single.Value {
+			drawRoadMapCircle(img, center, 3, 0, roadMapSingleDotColor)
+		}
+	}
+
+	// 大路 空心圆圈 豹子在圈上画斜线
+	for _, cell := range bigRoadCells {
+		if cell.Col < bigRoadColOffset {
+			continue
+		}
+		center := roadMapCellCenter(bigRoadOrigin, cell.Col-bigRoadColOffset, cell.Row)
+		drawRoadMapCircle(img, center, radius, radius-3, roadMapBigSmallColor(cell.BigSmall))
+		if cell.TripletCount > 0 {
+			drawRoadMapSlash(img, center, radius, roadMapTripletColor)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func roadMapBigSmallColor(bigSmall string) color.RGBA {
+	if bigSmall == enums.Big.Value {
+		return roadMapBigColor
+	}
+	return roadMapSmallColor
+}
+
+func roadMapCellCenter(origin image.Point, col, row int) image.Point {
+	return image.Point{
+		X: origin.X + col*roadMapCellSize + roadMapCellSize/2,
+		Y: origin.Y + row*roadMapCellSize + roadMapCellSize/2,
+	}
+}
+
+func drawRoadMapGrid(img *image.RGBA, origin image.Point, cols int) {
+	width := cols * roadMapCellSize
+	height := roadMapRows * roadMapCellSize
+	for col := 0; col <= cols; col++ {
+		x := origin.X + col*roadMapCellSize
+		for y := origin.Y; y <= origin.Y+height; y++ {
+			img.Set(x, y, roadMapGridColor)
+		}
+	}
+	for row := 0; row <= roadMapRows; row++ {
+		y := origin.Y + row*roadMapCellSize
+		for x := origin.X; x <= origin.X+width; x++ {
+			img.Set(x, y, roadMapGridColor)
+		}
+	}
+}
+
+// drawRoadMapCircle 绘制圆 innerRadius 为 0 时为实心圆,否则为圆环
+func drawRoadMapCircle(img *image.RGBA, center image.Point, radius, innerRadius int, c color.RGBA) {
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			d := dx*dx + dy*dy
+			if d <= radius*radius && d >= innerRadius*innerRadius {
+				img.Set(center.X+dx, center.Y+dy, c)
+			}
+		}
+	}
+}
+
+// drawRoadMapSlash 从右上到左下画一条斜线 线宽3像素
+func drawRoadMapSlash(img *image.RGBA, center image.Point, radius int, c color.RGBA) {
+	for i := -radius; i <= radius; i++ {
+		for w := -1; w <= 1; w++ {
+			img.Set(center.X+i+w, center.Y-i, c)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package common
+
+// LiarsDiceState 吹牛骰子牌桌状态 以json形式持久化在牌桌记录中
+type LiarsDiceState struct {
+	Players     []*LiarsDicePlayer `json:"players"`
+	TurnIndex   int                `json:"turn_index"`   // 当前行动玩家下标
+	BidQuantity int                `json:"bid_quantity"` // 当前叫数(个数)
+	BidFace     int                `json:"bid_face"`     // 当前叫数(点数)
+	BidderIndex int                `json:"bidder_index"` // 当前叫数玩家下标
+	Round       int                `json:"round"`        // 当前回合
+	Version     int                `json:"version"`      // 状态版本 每次行动后递增 用于校验过期按钮及超时任务
+	LastResult  string             `json:"last_result"`  // 上回合结果
+}
+
+type LiarsDicePlayer struct {
+	ChatGroupUserId string `json:"chat_group_user_id"`
+	TgUserId        int64  `json:"tg_user_id"`
+	Username        string `json:"username"`
+	FirstName       string `json:"first_name"`
+	Dice            []int  `json:"dice"`       // 当前手中骰子 长度即剩余骰子数
+	Eliminated      bool   `json:"eliminated"` // 是否出局
+}
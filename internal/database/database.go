@@ -18,6 +18,8 @@ func InitDB(dsn string) (*gorm.DB, error) {
 	var err error
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Error),
+		// 唯一索引冲突转换为 gorm.ErrDuplicatedKey 便于识别并发重复创建
+		TranslateError: true,
 	})
 	if err != nil {
 		logrus.WithField("err", err).Fatal("连接数据库失败")
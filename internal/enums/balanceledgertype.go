@@ -0,0 +1,45 @@
+package enums
+
+// BalanceLedgerType 代表枚举的自定义类型
+type BalanceLedgerType struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var BalanceLedgerTypeMap = make(map[string]BalanceLedgerType)
+
+// 构造函数
+func newBalanceLedgerType(value string, name string) BalanceLedgerType {
+	enum := BalanceLedgerType{Value: value, Name: name}
+	BalanceLedgerTypeMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	RegisterLedger        = newBalanceLedgerType("REGISTER", "注册奖励")
+	SignInLedger          = newBalanceLedgerType("SIGN_IN", "签到奖励")
+	BetLedger             = newBalanceLedgerType("BET", "下注")
+	BetSettleLedger       = newBalanceLedgerType("BET_SETTLE", "中奖派彩")
+	TransferOutLedger     = newBalanceLedgerType("TRANSFER_OUT", "转出")
+	TransferInLedger      = newBalanceLedgerType("TRANSFER_IN", "转入")
+	AdminAdjustLedger     = newBalanceLedgerType("ADMIN_ADJUST", "管理员调整")
+	LiarsDiceAnteLedger   = newBalanceLedgerType("LIARS_DICE_ANTE", "吹牛骰子入场")
+	LiarsDiceRefundLedger = newBalanceLedgerType("LIARS_DICE_REFUND", "吹牛骰子退还")
+	LiarsDiceWinLedger    = newBalanceLedgerType("LIARS_DICE_WIN", "吹牛骰子奖池")
+	ReliefLedger          = newBalanceLedgerType("RELIEF", "救济金")
+	RebateLedger          = newBalanceLedgerType("REBATE", "反水")
+	ReferralLedger        = newBalanceLedgerType("REFERRAL", "邀请奖励")
+	HongbaoSendLedger     = newBalanceLedgerType("HONGBAO_SEND", "发红包")
+	HongbaoClaimLedger    = newBalanceLedgerType("HONGBAO_CLAIM", "抢红包")
+	HongbaoRefundLedger   = newBalanceLedgerType("HONGBAO_REFUND", "红包过期退还")
+	TransferFeeLedger     = newBalanceLedgerType("TRANSFER_FEE", "转让手续费")
+)
+
+// GetBalanceLedgerType 通过 value 获取枚举项
+func GetBalanceLedgerType(value string) (BalanceLedgerType, bool) {
+	enum, ok := BalanceLedgerTypeMap[value]
+	return enum, ok
+
+}
@@ -23,7 +23,16 @@ var (
 	WaitUpdateUserBalance     = newBotPrivateChatStatus("WAIT_UPDATE_USER_BALANCE", "修改用户积分")
 	WaitQuickThereSimpleOdds  = newBotPrivateChatStatus("WAIT_QUICK_THERE_SIMPLE_ODDS", "快三简易倍率")
 	WaitQuickThereTripletOdds = newBotPrivateChatStatus("WAIT_QUICK_THERE_TRIPLET_ODDS", "快三豹子倍率")
+	WaitQuickThereSmallMax    = newBotPrivateChatStatus("WAIT_QUICK_THERE_SMALL_MAX", "快三大小分界")
 	WaitTransferBalance       = newBotPrivateChatStatus("WAIT_TRANSFER_BALANCE", "转让用户积分")
+	WaitRegisterReward        = newBotPrivateChatStatus("WAIT_REGISTER_REWARD", "注册奖励")
+	WaitSignInRewardLadder    = newBotPrivateChatStatus("WAIT_SIGN_IN_REWARD_LADDER", "签到奖励阶梯")
+	WaitRollRewardTable       = newBotPrivateChatStatus("WAIT_ROLL_REWARD_TABLE", "掷骰签到奖励表")
+	WaitReliefRule            = newBotPrivateChatStatus("WAIT_RELIEF_RULE", "救济金规则")
+	WaitRebateRule            = newBotPrivateChatStatus("WAIT_REBATE_RULE", "反水规则")
+	WaitReferralRule          = newBotPrivateChatStatus("WAIT_REFERRAL_RULE", "邀请奖励规则")
+	WaitTransferRule          = newBotPrivateChatStatus("WAIT_TRANSFER_RULE", "转让规则")
+	WaitTransferHouseAccount  = newBotPrivateChatStatus("WAIT_TRANSFER_HOUSE_ACCOUNT", "转让手续费收费账户")
 )
 
 // GetBotPrivateChatStatus 通过 value 获取枚举项
@@ -29,15 +29,57 @@ var (
 	CallbackUpdateGameplayType          = newCallbackPrefix("update_gameplay_type?", "更新游戏类型")
 	CallbackUpdateQuickThereSimpleOdds  = newCallbackPrefix("update_q_t_simple_odds?", "更新快三简易倍率")
 	CallbackUpdateQuickThereTripletOdds = newCallbackPrefix("update_q_t_triplet_odds?", "更新快三豹子倍率")
+	CallbackQuickThereDiceCount         = newCallbackPrefix("q_t_dice_count?", "快三骰子数量")
+	CallbackUpdateQuickThereDiceCount   = newCallbackPrefix("update_q_t_dice_count?", "更新快三骰子数量")
+	CallbackUpdateQuickThereSmallMax    = newCallbackPrefix("update_q_t_small_max?", "更新快三大小分界")
 	CallbackUpdateGameplayStatus        = newCallbackPrefix("update_gameplay_status?", "更新游戏类型状态")
 	CallbackUpdateGameDrawCycle         = newCallbackPrefix("update_game_draw_cycle?", "更新游戏开奖周期")
+	CallbackUpdateRegisterReward        = newCallbackPrefix("update_register_reward?", "更新注册奖励")
+	CallbackUpdateSignInRewardLadder    = newCallbackPrefix("update_sign_in_reward_ladder?", "更新签到奖励阶梯")
+	CallbackUpdateSignInMode            = newCallbackPrefix("update_sign_in_mode?", "更新签到模式")
+	CallbackUpdateRollRewardTable       = newCallbackPrefix("update_roll_reward_table?", "更新掷骰签到奖励表")
+	CallbackUpdateReliefStatus          = newCallbackPrefix("update_relief_status?", "更新救济金状态")
+	CallbackUpdateReliefRule            = newCallbackPrefix("update_relief_rule?", "更新救济金规则")
+	CallbackUpdateRebateStatus          = newCallbackPrefix("update_rebate_status?", "更新反水状态")
+	CallbackUpdateRebateMode            = newCallbackPrefix("update_rebate_mode?", "更新反水计算方式")
+	CallbackUpdateRebateRule            = newCallbackPrefix("update_rebate_rule?", "更新反水规则")
+	CallbackUpdateReferralStatus        = newCallbackPrefix("update_referral_status?", "更新邀请奖励状态")
+	CallbackUpdateReferralRule          = newCallbackPrefix("update_referral_rule?", "更新邀请奖励规则")
+	CallbackUpdateTransferRule          = newCallbackPrefix("update_transfer_rule?", "更新转让规则")
+	CallbackUpdateTransferFeeMode       = newCallbackPrefix("update_transfer_fee_mode?", "更新转让手续费去向")
+	CallbackUpdateTransferHouseAccount  = newCallbackPrefix("update_transfer_house_account?", "更新转让手续费收费账户")
+	CallbackTransferApprovalList        = newCallbackPrefix("transfer_approval_list?", "待审批转让")
+	CallbackTransferApprove             = newCallbackPrefix("transfer_approve?", "通过转让审批")
+	CallbackTransferReject              = newCallbackPrefix("transfer_reject?", "拒绝转让审批")
 	CallbackQueryChatGroupUser          = newCallbackPrefix("query_chat_group_user?", "查询群用户信息")
 	CallbackUpdateChatGroupUserBalance  = newCallbackPrefix("update_chat_group_user_balance?", "更新用户积分")
+	CallbackChatGroupUserLedger         = newCallbackPrefix("chat_group_user_ledger?", "群用户积分流水")
 	CallbackLotteryHistory              = newCallbackPrefix("lottery_history", "开奖历史")
 	CallbackChatGroupInfo               = newCallbackPrefix("chat_group_info?", "群详情信息")
 	CallbackTransferBalance             = newCallbackPrefix("transfer_balance?", "转让积分(用户)")
 	CallbackExitGroup                   = newCallbackPrefix("exit_group?", "退出群聊")
 	CallbackAdminExitGroup              = newCallbackPrefix("admin_exit_group?", "退出群聊")
+	CallbackDrawSourceType              = newCallbackPrefix("draw_source_type?", "开奖来源")
+	CallbackUpdateDrawSourceType        = newCallbackPrefix("update_draw_source_type?", "更新开奖来源")
+	CallbackLiarsDiceJoin               = newCallbackPrefix("liars_dice_join?", "吹牛骰子-加入")
+	CallbackLiarsDiceStart              = newCallbackPrefix("liars_dice_start?", "吹牛骰子-开始")
+	CallbackLiarsDiceCancel             = newCallbackPrefix("liars_dice_cancel?", "吹牛骰子-取消")
+	CallbackLiarsDiceBid                = newCallbackPrefix("liars_dice_bid?", "吹牛骰子-叫数")
+	CallbackLiarsDiceCall               = newCallbackPrefix("liars_dice_call?", "吹牛骰子-开")
+	CallbackLiarsDiceMyDice             = newCallbackPrefix("liars_dice_my_dice?", "吹牛骰子-我的骰子")
+	CallbackHongbaoGrab                 = newCallbackPrefix("hongbao_grab?", "抢红包")
+	CallbackGiveConfirm                 = newCallbackPrefix("give_confirm?", "确认转让积分(群内)")
+	CallbackGiveCancel                  = newCallbackPrefix("give_cancel?", "取消转让积分(群内)")
+	CallbackRankTab                     = newCallbackPrefix("rank_tab?", "排行榜切换")
+	CallbackProfileScope                = newCallbackPrefix("profile_scope?", "个人统计范围切换")
+	CallbackMyHistoryPage               = newCallbackPrefix("my_history_page?", "下注记录翻页")
+	CallbackLotteryHistoryPage          = newCallbackPrefix("lottery_history_page?", "开奖历史翻页")
+	CallbackLotteryTrend                = newCallbackPrefix("lottery_trend", "走势图")
+	CallbackUpdateBetReplyStatus        = newCallbackPrefix("update_bet_reply_status?", "更新下注回复状态")
+	CallbackUpdateSettleSummaryStatus   = newCallbackPrefix("update_settle_summary_status?", "更新结算汇总状态")
+	CallbackBetChipType                 = newCallbackPrefix("bet_chip_type?", "快捷下注-选择竞猜类型")
+	CallbackBetChipAmount               = newCallbackPrefix("bet_chip_amount?", "快捷下注-下注积分")
+	CallbackBetChipRepeat               = newCallbackPrefix("bet_chip_repeat?", "快捷下注-重复上次下注")
 )
 
 // GetCallbackPrefix 通过 value 获取枚举项
@@ -0,0 +1,32 @@
+package enums
+
+// DrawSourceType 代表枚举的自定义类型
+type DrawSourceType struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var DrawSourceTypeMap = make(map[string]DrawSourceType)
+
+// 构造函数
+func newDrawSourceType(value string, name string) DrawSourceType {
+	enum := DrawSourceType{Value: value, Name: name}
+	DrawSourceTypeMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	TelegramDiceDrawSource = newDrawSourceType("TELEGRAM_DICE", "Telegram骰子")
+	LocalRandomDrawSource  = newDrawSourceType("LOCAL_RANDOM", "本地随机数")
+	HttpFeedDrawSource     = newDrawSourceType("HTTP_FEED", "外部数据源")
+	ProvablyFairDrawSource = newDrawSourceType("PROVABLY_FAIR", "可验证公平")
+)
+
+// GetDrawSourceType 通过 value 获取枚举项
+func GetDrawSourceType(value string) (DrawSourceType, bool) {
+	enum, ok := DrawSourceTypeMap[value]
+	return enum, ok
+
+}
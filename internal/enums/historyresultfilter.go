@@ -0,0 +1,32 @@
+package enums
+
+// HistoryResultFilter 代表枚举的自定义类型
+type HistoryResultFilter struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var HistoryResultFilterMap = make(map[string]HistoryResultFilter)
+
+// 构造函数
+func newHistoryResultFilter(value string, name string) HistoryResultFilter {
+	enum := HistoryResultFilter{Value: value, Name: name}
+	HistoryResultFilterMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	HistoryResultAll       = newHistoryResultFilter("ALL", "全部")
+	HistoryResultWin       = newHistoryResultFilter("WIN", "赢")
+	HistoryResultLoss      = newHistoryResultFilter("LOSS", "输")
+	HistoryResultUnsettled = newHistoryResultFilter("UNSETTLED", "未开奖")
+)
+
+// GetHistoryResultFilter 通过 value 获取枚举项
+func GetHistoryResultFilter(value string) (HistoryResultFilter, bool) {
+	enum, ok := HistoryResultFilterMap[value]
+	return enum, ok
+
+}
@@ -0,0 +1,30 @@
+package enums
+
+// HongbaoSplitMode 代表枚举的自定义类型
+type HongbaoSplitMode struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var HongbaoSplitModeMap = make(map[string]HongbaoSplitMode)
+
+// 构造函数
+func newHongbaoSplitMode(value string, name string) HongbaoSplitMode {
+	enum := HongbaoSplitMode{Value: value, Name: name}
+	HongbaoSplitModeMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	HongbaoRandomSplit = newHongbaoSplitMode("RANDOM", "拼手气红包")
+	HongbaoEqualSplit  = newHongbaoSplitMode("EQUAL", "普通红包")
+)
+
+// GetHongbaoSplitMode 通过 value 获取枚举项
+func GetHongbaoSplitMode(value string) (HongbaoSplitMode, bool) {
+	enum, ok := HongbaoSplitModeMap[value]
+	return enum, ok
+
+}
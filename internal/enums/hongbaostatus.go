@@ -0,0 +1,31 @@
+package enums
+
+// HongbaoStatus 代表枚举的自定义类型
+type HongbaoStatus struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var HongbaoStatusMap = make(map[string]HongbaoStatus)
+
+// 构造函数
+func newHongbaoStatus(value string, name string) HongbaoStatus {
+	enum := HongbaoStatus{Value: value, Name: name}
+	HongbaoStatusMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	HongbaoActive   = newHongbaoStatus("ACTIVE", "进行中")
+	HongbaoFinished = newHongbaoStatus("FINISHED", "已抢完")
+	HongbaoExpired  = newHongbaoStatus("EXPIRED", "已过期")
+)
+
+// GetHongbaoStatus 通过 value 获取枚举项
+func GetHongbaoStatus(value string) (HongbaoStatus, bool) {
+	enum, ok := HongbaoStatusMap[value]
+	return enum, ok
+
+}
@@ -0,0 +1,32 @@
+package enums
+
+// LiarsDiceTableStatus 代表枚举的自定义类型
+type LiarsDiceTableStatus struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var LiarsDiceTableStatusMap = make(map[string]LiarsDiceTableStatus)
+
+// 构造函数
+func newLiarsDiceTableStatus(value string, name string) LiarsDiceTableStatus {
+	enum := LiarsDiceTableStatus{Value: value, Name: name}
+	LiarsDiceTableStatusMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	LiarsDiceWaiting   = newLiarsDiceTableStatus("WAITING", "等待加入")
+	LiarsDicePlaying   = newLiarsDiceTableStatus("PLAYING", "进行中")
+	LiarsDiceFinished  = newLiarsDiceTableStatus("FINISHED", "已结束")
+	LiarsDiceCancelled = newLiarsDiceTableStatus("CANCELLED", "已取消")
+)
+
+// GetLiarsDiceTableStatus 通过 value 获取枚举项
+func GetLiarsDiceTableStatus(value string) (LiarsDiceTableStatus, bool) {
+	enum, ok := LiarsDiceTableStatusMap[value]
+	return enum, ok
+
+}
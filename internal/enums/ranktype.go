@@ -0,0 +1,32 @@
+package enums
+
+// RankType 代表枚举的自定义类型
+type RankType struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var RankTypeMap = make(map[string]RankType)
+
+// 构造函数
+func newRankType(value string, name string) RankType {
+	enum := RankType{Value: value, Name: name}
+	RankTypeMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	BalanceRank      = newRankType("BALANCE", "💰富豪榜")
+	TodayProfitRank  = newRankType("TODAY_PROFIT", "📈今日盈利榜")
+	WeekTurnoverRank = newRankType("WEEK_TURNOVER", "🔥本周流水榜")
+	BiggestWinRank   = newRankType("BIGGEST_WIN", "🏅单笔最大赢")
+)
+
+// GetRankType 通过 value 获取枚举项
+func GetRankType(value string) (RankType, bool) {
+	enum, ok := RankTypeMap[value]
+	return enum, ok
+
+}
@@ -0,0 +1,30 @@
+package enums
+
+// RebateMode 代表枚举的自定义类型
+type RebateMode struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var RebateModeMap = make(map[string]RebateMode)
+
+// 构造函数
+func newRebateMode(value string, name string) RebateMode {
+	enum := RebateMode{Value: value, Name: name}
+	RebateModeMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	TurnoverRebate = newRebateMode("TURNOVER", "按流水")
+	NetLossRebate  = newRebateMode("NET_LOSS", "按净输")
+)
+
+// GetRebateMode 通过 value 获取枚举项
+func GetRebateMode(value string) (RebateMode, bool) {
+	enum, ok := RebateModeMap[value]
+	return enum, ok
+
+}
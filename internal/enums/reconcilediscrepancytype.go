@@ -0,0 +1,34 @@
+package enums
+
+// ReconcileDiscrepancyType 代表枚举的自定义类型
+type ReconcileDiscrepancyType struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var ReconcileDiscrepancyTypeMap = make(map[string]ReconcileDiscrepancyType)
+
+// 构造函数
+func newReconcileDiscrepancyType(value string, name string) ReconcileDiscrepancyType {
+	enum := ReconcileDiscrepancyType{Value: value, Name: name}
+	ReconcileDiscrepancyTypeMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	SourceBalanceMismatch = newReconcileDiscrepancyType("SOURCE_BALANCE_MISMATCH", "余额与业务记录不符")
+	InitialBalanceMissing = newReconcileDiscrepancyType("INITIAL_BALANCE_MISSING", "无期初余额无法核对")
+	BalanceMismatch       = newReconcileDiscrepancyType("BALANCE_MISMATCH", "余额与流水不符")
+	LedgerChainBroken     = newReconcileDiscrepancyType("LEDGER_CHAIN_BROKEN", "流水前后余额不连续")
+	BetLedgerMismatch     = newReconcileDiscrepancyType("BET_LEDGER_MISMATCH", "下注与流水不符")
+	SettleLedgerMismatch  = newReconcileDiscrepancyType("SETTLE_LEDGER_MISMATCH", "派彩与流水不符")
+)
+
+// GetReconcileDiscrepancyType 通过 value 获取枚举项
+func GetReconcileDiscrepancyType(value string) (ReconcileDiscrepancyType, bool) {
+	enum, ok := ReconcileDiscrepancyTypeMap[value]
+	return enum, ok
+
+}
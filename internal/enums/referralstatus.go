@@ -0,0 +1,30 @@
+package enums
+
+// ReferralStatus 代表枚举的自定义类型
+type ReferralStatus struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var ReferralStatusMap = make(map[string]ReferralStatus)
+
+// 构造函数
+func newReferralStatus(value string, name string) ReferralStatus {
+	enum := ReferralStatus{Value: value, Name: name}
+	ReferralStatusMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	ReferralPending  = newReferralStatus("PENDING", "待达标")
+	ReferralRewarded = newReferralStatus("REWARDED", "已奖励")
+)
+
+// GetReferralStatus 通过 value 获取枚举项
+func GetReferralStatus(value string) (ReferralStatus, bool) {
+	enum, ok := ReferralStatusMap[value]
+	return enum, ok
+
+}
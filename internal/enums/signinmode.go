@@ -0,0 +1,31 @@
+package enums
+
+// SignInMode 代表枚举的自定义类型
+type SignInMode struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var SignInModeMap = make(map[string]SignInMode)
+
+// 构造函数
+func newSignInMode(value string, name string) SignInMode {
+	enum := SignInMode{Value: value, Name: name}
+	SignInModeMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	FixedSignIn = newSignInMode("FIXED", "固定奖励")
+	DiceSignIn  = newSignInMode("DICE", "🎲骰子")
+	SlotSignIn  = newSignInMode("SLOT", "🎰老虎机")
+)
+
+// GetSignInMode 通过 value 获取枚举项
+func GetSignInMode(value string) (SignInMode, bool) {
+	enum, ok := SignInModeMap[value]
+	return enum, ok
+
+}
@@ -0,0 +1,30 @@
+package enums
+
+// TransferFeeMode 代表枚举的自定义类型
+type TransferFeeMode struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var TransferFeeModeMap = make(map[string]TransferFeeMode)
+
+// 构造函数
+func newTransferFeeMode(value string, name string) TransferFeeMode {
+	enum := TransferFeeMode{Value: value, Name: name}
+	TransferFeeModeMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	BurnTransferFee  = newTransferFeeMode("BURN", "销毁")
+	HouseTransferFee = newTransferFeeMode("HOUSE", "归收费账户")
+)
+
+// GetTransferFeeMode 通过 value 获取枚举项
+func GetTransferFeeMode(value string) (TransferFeeMode, bool) {
+	enum, ok := TransferFeeModeMap[value]
+	return enum, ok
+
+}
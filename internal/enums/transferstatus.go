@@ -0,0 +1,31 @@
+package enums
+
+// TransferStatus 代表枚举的自定义类型
+type TransferStatus struct {
+	Value string
+	Name  string
+}
+
+// 枚举映射
+var TransferStatusMap = make(map[string]TransferStatus)
+
+// 构造函数
+func newTransferStatus(value string, name string) TransferStatus {
+	enum := TransferStatus{Value: value, Name: name}
+	TransferStatusMap[value] = enum
+	return enum
+}
+
+// 使用构造函数定义枚举值
+var (
+	TransferPending   = newTransferStatus("PENDING", "待审批")
+	TransferCompleted = newTransferStatus("COMPLETED", "已完成")
+	TransferRejected  = newTransferStatus("REJECTED", "已拒绝")
+)
+
+// GetTransferStatus 通过 value 获取枚举项
+func GetTransferStatus(value string) (TransferStatus, bool) {
+	enum, ok := TransferStatusMap[value]
+	return enum, ok
+
+}
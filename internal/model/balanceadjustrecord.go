@@ -0,0 +1,50 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// BalanceAdjustRecord 管理员调整积分记录
+type BalanceAdjustRecord struct {
+	Id               string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId      string          `json:"chat_group_id" gorm:"type:varchar(64);not null;index"`
+	ChatGroupUserId  string          `json:"chat_group_user_id" gorm:"type:varchar(64);not null;index"`
+	TgUserId         int64           `json:"tg_user_id" gorm:"type:bigint(20);not null"`
+	OperatorTgUserId int64           `json:"operator_tg_user_id" gorm:"type:bigint(20);not null"` // 操作人(管理员)
+	Operator         string          `json:"operator" gorm:"type:varchar(16);not null"`           // 调整方式 +/-/=
+	Amount           decimal.Decimal `json:"amount" gorm:"type:decimal(20, 2);not null"`          // 管理员输入的积分
+	Delta            decimal.Decimal `json:"delta" gorm:"type:decimal(20, 2);not null"`           // 实际变动积分
+	CreateTime       string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *BalanceAdjustRecord) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+// SumDeltaGroupByChatGroupUserId 按群用户汇总群内管理员调整的积分
+func (c *BalanceAdjustRecord) SumDeltaGroupByChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&BalanceAdjustRecord{}).Select("chat_group_user_id, sum(delta) as amount").
+		Where("chat_group_id = ?", c.ChatGroupId).Group("chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// BalanceLedger 积分流水 每次积分变更均记录一条
+type BalanceLedger struct {
+	Id               string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupUserId  string          `json:"chat_group_user_id" gorm:"type:varchar(64);not null;index"`
+	ChatGroupId      string          `json:"chat_group_id" gorm:"type:varchar(64);not null;index"`
+	TgUserId         int64           `json:"tg_user_id" gorm:"type:bigint(20);not null"`
+	Delta            decimal.Decimal `json:"delta" gorm:"type:decimal(20, 2);not null"`               // 变动积分
+	BalanceBefore    decimal.Decimal `json:"balance_before" gorm:"type:decimal(20, 2);not null"`      // 变动前余额
+	BalanceAfter     decimal.Decimal `json:"balance_after" gorm:"type:decimal(20, 2);not null"`       // 变动后余额
+	ReasonType       string          `json:"reason_type" gorm:"type:varchar(64);not null"`            // 变动类型
+	RefId            string          `json:"ref_id" gorm:"type:varchar(64);default:null"`             // 关联ID(下注记录ID、牌桌ID、转让对方的群用户ID)
+	IssueNumber      string          `json:"issue_number" gorm:"type:varchar(64);default:null"`       // 关联期号
+	OperatorTgUserId int64           `json:"operator_tg_user_id" gorm:"type:bigint(20);default:null"` // 操作人(管理员)
+	CreateTime       string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (BalanceLedger) TableName() string {
+	return "balance_ledger"
+}
+
+func (c *BalanceLedger) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *BalanceLedger) ListByChatGroupUserId(db *gorm.DB, limit int) ([]*BalanceLedger, error) {
+	var balanceLedgers []*BalanceLedger
+
+	result := db.Where("chat_group_user_id = ?", c.ChatGroupUserId).Order("create_time desc").Order("id desc").Limit(limit).Find(&balanceLedgers)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return balanceLedgers, nil
+}
+
+// ListAllByChatGroupUserId 按时间顺序查询用户的全部积分流水
+func (c *BalanceLedger) ListAllByChatGroupUserId(db *gorm.DB) ([]*BalanceLedger, error) {
+	var balanceLedgers []*BalanceLedger
+
+	result := db.Where("chat_group_user_id = ?", c.ChatGroupUserId).Order("create_time asc").Order("id asc").Find(&balanceLedgers)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return balanceLedgers, nil
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// BalanceReconcileReport 积分对账异常报告 每条记录一处不一致
+type BalanceReconcileReport struct {
+	Id              string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ReconcileDate   string          `json:"reconcile_date" gorm:"type:varchar(64);not null;index"` // 对账日期
+	ChatGroupId     string          `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	ChatGroupUserId string          `json:"chat_group_user_id" gorm:"type:varchar(64);not null"`
+	TgUserId        int64           `json:"tg_user_id" gorm:"type:bigint(20);not null"`
+	Username        string          `json:"username" gorm:"type:varchar(500)"`
+	DiscrepancyType string          `json:"discrepancy_type" gorm:"type:varchar(64);not null"`
+	ExpectedBalance decimal.Decimal `json:"expected_balance" gorm:"type:decimal(20, 2)"` // 重算的应有余额
+	ActualBalance   decimal.Decimal `json:"actual_balance" gorm:"type:decimal(20, 2)"`   // 当前余额
+	Detail          string          `json:"detail" gorm:"type:varchar(1024)"`
+	CreateTime      string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *BalanceReconcileReport) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
@@ -7,14 +7,17 @@ import (
 )
 
 type ChatGroup struct {
-	Id               string `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
-	TgChatGroupTitle string `json:"tg_chat_group_title" gorm:"type:varchar(900);not null"`
-	TgChatGroupId    int64  `json:"tg_chat_group_id" gorm:"type:bigint(20);not null"`
-	GameplayType     string `json:"gameplay_type" gorm:"type:varchar(255);not null"`
-	GameDrawCycle    int    `json:"game_draw_cycle" gorm:"type:int(11);not null"`
-	GameplayStatus   int    `json:"gameplay_status" gorm:"type:int(11);not null"`
-	ChatGroupStatus  string `json:"chat_group_status" gorm:"type:varchar(255);not null"`
-	CreateTime       string `json:"create_time" gorm:"type:varchar(255);not null"`
+	Id                  string `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	TgChatGroupTitle    string `json:"tg_chat_group_title" gorm:"type:varchar(900);not null"`
+	TgChatGroupId       int64  `json:"tg_chat_group_id" gorm:"type:bigint(20);not null"`
+	GameplayType        string `json:"gameplay_type" gorm:"type:varchar(255);not null"`
+	GameDrawCycle       int    `json:"game_draw_cycle" gorm:"type:int(11);not null"`
+	GameplayStatus      int    `json:"gameplay_status" gorm:"type:int(11);not null"`
+	ChatGroupStatus     string `json:"chat_group_status" gorm:"type:varchar(255);not null"`
+	DrawSourceType      string `json:"draw_source_type" gorm:"type:varchar(255);not null;default:'TELEGRAM_DICE'"` // 开奖结果来源
+	BetReplyStatus      int    `json:"bet_reply_status" gorm:"type:int(11);not null;default:1"`                    // 是否逐条回复下注成功
+	SettleSummaryStatus int    `json:"settle_summary_status" gorm:"type:int(11);not null;default:1"`               // 是否在群内发送结算汇总
+	CreateTime          string `json:"create_time" gorm:"type:varchar(255);not null"`
 }
 
 func (c *ChatGroup) Create(db *gorm.DB) error {
@@ -91,6 +94,14 @@ func (c *ChatGroup) UpdateGameDrawCycleById(db *gorm.DB) error {
 	return nil
 }
 
+func (c *ChatGroup) UpdateDrawSourceTypeById(db *gorm.DB) error {
+	result := db.Model(&c).Select("draw_source_type").Updates(c)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
 func (c *ChatGroup) UpdateChatGroupStatusById(db *gorm.DB) error {
 	result := db.Model(&c).Select("gameplay_status").Updates(c)
 	if result.Error != nil {
@@ -120,3 +131,30 @@ func ListChatGroupByIds(db *gorm.DB, chatGroupIds []string) ([]*ChatGroup, error
 
 	return chatGroups, nil
 }
+
+func ListChatGroupByChatGroupStatus(db *gorm.DB, chatGroupStatus string) ([]*ChatGroup, error) {
+	var chatGroups []*ChatGroup
+
+	result := db.Where("chat_group_status = ?", chatGroupStatus).Find(&chatGroups)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return chatGroups, nil
+}
+
+func (c *ChatGroup) UpdateBetReplyStatusById(db *gorm.DB) error {
+	result := db.Model(&ChatGroup{}).Where("id = ?", c.Id).Update("bet_reply_status", c.BetReplyStatus)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *ChatGroup) UpdateSettleSummaryStatusById(db *gorm.DB) error {
+	result := db.Model(&ChatGroup{}).Where("id = ?", c.Id).Update("settle_summary_status", c.SettleSummaryStatus)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
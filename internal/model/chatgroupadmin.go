@@ -60,3 +60,14 @@ func DeleteChatGroupAdminByChatGroupId(db *gorm.DB, chatGroupId string) {
 func (c *ChatGroupAdmin) DeleteByChatGroupIdAndAdminTgUserId(db *gorm.DB) {
 	db.Where("chat_group_id = ? and admin_tg_user_id = ?", c.ChatGroupId, c.AdminTgUserId).Delete(&ChatGroupAdmin{})
 }
+
+func ListChatGroupAdminByChatGroupId(db *gorm.DB, chatGroupId string) ([]*ChatGroupAdmin, error) {
+	var chatGroupAdmins []*ChatGroupAdmin
+
+	result := db.Where("chat_group_id = ?", chatGroupId).Find(&chatGroupAdmins)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return chatGroupAdmins, nil
+}
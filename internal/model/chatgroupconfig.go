@@ -0,0 +1,21 @@
+package model
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// DeleteDuplicateConfigByChatGroupId 删除同一群并发重复创建的配置 仅保留最早创建的一条,以便建立chat_group_id唯一索引
+// 重复的配置均由同一群的修改操作一并更新,删除任意一条不影响配置内容
+func DeleteDuplicateConfigByChatGroupId(db *gorm.DB, config interface{}) error {
+	if !db.Migrator().HasTable(config) {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(config); err != nil {
+		return err
+	}
+	table := stmt.Schema.Table
+	result := db.Exec(fmt.Sprintf("DELETE t1 FROM %s t1 JOIN %s t2 ON t1.chat_group_id = t2.chat_group_id AND (t1.create_time > t2.create_time OR (t1.create_time = t2.create_time AND t1.id > t2.id))", table, table))
+	return result.Error
+}
@@ -1,20 +1,23 @@
 package model
 
 import (
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"telegram-dice-bot/internal/utils"
 )
 
 type ChatGroupUser struct {
-	Id          string  `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
-	TgUserId    int64   `json:"tg_user_id" gorm:"type:bigint(20);not null"` // Telegram 用户ID
-	ChatGroupId string  `json:"chat_group_id" gorm:"type:varchar(64);not null;"`
-	IsLeft      int     `json:"is_left" gorm:"type:int(64);not null;"`      // 是否离开群组
-	Username    string  `json:"username" gorm:"type:varchar(500);not null"` // Telegram 用户名
-	Balance     float64 `json:"balance" gorm:"type:decimal(20, 2);not null"`
-	SignInTime  string  `json:"sign_in_time" gorm:"type:varchar(500)"` // 签到时间
-	CreateTime  string  `json:"create_time" gorm:"type:varchar(255);not null"`
+	Id             string              `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	TgUserId       int64               `json:"tg_user_id" gorm:"type:bigint(20);not null"` // Telegram 用户ID
+	ChatGroupId    string              `json:"chat_group_id" gorm:"type:varchar(64);not null;"`
+	IsLeft         int                 `json:"is_left" gorm:"type:int(64);not null;"`      // 是否离开群组
+	Username       string              `json:"username" gorm:"type:varchar(500);not null"` // Telegram 用户名
+	Balance        decimal.Decimal     `json:"balance" gorm:"type:decimal(20, 2);not null"`
+	InitialBalance decimal.NullDecimal `json:"initial_balance" gorm:"type:decimal(20, 2);default:null"` // 期初余额 对账时以此为起点累加各业务记录
+	SignInTime     string              `json:"sign_in_time" gorm:"type:varchar(500)"`                   // 签到时间
+	SignInStreak   int                 `json:"sign_in_streak" gorm:"type:int(11);not null;default:0"`   // 连续签到天数
+	CreateTime     string              `json:"create_time" gorm:"type:varchar(255);not null"`
 }
 
 func (c *ChatGroupUser) Create(db *gorm.DB) error {
@@ -87,3 +90,21 @@ func (c *ChatGroupUser) QueryByIdAndChatGroupId(db *gorm.DB) (*ChatGroupUser, er
 	}
 	return chatGroupUser, nil
 }
+
+func ListChatGroupUserByIds(db *gorm.DB, chatGroupUserIds []string) ([]*ChatGroupUser, error) {
+	var chatGroupUsers []*ChatGroupUser
+	result := db.Where("id in ?", chatGroupUserIds).Find(&chatGroupUsers)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return chatGroupUsers, nil
+}
+
+func (c *ChatGroupUser) ListByChatGroupId(db *gorm.DB) ([]*ChatGroupUser, error) {
+	var chatGroupUsers []*ChatGroupUser
+	result := db.Where("chat_group_id = ?", c.ChatGroupId).Find(&chatGroupUsers)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return chatGroupUsers, nil
+}
@@ -0,0 +1,9 @@
+package model
+
+import "github.com/shopspring/decimal"
+
+// ChatGroupUserAmount 按群用户汇总的积分 用于对账时按来源统计余额变动
+type ChatGroupUserAmount struct {
+	ChatGroupUserId string          `json:"chat_group_user_id"`
+	Amount          decimal.Decimal `json:"amount"`
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// Hongbao 群红包
+type Hongbao struct {
+	Id                    string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId           string          `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	TgChatGroupId         int64           `json:"tg_chat_group_id" gorm:"type:bigint(20);not null"`
+	SenderChatGroupUserId string          `json:"sender_chat_group_user_id" gorm:"type:varchar(64);not null"`
+	SenderTgUserId        int64           `json:"sender_tg_user_id" gorm:"type:bigint(20);not null"`
+	SenderName            string          `json:"sender_name" gorm:"type:varchar(255);default:null"`
+	SplitMode             string          `json:"split_mode" gorm:"type:varchar(64);not null"`       // 拼手气/普通
+	TotalAmount           decimal.Decimal `json:"total_amount" gorm:"type:decimal(20, 2);not null"`  // 红包总积分
+	TotalCount            int             `json:"total_count" gorm:"type:int(11);not null"`          // 红包个数
+	RemainAmount          decimal.Decimal `json:"remain_amount" gorm:"type:decimal(20, 2);not null"` // 剩余积分
+	RemainCount           int             `json:"remain_count" gorm:"type:int(11);not null"`         // 剩余个数
+	Status                string          `json:"status" gorm:"type:varchar(64);not null;index"`     // 红包状态
+	MessageId             int             `json:"message_id" gorm:"type:int(11);default:null"`       // 红包消息ID
+	ExpireTime            string          `json:"expire_time" gorm:"type:varchar(255);not null"`     // 过期时间 过期后剩余积分退还
+	UpdateTime            string          `json:"update_time" gorm:"type:varchar(255);not null"`
+	CreateTime            string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *Hongbao) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *Hongbao) QueryById(db *gorm.DB) (*Hongbao, error) {
+	var hongbao *Hongbao
+	result := db.Where("id = ?", c.Id).First(&hongbao)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return hongbao, nil
+}
+
+func (c *Hongbao) UpdateMessageIdById(db *gorm.DB) error {
+	result := db.Model(&Hongbao{}).Where("id = ?", c.Id).Update("message_id", c.MessageId)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// UpdateRemainByIdAndRemainCount 以剩余个数作为版本号更新剩余积分、个数及状态 返回是否更新成功
+func (c *Hongbao) UpdateRemainByIdAndRemainCount(db *gorm.DB, remainCountBefore int) (bool, error) {
+	result := db.Model(&Hongbao{}).Where("id = ? and remain_count = ?", c.Id, remainCountBefore).Updates(map[string]interface{}{
+		"remain_amount": c.RemainAmount,
+		"remain_count":  c.RemainCount,
+		"status":        c.Status,
+		"update_time":   c.UpdateTime,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func ListHongbaoByStatus(db *gorm.DB, status string) ([]*Hongbao, error) {
+	var hongbaos []*Hongbao
+	result := db.Where("status = ?", status).Find(&hongbaos)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return hongbaos, nil
+}
+
+// SumTotalAmountGroupBySenderChatGroupUserId 按发送人汇总群内发出的红包积分
+func (c *Hongbao) SumTotalAmountGroupBySenderChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&Hongbao{}).Select("sender_chat_group_user_id as chat_group_user_id, sum(total_amount) as amount").
+		Where("chat_group_id = ?", c.ChatGroupId).Group("sender_chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
+
+// SumRemainAmountGroupBySenderChatGroupUserIdAndStatus 按发送人汇总群内指定状态红包的剩余积分
+func (c *Hongbao) SumRemainAmountGroupBySenderChatGroupUserIdAndStatus(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&Hongbao{}).Select("sender_chat_group_user_id as chat_group_user_id, sum(remain_amount) as amount").
+		Where("chat_group_id = ? and status = ?", c.ChatGroupId, c.Status).Group("sender_chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// HongbaoClaim 抢红包记录 每人每个红包仅能抢一次
+type HongbaoClaim struct {
+	Id              string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	HongbaoId       string          `json:"hongbao_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_hongbao_claim_user"`
+	ChatGroupUserId string          `json:"chat_group_user_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_hongbao_claim_user"`
+	TgUserId        int64           `json:"tg_user_id" gorm:"type:bigint(20);not null"`
+	ClaimerName     string          `json:"claimer_name" gorm:"type:varchar(255);default:null"`
+	Amount          decimal.Decimal `json:"amount" gorm:"type:decimal(20, 2);not null"` // 抢到的积分
+	CreateTime      string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *HongbaoClaim) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *HongbaoClaim) ListByHongbaoId(db *gorm.DB) ([]*HongbaoClaim, error) {
+	var hongbaoClaims []*HongbaoClaim
+	result := db.Where("hongbao_id = ?", c.HongbaoId).Order("create_time asc, id asc").Find(&hongbaoClaims)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return hongbaoClaims, nil
+}
+
+// SumHongbaoClaimAmountGroupByChatGroupUserIdAndChatGroupId 按群用户汇总指定群内红包被抢到的积分
+func SumHongbaoClaimAmountGroupByChatGroupUserIdAndChatGroupId(db *gorm.DB, chatGroupId string) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	hongbaoIds := db.Model(&Hongbao{}).Select("id").Where("chat_group_id = ?", chatGroupId)
+	result := db.Model(&HongbaoClaim{}).Select("chat_group_user_id, sum(amount) as amount").
+		Where("hongbao_id in (?)", hongbaoIds).Group("chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+type LiarsDiceTable struct {
+	Id              string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId     string          `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	TgChatGroupId   int64           `json:"tg_chat_group_id" gorm:"type:bigint(20);not null"`
+	CreatorTgUserId int64           `json:"creator_tg_user_id" gorm:"type:bigint(20);not null"` // 开桌用户
+	Ante            decimal.Decimal `json:"ante" gorm:"type:decimal(20, 2);not null"`           // 入场积分
+	Pot             decimal.Decimal `json:"pot" gorm:"type:decimal(20, 2);not null"`            // 奖池
+	Status          string          `json:"status" gorm:"type:varchar(64);not null"`
+	MessageId       int             `json:"message_id" gorm:"type:int(11);not null"`  // 群内牌桌消息ID
+	State           string          `json:"state" gorm:"type:text"`                   // 牌桌状态(json)
+	TurnDeadline    string          `json:"turn_deadline" gorm:"type:varchar(255)"`   // 当前行动截止时间
+	WinnerTgUserId  int64           `json:"winner_tg_user_id" gorm:"type:bigint(20)"` // 获胜用户
+	UpdateTime      string          `json:"update_time" gorm:"type:varchar(255);not null"`
+	CreateTime      string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *LiarsDiceTable) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *LiarsDiceTable) QueryById(db *gorm.DB) (*LiarsDiceTable, error) {
+	var liarsDiceTable *LiarsDiceTable
+	result := db.First(&liarsDiceTable, c.Id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return liarsDiceTable, nil
+}
+
+func (c *LiarsDiceTable) QueryByChatGroupIdAndStatuses(db *gorm.DB, statuses []string) (*LiarsDiceTable, error) {
+	var liarsDiceTable *LiarsDiceTable
+	result := db.Where("chat_group_id = ? and status in ?", c.ChatGroupId, statuses).First(&liarsDiceTable)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return liarsDiceTable, nil
+}
+
+func ListLiarsDiceTableByStatuses(db *gorm.DB, statuses []string) ([]*LiarsDiceTable, error) {
+	var liarsDiceTables []*LiarsDiceTable
+
+	result := db.Where("status in ?", statuses).Find(&liarsDiceTables)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return liarsDiceTables, nil
+}
+
+func (c *LiarsDiceTable) ListByChatGroupId(db *gorm.DB) ([]*LiarsDiceTable, error) {
+	var liarsDiceTables []*LiarsDiceTable
+
+	result := db.Where("chat_group_id = ?", c.ChatGroupId).Find(&liarsDiceTables)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return liarsDiceTables, nil
+}
@@ -32,13 +32,21 @@ func (c *LotteryRecord) Create(db *gorm.DB) error {
 	return nil
 }
 
-func (c *LotteryRecord) ListByChatGroupId(db *gorm.DB) ([]*LotteryRecord, error) {
+// PageByChatGroupId 分页查询群开奖记录 按期号倒序 返回当前页记录及总条数
+func (c *LotteryRecord) PageByChatGroupId(db *gorm.DB, offset, limit int) ([]*LotteryRecord, int64, error) {
 	var lotteryRecords []*LotteryRecord
+	var total int64
 
-	result := db.Where("chat_group_id = ?", c.ChatGroupId).Order("issue_number desc").Limit(10).Find(&lotteryRecords)
+	query := db.Model(&LotteryRecord{}).Where("chat_group_id = ?", c.ChatGroupId)
+	result := query.Count(&total)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, 0, result.Error
 	}
 
-	return lotteryRecords, nil
+	result = query.Order("issue_number desc").Offset(offset).Limit(limit).Find(&lotteryRecords)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return lotteryRecords, total, nil
 }
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+type ProvablyFairSeed struct {
+	Id              string `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId     string `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	IssueNumber     string `json:"issue_number" gorm:"type:varchar(64);not null"`
+	ServerSeed      string `json:"server_seed" gorm:"type:varchar(128);not null"`            // 服务端种子 开奖前不公开
+	ServerSeedHash  string `json:"server_seed_hash" gorm:"type:varchar(128);not null"`       // 服务端种子SHA-256 期号开始时公布
+	ClientSeed      string `json:"client_seed" gorm:"type:text"`                             // 客户端种子 由本期下注收集
+	RevealTime      string `json:"reveal_time" gorm:"type:varchar(255)"`                     // 公布种子时间
+	CommitMessageId int    `json:"commit_message_id" gorm:"type:int(11);not null;default:0"` // 开奖前公布承诺哈希的消息ID 为0表示未公布,该期不可验证
+	CreateTime      string `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *ProvablyFairSeed) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *ProvablyFairSeed) QueryByChatGroupIdAndIssueNumber(db *gorm.DB) (*ProvablyFairSeed, error) {
+	var provablyFairSeed *ProvablyFairSeed
+	result := db.Where("chat_group_id = ? and issue_number = ?", c.ChatGroupId, c.IssueNumber).First(&provablyFairSeed)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return provablyFairSeed, nil
+}
+
+func (c *ProvablyFairSeed) UpdateCommitMessageIdById(db *gorm.DB) error {
+	result := db.Model(&c).Update("commit_message_id", c.CommitMessageId)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *ProvablyFairSeed) UpdateRevealById(db *gorm.DB) error {
+	result := db.Model(&c).Select("client_seed", "reveal_time").Updates(c)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
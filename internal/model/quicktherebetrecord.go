@@ -1,23 +1,27 @@
 package model
 
 import (
+	"fmt"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"strings"
 	"telegram-dice-bot/internal/utils"
 )
 
 type QuickThereBetRecord struct {
-	Id              string  `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
-	ChatGroupUserId string  `json:"chat_group_user_id" gorm:"type:varchar(64);not null"` // 用户ID
-	ChatGroupId     string  `json:"chat_group_id" gorm:"type:varchar(64);not null;"`
-	IssueNumber     string  `json:"issue_number" gorm:"type:varchar(64);not null"`
-	BetType         string  `json:"bet_type" gorm:"type:varchar(64);not null"`               // 下注类型
-	BetAmount       float64 `json:"bet_amount" gorm:"type:decimal(20, 2);not null"`          // 下注金额
-	SettleStatus    int     `json:"settle_status" gorm:"type:int(11);not null"`              // 结算状态
-	BetResultType   *int    `json:"bet_result_type" gorm:"type:int(11);default:null"`        // 下注结果输赢
-	BetResultAmount string  `json:"bet_result_amount" gorm:"type:varchar(255);default:null"` // 下注结果
-	UpdateTime      string  `json:"update_time" gorm:"type:varchar(255);not null"`
-	CreateTime      string  `json:"create_time" gorm:"type:varchar(255);not null"`
+	Id              string              `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupUserId string              `json:"chat_group_user_id" gorm:"type:varchar(64);not null"` // 用户ID
+	ChatGroupId     string              `json:"chat_group_id" gorm:"type:varchar(64);not null;"`
+	IssueNumber     string              `json:"issue_number" gorm:"type:varchar(64);not null"`
+	BetType         string              `json:"bet_type" gorm:"type:varchar(64);not null"`                 // 下注类型
+	BetAmount       decimal.Decimal     `json:"bet_amount" gorm:"type:decimal(20, 2);not null"`            // 下注金额
+	SettleStatus    int                 `json:"settle_status" gorm:"type:int(11);not null"`                // 结算状态
+	BetResultType   *int                `json:"bet_result_type" gorm:"type:int(11);default:null"`          // 下注结果输赢
+	BetResultAmount decimal.NullDecimal `json:"bet_result_amount" gorm:"type:decimal(20, 2);default:null"` // 下注结果 输赢积分,未开奖为空
+	ClientSeed      string              `json:"client_seed" gorm:"type:varchar(64);default:null"`          // 可验证公平开奖的客户端种子
+	UpdateTime      string              `json:"update_time" gorm:"type:varchar(255);not null"`
+	CreateTime      string              `json:"create_time" gorm:"type:varchar(255);not null"`
 }
 
 func (c *QuickThereBetRecord) Create(db *gorm.DB) error {
@@ -57,3 +61,157 @@ func (c *QuickThereBetRecord) QueryById(db *gorm.DB) (*QuickThereBetRecord, erro
 	}
 	return quickThereBetRecord, nil
 }
+
+func (c *QuickThereBetRecord) ListByChatGroupUserIdAndCreateTimeFrom(db *gorm.DB, createTime string) ([]*QuickThereBetRecord, error) {
+	var quickThereBetRecords []*QuickThereBetRecord
+	result := db.Where("chat_group_user_id = ? and create_time >= ?", c.ChatGroupUserId, createTime).Find(&quickThereBetRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return quickThereBetRecords, nil
+}
+
+// ListByChatGroupIdAndSettleStatusAndCreateTimeRange 查询群内某时间段[from, to)指定结算状态的下注记录
+func (c *QuickThereBetRecord) ListByChatGroupIdAndSettleStatusAndCreateTimeRange(db *gorm.DB, createTimeFrom string, createTimeTo string) ([]*QuickThereBetRecord, error) {
+	var quickThereBetRecords []*QuickThereBetRecord
+	result := db.Where("chat_group_id = ? and settle_status = ? and create_time >= ? and create_time < ?", c.ChatGroupId, c.SettleStatus, createTimeFrom, createTimeTo).Find(&quickThereBetRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return quickThereBetRecords, nil
+}
+
+// CountByChatGroupIdAndSettleStatusAndCreateTimeRange 统计群内某时间段[from, to)指定结算状态的下注记录数
+func (c *QuickThereBetRecord) CountByChatGroupIdAndSettleStatusAndCreateTimeRange(db *gorm.DB, createTimeFrom string, createTimeTo string) (int64, error) {
+	var count int64
+	result := db.Model(&QuickThereBetRecord{}).Where("chat_group_id = ? and settle_status = ? and create_time >= ? and create_time < ?", c.ChatGroupId, c.SettleStatus, createTimeFrom, createTimeTo).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
+
+// ListTopProfitByChatGroupIdAndBetResultType 查询群内指定输赢结果的下注 按单笔净盈利(派彩-下注)从高到低排序
+func (c *QuickThereBetRecord) ListTopProfitByChatGroupIdAndBetResultType(db *gorm.DB, limit int) ([]*QuickThereBetRecord, error) {
+	var quickThereBetRecords []*QuickThereBetRecord
+	result := db.Where("chat_group_id = ? and bet_result_type = ?", c.ChatGroupId, c.BetResultType).Order("bet_result_amount - bet_amount desc").Limit(limit).Find(&quickThereBetRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return quickThereBetRecords, nil
+}
+
+// ListByChatGroupUserIdsAndSettleStatus 查询多个群用户指定结算状态的下注记录 按下注时间从早到晚排序
+func (c *QuickThereBetRecord) ListByChatGroupUserIdsAndSettleStatus(db *gorm.DB, chatGroupUserIds []string) ([]*QuickThereBetRecord, error) {
+	var quickThereBetRecords []*QuickThereBetRecord
+	result := db.Where("chat_group_user_id in ? and settle_status = ?", chatGroupUserIds, c.SettleStatus).Order("create_time asc").Find(&quickThereBetRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return quickThereBetRecords, nil
+}
+
+// QuickThereBetRecordFilter 下注记录筛选条件 空值表示不筛选
+type QuickThereBetRecordFilter struct {
+	CreateTimeFrom string
+	CreateTimeTo   string
+	BetType        string
+	SettleStatus   *int
+	BetResultType  *int
+}
+
+// PageByChatGroupUserIdAndFilter 按筛选条件分页查询用户下注记录 按下注时间从晚到早排序 同时返回总条数
+func (c *QuickThereBetRecord) PageByChatGroupUserIdAndFilter(db *gorm.DB, filter *QuickThereBetRecordFilter, offset int, limit int) ([]*QuickThereBetRecord, int64, error) {
+	query := db.Model(&QuickThereBetRecord{}).Where("chat_group_user_id = ?", c.ChatGroupUserId)
+	if filter.CreateTimeFrom != "" {
+		query = query.Where("create_time >= ?", filter.CreateTimeFrom)
+	}
+	if filter.CreateTimeTo != "" {
+		query = query.Where("create_time < ?", filter.CreateTimeTo)
+	}
+	if filter.BetType != "" {
+		query = query.Where("bet_type = ?", filter.BetType)
+	}
+	if filter.SettleStatus != nil {
+		query = query.Where("settle_status = ?", *filter.SettleStatus)
+	}
+	if filter.BetResultType != nil {
+		query = query.Where("bet_result_type = ?", *filter.BetResultType)
+	}
+
+	var total int64
+	result := query.Count(&total)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var quickThereBetRecords []*QuickThereBetRecord
+	result = query.Order("create_time desc").Order("id desc").Offset(offset).Limit(limit).Find(&quickThereBetRecords)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return quickThereBetRecords, total, nil
+}
+
+// SumBetAmountGroupByChatGroupUserId 按群用户汇总群内下注积分
+func (c *QuickThereBetRecord) SumBetAmountGroupByChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&QuickThereBetRecord{}).Select("chat_group_user_id, sum(bet_amount) as amount").
+		Where("chat_group_id = ?", c.ChatGroupId).Group("chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
+
+// SumBetResultAmountGroupByChatGroupUserIdAndBetResultType 按群用户汇总群内指定输赢结果的下注结果积分
+func (c *QuickThereBetRecord) SumBetResultAmountGroupByChatGroupUserIdAndBetResultType(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&QuickThereBetRecord{}).Select("chat_group_user_id, sum(bet_result_amount) as amount").
+		Where("chat_group_id = ? and settle_status = ? and bet_result_type = ?", c.ChatGroupId, c.SettleStatus, c.BetResultType).
+		Group("chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
+
+// NormalizeLegacyBetResultAmount 下注结果积分由字符串改为decimal前 将历史的"+x.xx"/"-x.xx"规整为可直接转换的数值,
+// 空字符串置为空;仍有无法转换的值时返回错误,避免表结构变更失败或被静默截断。列已是decimal时不做处理
+func NormalizeLegacyBetResultAmount(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&QuickThereBetRecord{}) {
+		return nil
+	}
+	columnTypes, err := db.Migrator().ColumnTypes(&QuickThereBetRecord{})
+	if err != nil {
+		return err
+	}
+	isLegacy := false
+	for _, columnType := range columnTypes {
+		if columnType.Name() == "bet_result_amount" {
+			isLegacy = strings.Contains(strings.ToLower(columnType.DatabaseTypeName()), "char")
+		}
+	}
+	if !isLegacy {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&QuickThereBetRecord{}).Where("bet_result_amount is not null").
+			Update("bet_result_amount", gorm.Expr("NULLIF(TRIM(LEADING '+' FROM TRIM(bet_result_amount)), '')"))
+		if result.Error != nil {
+			return result.Error
+		}
+		logrus.WithField("rows", result.RowsAffected).Info("历史下注结果积分已规整")
+
+		var invalidCount int64
+		result = tx.Model(&QuickThereBetRecord{}).Where("bet_result_amount is not null and bet_result_amount not regexp ?", "^-?[0-9]+(\\.[0-9]+)?$").Count(&invalidCount)
+		if result.Error != nil {
+			return result.Error
+		}
+		if invalidCount > 0 {
+			return fmt.Errorf("存在%d条无法转换为数值的下注结果积分", invalidCount)
+		}
+		return nil
+	})
+}
@@ -1,17 +1,20 @@
 package model
 
 import (
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"telegram-dice-bot/internal/utils"
 )
 
 type QuickThereConfig struct {
-	Id          string  `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
-	ChatGroupId string  `json:"chat_group_id" gorm:"type:varchar(64);not null"`
-	SimpleOdds  float64 `json:"simple_odds" gorm:"decimal(5, 2);not null"`
-	TripletOdds float64 `json:"triplet_odds" gorm:"decimal(5, 2);not null"`
-	CreateTime  string  `json:"create_time" gorm:"type:varchar(255);not null"`
+	Id            string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId   string          `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	SimpleOdds    decimal.Decimal `json:"simple_odds" gorm:"type:decimal(10, 2);not null"`
+	TripletOdds   decimal.Decimal `json:"triplet_odds" gorm:"type:decimal(10, 2);not null"`
+	DiceCount     int             `json:"dice_count" gorm:"type:int(11);not null;default:3"`       // 骰子数量(2-5)
+	SmallMaxTotal int             `json:"small_max_total" gorm:"type:int(11);not null;default:10"` // 总点数小于等于该值为小 否则为大
+	CreateTime    string          `json:"create_time" gorm:"type:varchar(255);not null"`
 }
 
 func (c *QuickThereConfig) Create(db *gorm.DB) error {
@@ -48,6 +51,25 @@ func (c *QuickThereConfig) UpdateTripletOddsByChatGroupId(db *gorm.DB) error {
 	return nil
 }
 
+func (c *QuickThereConfig) UpdateDiceCountByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&QuickThereConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Updates(map[string]interface{}{
+		"dice_count":      c.DiceCount,
+		"small_max_total": c.SmallMaxTotal,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *QuickThereConfig) UpdateSmallMaxTotalByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&QuickThereConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("small_max_total", c.SmallMaxTotal)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
 func QueryQuickThereConfigByChatGroupId(db *gorm.DB, chatGroupId string) (*QuickThereConfig, error) {
 	var QuickThereConfig *QuickThereConfig
 	result := db.Where("chat_group_id = ?", chatGroupId).First(&QuickThereConfig)
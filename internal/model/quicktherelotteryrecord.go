@@ -7,17 +7,20 @@ import (
 )
 
 type QuickThereLotteryRecord struct {
-	Id           string `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
-	ChatGroupId  string `json:"chat_group_id" gorm:"type:varchar(64);not null"`
-	IssueNumber  string `json:"issue_number" gorm:"type:varchar(64);not null"`
-	ValueA       int    `json:"value_a" gorm:"type:int(11);not null"`
-	ValueB       int    `json:"value_b" gorm:"type:int(11);not null"`
-	ValueC       int    `json:"value_c" gorm:"type:int(11);not null"`
-	Total        int    `json:"total" gorm:"type:int(11);not null"`
-	SingleDouble string `json:"single_double" gorm:"type:varchar(255);not null"`
-	BigSmall     string `json:"big_small" gorm:"type:varchar(255);not null"`
-	Triplet      int    `json:"triplet" gorm:"type:int(11);not null"`
-	CreateTime   string `json:"create_time" gorm:"type:varchar(255);not null"`
+	Id             string `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId    string `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	IssueNumber    string `json:"issue_number" gorm:"type:varchar(64);not null"`
+	ValueA         int    `json:"value_a" gorm:"type:int(11);not null"`
+	ValueB         int    `json:"value_b" gorm:"type:int(11);not null"`
+	ValueC         int    `json:"value_c" gorm:"type:int(11);not null"`
+	Total          int    `json:"total" gorm:"type:int(11);not null"`
+	SingleDouble   string `json:"single_double" gorm:"type:varchar(255);not null"`
+	BigSmall       string `json:"big_small" gorm:"type:varchar(255);not null"`
+	Triplet        int    `json:"triplet" gorm:"type:int(11);not null"`
+	DiceValues     string `json:"dice_values" gorm:"type:varchar(255);default:null"`      // 全部骰子点数 逗号分隔
+	TgChatGroupId  int64  `json:"tg_chat_group_id" gorm:"type:bigint(20);default:null"`   // 开奖时的Telegram群ID
+	DrawMessageIds string `json:"draw_message_ids" gorm:"type:varchar(255);default:null"` // 开奖消息ID(骰子消息或开奖文本消息) 逗号分隔
+	CreateTime     string `json:"create_time" gorm:"type:varchar(255);not null"`
 }
 
 func (c *QuickThereLotteryRecord) Create(db *gorm.DB) error {
@@ -57,3 +60,51 @@ func (c *QuickThereLotteryRecord) QueryById(db *gorm.DB) (*QuickThereLotteryReco
 	}
 	return quickThereLotteryRecord, nil
 }
+
+// ListRecentByChatGroupId 查询群最近 limit 期快三开奖记录 按期号倒序
+func (c *QuickThereLotteryRecord) ListRecentByChatGroupId(db *gorm.DB, limit int) ([]*QuickThereLotteryRecord, error) {
+	var quickThereLotteryRecords []*QuickThereLotteryRecord
+
+	result := db.Where("chat_group_id = ?", c.ChatGroupId).Order("issue_number desc").Limit(limit).Find(&quickThereLotteryRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return quickThereLotteryRecords, nil
+}
+
+// QueryLastTripletByChatGroupId 查询群最近一期豹子开奖记录
+func (c *QuickThereLotteryRecord) QueryLastTripletByChatGroupId(db *gorm.DB) (*QuickThereLotteryRecord, error) {
+	var quickThereLotteryRecord *QuickThereLotteryRecord
+
+	result := db.Where("chat_group_id = ? and triplet = ?", c.ChatGroupId, 1).Order("issue_number desc").First(&quickThereLotteryRecord)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return quickThereLotteryRecord, nil
+}
+
+// CountByChatGroupIdAndIssueNumberAfter 统计群在指定期号之后的开奖期数
+func (c *QuickThereLotteryRecord) CountByChatGroupIdAndIssueNumberAfter(db *gorm.DB, issueNumber string) (int64, error) {
+	var count int64
+
+	result := db.Model(&QuickThereLotteryRecord{}).Where("chat_group_id = ? and issue_number > ?", c.ChatGroupId, issueNumber).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+// CountByChatGroupId 统计群快三开奖总期数
+func (c *QuickThereLotteryRecord) CountByChatGroupId(db *gorm.DB) (int64, error) {
+	var count int64
+
+	result := db.Model(&QuickThereLotteryRecord{}).Where("chat_group_id = ?", c.ChatGroupId).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
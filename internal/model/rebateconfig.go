@@ -0,0 +1,94 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// RebateConfig 群反水配置
+type RebateConfig struct {
+	Id          string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId string          `json:"chat_group_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_rebate_config_chat_group"`
+	Status      int             `json:"status" gorm:"type:int(11);not null;default:0"`                 // 开启状态
+	RebateMode  string          `json:"rebate_mode" gorm:"type:varchar(64);not null;default:TURNOVER"` // 计算方式 按流水/按净输
+	Rate        decimal.Decimal `json:"rate" gorm:"type:decimal(10, 2);not null"`                      // 反水比例(%)
+	MinTurnover decimal.Decimal `json:"min_turnover" gorm:"type:decimal(20, 2);not null"`              // 最低流水 前一日下注总额低于该值不反水
+	PayHour     int             `json:"pay_hour" gorm:"type:int(11);not null;default:0"`               // 每日发放时间(点)
+	PaidBetDate string          `json:"paid_bet_date" gorm:"type:varchar(64);default:null"`            // 已发放完成的最近下注日期
+	CreateTime  string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *RebateConfig) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *RebateConfig) UpdateStatusByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&RebateConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("status", c.Status)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *RebateConfig) UpdateRebateModeByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&RebateConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("rebate_mode", c.RebateMode)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *RebateConfig) UpdateRuleByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&RebateConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Updates(map[string]interface{}{
+		"rate":         c.Rate,
+		"min_turnover": c.MinTurnover,
+		"pay_hour":     c.PayHour,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// UpdatePaidBetDateByChatGroupId 记录已发放完成的最近下注日期
+func (c *RebateConfig) UpdatePaidBetDateByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&RebateConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("paid_bet_date", c.PaidBetDate)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func QueryRebateConfigByChatGroupId(db *gorm.DB, chatGroupId string) (*RebateConfig, error) {
+	var rebateConfig *RebateConfig
+	result := db.Where("chat_group_id = ?", chatGroupId).First(&rebateConfig)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rebateConfig, nil
+}
+
+func ListRebateConfigByStatus(db *gorm.DB, status int) ([]*RebateConfig, error) {
+	var rebateConfigs []*RebateConfig
+	result := db.Where("status = ?", status).Find(&rebateConfigs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rebateConfigs, nil
+}
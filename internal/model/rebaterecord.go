@@ -0,0 +1,70 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// RebateRecord 反水发放记录 每个群用户每个下注日仅一条
+type RebateRecord struct {
+	Id              string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId     string          `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	ChatGroupUserId string          `json:"chat_group_user_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_rebate_user_date"`
+	TgUserId        int64           `json:"tg_user_id" gorm:"type:bigint(20);not null"`
+	BetDate         string          `json:"bet_date" gorm:"type:varchar(64);not null;uniqueIndex:idx_rebate_user_date"` // 下注日期
+	RebateMode      string          `json:"rebate_mode" gorm:"type:varchar(64);not null"`                               // 计算方式
+	Rate            decimal.Decimal `json:"rate" gorm:"type:decimal(10, 2);not null"`                                   // 反水比例(%)
+	Turnover        decimal.Decimal `json:"turnover" gorm:"type:decimal(20, 2);not null"`                               // 下注流水
+	NetLoss         decimal.Decimal `json:"net_loss" gorm:"type:decimal(20, 2);not null"`                               // 净输积分
+	Amount          decimal.Decimal `json:"amount" gorm:"type:decimal(20, 2);not null"`                                 // 反水积分
+	CreateTime      string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *RebateRecord) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *RebateRecord) QueryByChatGroupUserIdAndBetDate(db *gorm.DB) (*RebateRecord, error) {
+	var rebateRecord *RebateRecord
+	result := db.Where("chat_group_user_id = ? and bet_date = ?", c.ChatGroupUserId, c.BetDate).First(&rebateRecord)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rebateRecord, nil
+}
+
+func (c *RebateRecord) ListByChatGroupUserId(db *gorm.DB, limit int) ([]*RebateRecord, error) {
+	var rebateRecords []*RebateRecord
+	result := db.Where("chat_group_user_id = ?", c.ChatGroupUserId).Order("create_time desc").Limit(limit).Find(&rebateRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rebateRecords, nil
+}
+
+// SumAmountGroupByChatGroupUserId 按群用户汇总群内发放的反水
+func (c *RebateRecord) SumAmountGroupByChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&RebateRecord{}).Select("chat_group_user_id, sum(amount) as amount").
+		Where("chat_group_id = ?", c.ChatGroupId).Group("chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// ReferralConfig 群邀请奖励配置
+type ReferralConfig struct {
+	Id          string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId string          `json:"chat_group_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_referral_config_chat_group"`
+	Status      int             `json:"status" gorm:"type:int(11);not null;default:0"`    // 开启状态
+	Bonus       decimal.Decimal `json:"bonus" gorm:"type:decimal(20, 2);not null"`        // 邀请人奖励积分
+	MinTurnover decimal.Decimal `json:"min_turnover" gorm:"type:decimal(20, 2);not null"` // 被邀请人入群后需达到的下注流水
+	CreateTime  string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *ReferralConfig) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *ReferralConfig) UpdateStatusByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&ReferralConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("status", c.Status)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *ReferralConfig) UpdateRuleByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&ReferralConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Updates(map[string]interface{}{
+		"bonus":        c.Bonus,
+		"min_turnover": c.MinTurnover,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func QueryReferralConfigByChatGroupId(db *gorm.DB, chatGroupId string) (*ReferralConfig, error) {
+	var referralConfig *ReferralConfig
+	result := db.Where("chat_group_id = ?", chatGroupId).First(&referralConfig)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return referralConfig, nil
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// ReferralLink 群用户专属邀请链接 通过 createChatInviteLink 生成
+type ReferralLink struct {
+	Id              string `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId     string `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	ChatGroupUserId string `json:"chat_group_user_id" gorm:"type:varchar(64);not null;uniqueIndex"`
+	TgUserId        int64  `json:"tg_user_id" gorm:"type:bigint(20);not null"`
+	InviteLink      string `json:"invite_link" gorm:"type:varchar(255);not null;uniqueIndex"` // 邀请链接
+	CreateTime      string `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *ReferralLink) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *ReferralLink) QueryByChatGroupUserId(db *gorm.DB) (*ReferralLink, error) {
+	var referralLink *ReferralLink
+	result := db.Where("chat_group_user_id = ?", c.ChatGroupUserId).First(&referralLink)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return referralLink, nil
+}
+
+func (c *ReferralLink) QueryByInviteLink(db *gorm.DB) (*ReferralLink, error) {
+	var referralLink *ReferralLink
+	result := db.Where("invite_link = ?", c.InviteLink).First(&referralLink)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return referralLink, nil
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// ReferralRecord 邀请记录 同一被邀请人在同一群仅记录一次
+type ReferralRecord struct {
+	Id                     string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId            string          `json:"chat_group_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_referral_group_invitee"`
+	InviterChatGroupUserId string          `json:"inviter_chat_group_user_id" gorm:"type:varchar(64);not null;index"`
+	InviterTgUserId        int64           `json:"inviter_tg_user_id" gorm:"type:bigint(20);not null"`
+	InviteeTgUserId        int64           `json:"invitee_tg_user_id" gorm:"type:bigint(20);not null;uniqueIndex:idx_referral_group_invitee"`
+	InviteLink             string          `json:"invite_link" gorm:"type:varchar(255);not null"`
+	Status                 string          `json:"status" gorm:"type:varchar(64);not null"`             // 奖励状态
+	Bonus                  decimal.Decimal `json:"bonus" gorm:"type:decimal(20, 2);not null;default:0"` // 发放的奖励积分
+	RewardTime             string          `json:"reward_time" gorm:"type:varchar(255);default:null"`   // 奖励发放时间
+	CreateTime             string          `json:"create_time" gorm:"type:varchar(255);not null"`       // 入群时间
+}
+
+func (c *ReferralRecord) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *ReferralRecord) QueryByChatGroupIdAndInviteeTgUserId(db *gorm.DB) (*ReferralRecord, error) {
+	var referralRecord *ReferralRecord
+	result := db.Where("chat_group_id = ? and invitee_tg_user_id = ?", c.ChatGroupId, c.InviteeTgUserId).First(&referralRecord)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return referralRecord, nil
+}
+
+// UpdateRewardedById 待达标的邀请记录更新为已奖励 返回是否更新成功 防止重复发放
+func (c *ReferralRecord) UpdateRewardedById(db *gorm.DB, pendingStatus string) (bool, error) {
+	result := db.Model(&ReferralRecord{}).Where("id = ? and status = ?", c.Id, pendingStatus).Updates(map[string]interface{}{
+		"status":      c.Status,
+		"bonus":       c.Bonus,
+		"reward_time": c.RewardTime,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (c *ReferralRecord) ListByInviterChatGroupUserId(db *gorm.DB) ([]*ReferralRecord, error) {
+	var referralRecords []*ReferralRecord
+	result := db.Where("inviter_chat_group_user_id = ?", c.InviterChatGroupUserId).Order("create_time desc").Find(&referralRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return referralRecords, nil
+}
+
+// SumBonusGroupByInviterChatGroupUserId 按邀请人汇总群内指定状态的邀请奖励
+func (c *ReferralRecord) SumBonusGroupByInviterChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&ReferralRecord{}).Select("inviter_chat_group_user_id as chat_group_user_id, sum(bonus) as amount").
+		Where("chat_group_id = ? and status = ?", c.ChatGroupId, c.Status).Group("inviter_chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
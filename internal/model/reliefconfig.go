@@ -0,0 +1,68 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// ReliefConfig 群救济金配置
+type ReliefConfig struct {
+	Id              string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId     string          `json:"chat_group_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_relief_config_chat_group"`
+	Status          int             `json:"status" gorm:"type:int(11);not null;default:0"`           // 开启状态
+	Amount          decimal.Decimal `json:"amount" gorm:"type:decimal(20, 2);not null"`              // 每次发放积分
+	Threshold       decimal.Decimal `json:"threshold" gorm:"type:decimal(20, 2);not null"`           // 余额低于该值可领取
+	DailyLimit      int             `json:"daily_limit" gorm:"type:int(11);not null;default:1"`      // 每日可领取次数
+	CooldownMinutes int             `json:"cooldown_minutes" gorm:"type:int(11);not null;default:0"` // 两次领取间隔(分钟)
+	CreateTime      string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *ReliefConfig) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *ReliefConfig) UpdateStatusByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&ReliefConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("status", c.Status)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *ReliefConfig) UpdateRuleByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&ReliefConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Updates(map[string]interface{}{
+		"amount":           c.Amount,
+		"threshold":        c.Threshold,
+		"daily_limit":      c.DailyLimit,
+		"cooldown_minutes": c.CooldownMinutes,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func QueryReliefConfigByChatGroupId(db *gorm.DB, chatGroupId string) (*ReliefConfig, error) {
+	var reliefConfig *ReliefConfig
+	result := db.Where("chat_group_id = ?", chatGroupId).First(&reliefConfig)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return reliefConfig, nil
+}
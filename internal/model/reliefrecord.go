@@ -0,0 +1,67 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// ReliefRecord 救济金领取记录
+type ReliefRecord struct {
+	Id              string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId     string          `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	ChatGroupUserId string          `json:"chat_group_user_id" gorm:"type:varchar(64);not null;index"`
+	TgUserId        int64           `json:"tg_user_id" gorm:"type:bigint(20);not null"`
+	Amount          decimal.Decimal `json:"amount" gorm:"type:decimal(20, 2);not null"`         // 领取积分
+	BalanceBefore   decimal.Decimal `json:"balance_before" gorm:"type:decimal(20, 2);not null"` // 领取前余额
+	CreateTime      string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *ReliefRecord) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+// ListByChatGroupUserIdAndCreateTimeFrom 查询用户某时间之后的领取记录(按时间倒序)
+func (c *ReliefRecord) ListByChatGroupUserIdAndCreateTimeFrom(db *gorm.DB, createTimeFrom string) ([]*ReliefRecord, error) {
+	var reliefRecords []*ReliefRecord
+	result := db.Where("chat_group_user_id = ? and create_time >= ?", c.ChatGroupUserId, createTimeFrom).Order("create_time desc").Find(&reliefRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return reliefRecords, nil
+}
+
+func (c *ReliefRecord) ListByChatGroupUserId(db *gorm.DB, limit int) ([]*ReliefRecord, error) {
+	var reliefRecords []*ReliefRecord
+	result := db.Where("chat_group_user_id = ?", c.ChatGroupUserId).Order("create_time desc").Limit(limit).Find(&reliefRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return reliefRecords, nil
+}
+
+// SumAmountGroupByChatGroupUserId 按群用户汇总群内领取的救济金
+func (c *ReliefRecord) SumAmountGroupByChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&ReliefRecord{}).Select("chat_group_user_id, sum(amount) as amount").
+		Where("chat_group_id = ?", c.ChatGroupId).Group("chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// SignInConfig 群注册及签到奖励配置
+type SignInConfig struct {
+	Id              string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId     string          `json:"chat_group_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_sign_in_config_chat_group"`
+	RegisterReward  decimal.Decimal `json:"register_reward" gorm:"type:decimal(20, 2);not null"`         // 注册奖励积分
+	RewardLadder    string          `json:"reward_ladder" gorm:"type:varchar(1000);not null"`            // 连续签到奖励阶梯 逗号分隔 第N项为连续签到第N天的奖励
+	SignInMode      string          `json:"sign_in_mode" gorm:"type:varchar(64);not null;default:FIXED"` // 签到模式 固定奖励/骰子/老虎机
+	DiceRewardTable string          `json:"dice_reward_table" gorm:"type:varchar(1000);default:null"`    // 🎲签到奖励表 点数=奖励 逗号分隔
+	SlotRewardTable string          `json:"slot_reward_table" gorm:"type:varchar(1000);default:null"`    // 🎰签到奖励表 结果值=奖励 逗号分隔 *为其余结果
+	CreateTime      string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *SignInConfig) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *SignInConfig) UpdateRegisterRewardByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&SignInConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("register_reward", c.RegisterReward)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *SignInConfig) UpdateRewardLadderByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&SignInConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("reward_ladder", c.RewardLadder)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *SignInConfig) UpdateSignInModeByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&SignInConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("sign_in_mode", c.SignInMode)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *SignInConfig) UpdateDiceRewardTableByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&SignInConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("dice_reward_table", c.DiceRewardTable)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *SignInConfig) UpdateSlotRewardTableByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&SignInConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("slot_reward_table", c.SlotRewardTable)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func QuerySignInConfigByChatGroupId(db *gorm.DB, chatGroupId string) (*SignInConfig, error) {
+	var signInConfig *SignInConfig
+	result := db.Where("chat_group_id = ?", chatGroupId).First(&signInConfig)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return signInConfig, nil
+}
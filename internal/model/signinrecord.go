@@ -0,0 +1,62 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// SignInRecord 签到记录 掷骰签到时记录骰子结果及消息ID以便核对
+type SignInRecord struct {
+	Id              string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId     string          `json:"chat_group_id" gorm:"type:varchar(64);not null"`
+	ChatGroupUserId string          `json:"chat_group_user_id" gorm:"type:varchar(64);not null;index"`
+	TgUserId        int64           `json:"tg_user_id" gorm:"type:bigint(20);not null"`
+	SignInMode      string          `json:"sign_in_mode" gorm:"type:varchar(64);not null"`   // 签到模式
+	DiceEmoji       string          `json:"dice_emoji" gorm:"type:varchar(16);default:null"` // 骰子类型 🎲/🎰
+	DiceValue       int             `json:"dice_value" gorm:"type:int(11);default:null"`     // 骰子结果值
+	TgChatGroupId   int64           `json:"tg_chat_group_id" gorm:"type:bigint(20);default:null"`
+	DiceMessageId   int             `json:"dice_message_id" gorm:"type:int(11);default:null"` // 骰子消息ID
+	Streak          int             `json:"streak" gorm:"type:int(11);not null"`              // 连续签到天数
+	Reward          decimal.Decimal `json:"reward" gorm:"type:decimal(20, 2);not null"`       // 奖励积分
+	CreateTime      string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *SignInRecord) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *SignInRecord) ListByChatGroupUserId(db *gorm.DB, limit int) ([]*SignInRecord, error) {
+	var signInRecords []*SignInRecord
+	result := db.Where("chat_group_user_id = ?", c.ChatGroupUserId).Order("create_time desc").Limit(limit).Find(&signInRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return signInRecords, nil
+}
+
+// SumRewardGroupByChatGroupUserId 按群用户汇总群内签到奖励
+func (c *SignInRecord) SumRewardGroupByChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&SignInRecord{}).Select("chat_group_user_id, sum(reward) as amount").
+		Where("chat_group_id = ?", c.ChatGroupId).Group("chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
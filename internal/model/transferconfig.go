@@ -0,0 +1,81 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// TransferConfig 群积分转让规则 数值为0表示不限制
+type TransferConfig struct {
+	Id                   string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId          string          `json:"chat_group_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_transfer_config_chat_group"`
+	DailyCountLimit      int             `json:"daily_count_limit" gorm:"type:int(11);not null;default:0"`             // 每人每日转出次数上限
+	DailyAmountLimit     decimal.Decimal `json:"daily_amount_limit" gorm:"type:decimal(20, 2);not null;default:0"`     // 每人每日转出积分上限
+	FeeRate              decimal.Decimal `json:"fee_rate" gorm:"type:decimal(10, 2);not null;default:0"`               // 手续费比例(百分比) 由转出方额外支付
+	FeeMode              string          `json:"fee_mode" gorm:"type:varchar(64);not null"`                            // 手续费去向
+	HouseChatGroupUserId string          `json:"house_chat_group_user_id" gorm:"type:varchar(64);not null;default:''"` // 手续费收费账户
+	MinAccountAgeHours   int             `json:"min_account_age_hours" gorm:"type:int(11);not null;default:0"`         // 转出方注册时长要求(小时)
+	MinTurnover          decimal.Decimal `json:"min_turnover" gorm:"type:decimal(20, 2);not null;default:0"`           // 转出方累计下注流水要求
+	ApprovalThreshold    decimal.Decimal `json:"approval_threshold" gorm:"type:decimal(20, 2);not null;default:0"`     // 超过该积分的转让需管理员审批
+	CreateTime           string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *TransferConfig) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *TransferConfig) UpdateRuleByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&TransferConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Updates(map[string]interface{}{
+		"daily_count_limit":     c.DailyCountLimit,
+		"daily_amount_limit":    c.DailyAmountLimit,
+		"fee_rate":              c.FeeRate,
+		"min_account_age_hours": c.MinAccountAgeHours,
+		"min_turnover":          c.MinTurnover,
+		"approval_threshold":    c.ApprovalThreshold,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *TransferConfig) UpdateFeeModeByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&TransferConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("fee_mode", c.FeeMode)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (c *TransferConfig) UpdateHouseChatGroupUserIdByChatGroupId(db *gorm.DB) error {
+	result := db.Model(&TransferConfig{}).Where("chat_group_id = ?", c.ChatGroupId).Update("house_chat_group_user_id", c.HouseChatGroupUserId)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func QueryTransferConfigByChatGroupId(db *gorm.DB, chatGroupId string) (*TransferConfig, error) {
+	var transferConfig *TransferConfig
+	result := db.Where("chat_group_id = ?", chatGroupId).First(&transferConfig)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return transferConfig, nil
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"telegram-dice-bot/internal/utils"
+)
+
+// TransferRecord 积分转让记录 超过审批门槛的转让先以待审批状态记录
+type TransferRecord struct {
+	Id                      string          `json:"id" gorm:"type:varchar(64);not null;primaryKey"`
+	ChatGroupId             string          `json:"chat_group_id" gorm:"type:varchar(64);not null;index"`
+	SenderChatGroupUserId   string          `json:"sender_chat_group_user_id" gorm:"type:varchar(64);not null;index"`
+	SenderTgUserId          int64           `json:"sender_tg_user_id" gorm:"type:bigint(20);not null"`
+	ReceiverChatGroupUserId string          `json:"receiver_chat_group_user_id" gorm:"type:varchar(64);not null"`
+	ReceiverTgUserId        int64           `json:"receiver_tg_user_id" gorm:"type:bigint(20);not null"`
+	Amount                  decimal.Decimal `json:"amount" gorm:"type:decimal(20, 2);not null"`                    // 转让积分
+	Fee                     decimal.Decimal `json:"fee" gorm:"type:decimal(20, 2);not null;default:0"`             // 手续费
+	FeeMode                 string          `json:"fee_mode" gorm:"type:varchar(64);not null"`                     // 手续费去向
+	HouseChatGroupUserId    string          `json:"house_chat_group_user_id" gorm:"type:varchar(64);default:null"` // 手续费实际归入的收费账户
+	Status                  string          `json:"status" gorm:"type:varchar(64);not null"`                       // 转让状态
+	ReviewerTgUserId        int64           `json:"reviewer_tg_user_id" gorm:"type:bigint(20);default:0"`          // 审批管理员
+	ReviewTime              string          `json:"review_time" gorm:"type:varchar(255);default:null"`             // 审批时间
+	CreateTime              string          `json:"create_time" gorm:"type:varchar(255);not null"`
+}
+
+func (c *TransferRecord) Create(db *gorm.DB) error {
+	if c.Id == "" {
+		id, err := utils.NextID()
+		if err != nil {
+			logrus.Error("SnowFlakeId create error")
+			return err
+		}
+		c.Id = id
+	}
+
+	result := db.Create(c)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *TransferRecord) QueryById(db *gorm.DB) (*TransferRecord, error) {
+	var transferRecord *TransferRecord
+	result := db.Where("id = ?", c.Id).First(&transferRecord)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return transferRecord, nil
+}
+
+// UpdateReviewedById 待审批的转让更新为审批结果 返回是否更新成功 防止重复审批
+func (c *TransferRecord) UpdateReviewedById(db *gorm.DB, pendingStatus string) (bool, error) {
+	result := db.Model(&TransferRecord{}).Where("id = ? and status = ?", c.Id, pendingStatus).Updates(map[string]interface{}{
+		"status":              c.Status,
+		"reviewer_tg_user_id": c.ReviewerTgUserId,
+		"review_time":         c.ReviewTime,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (c *TransferRecord) ListBySenderChatGroupUserIdAndCreateTimeFrom(db *gorm.DB, createTime string) ([]*TransferRecord, error) {
+	var transferRecords []*TransferRecord
+	result := db.Where("sender_chat_group_user_id = ? and create_time >= ?", c.SenderChatGroupUserId, createTime).Find(&transferRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return transferRecords, nil
+}
+
+func (c *TransferRecord) ListByChatGroupIdAndStatus(db *gorm.DB, limit int) ([]*TransferRecord, error) {
+	var transferRecords []*TransferRecord
+	result := db.Where("chat_group_id = ? and status = ?", c.ChatGroupId, c.Status).Order("create_time asc").Limit(limit).Find(&transferRecords)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return transferRecords, nil
+}
+
+func (c *TransferRecord) CountByChatGroupIdAndStatus(db *gorm.DB) (int64, error) {
+	var count int64
+	result := db.Model(&TransferRecord{}).Where("chat_group_id = ? and status = ?", c.ChatGroupId, c.Status).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
+
+// UpdateHouseChatGroupUserIdById 记录手续费实际归入的收费账户
+func (c *TransferRecord) UpdateHouseChatGroupUserIdById(db *gorm.DB) error {
+	result := db.Model(&TransferRecord{}).Where("id = ?", c.Id).Update("house_chat_group_user_id", c.HouseChatGroupUserId)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// SumSentGroupBySenderChatGroupUserId 按转出方汇总群内指定状态转让的转出积分及手续费
+func (c *TransferRecord) SumSentGroupBySenderChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&TransferRecord{}).Select("sender_chat_group_user_id as chat_group_user_id, sum(amount + fee) as amount").
+		Where("chat_group_id = ? and status = ?", c.ChatGroupId, c.Status).Group("sender_chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
+
+// SumAmountGroupByReceiverChatGroupUserId 按转入方汇总群内指定状态转让的转入积分
+func (c *TransferRecord) SumAmountGroupByReceiverChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&TransferRecord{}).Select("receiver_chat_group_user_id as chat_group_user_id, sum(amount) as amount").
+		Where("chat_group_id = ? and status = ?", c.ChatGroupId, c.Status).Group("receiver_chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
+
+// SumFeeGroupByHouseChatGroupUserId 按收费账户汇总群内指定状态转让归入的手续费
+func (c *TransferRecord) SumFeeGroupByHouseChatGroupUserId(db *gorm.DB) ([]*ChatGroupUserAmount, error) {
+	var amounts []*ChatGroupUserAmount
+	result := db.Model(&TransferRecord{}).Select("house_chat_group_user_id as chat_group_user_id, sum(fee) as amount").
+		Where("chat_group_id = ? and status = ? and house_chat_group_user_id is not null", c.ChatGroupId, c.Status).
+		Group("house_chat_group_user_id").Scan(&amounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return amounts, nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"github.com/shopspring/decimal"
+	"strings"
+)
+
+// AmountPlaces 积分及倍率保留的小数位数 与数据库 decimal(20, 2) 保持一致
+const AmountPlaces = 2
+
+// MaxAmount 单次输入的积分上限
+var MaxAmount = decimal.NewFromInt(9999999999)
+
+// ParseAmount 解析用户输入的积分或倍率 必须为正数且最多两位小数
+func ParseAmount(s string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(strings.TrimSpace(s))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	err = CheckAmount(amount)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return amount, nil
+}
+
+// CheckAmount 校验积分范围(0-9999999999]且最多两位小数
+func CheckAmount(amount decimal.Decimal) error {
+	if !amount.IsPositive() || amount.GreaterThan(MaxAmount) {
+		return errors.New("积分超出范围")
+	}
+	if !amount.Equal(amount.Truncate(AmountPlaces)) {
+		return errors.New("最多支持两位小数")
+	}
+	return nil
+}
+
+// RoundPayout 派彩舍入规则: 派彩金额按分向下截断,不足一分的部分不予派发
+// 保证派彩不会超过 下注积分*倍率 的理论值,同一笔下注无论何时结算结果都一致
+func RoundPayout(amount decimal.Decimal) decimal.Decimal {
+	return amount.RoundFloor(AmountPlaces)
+}
+
+// FormatAmount 积分展示 固定两位小数
+func FormatAmount(amount decimal.Decimal) string {
+	return amount.StringFixed(AmountPlaces)
+}
+
+// FormatSignedAmount 积分变动展示 正数带+号
+func FormatSignedAmount(amount decimal.Decimal) string {
+	if amount.IsPositive() {
+		return "+" + FormatAmount(amount)
+	}
+	return FormatAmount(amount)
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// JoinInts 将整数切片以分隔符拼接为字符串
+func JoinInts(values []int, sep string) string {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = strconv.Itoa(value)
+	}
+	return strings.Join(strs, sep)
+}
+
+// SplitInts 将分隔符拼接的字符串解析为整数切片 忽略无法解析的项
+func SplitInts(s string, sep string) []int {
+	var values []int
+	for _, str := range strings.Split(s, sep) {
+		value, err := strconv.Atoi(strings.TrimSpace(str))
+		if err != nil {
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
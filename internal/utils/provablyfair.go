@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewServerSeed 生成32字节的随机服务端种子(十六进制)
+func NewServerSeed() (string, error) {
+	seed := make([]byte, 32)
+	_, err := rand.Read(seed)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(seed), nil
+}
+
+// HashServerSeed 计算服务端种子的SHA-256承诺哈希
+func HashServerSeed(serverSeed string) string {
+	sum := sha256.Sum256([]byte(serverSeed))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeriveDiceValues 由服务端种子、期号和客户端种子确定性地推导骰子点数
+// 以 HMAC-SHA256(serverSeed, "issueNumber:clientSeed:nonce") 的字节流逐个取值,
+// 丢弃大于等于252的字节以避免取模偏差,字节用尽时nonce加1继续。
+func DeriveDiceValues(serverSeed string, issueNumber string, clientSeed string, numDice int) []int {
+	diceValues := make([]int, 0, numDice)
+	for nonce := 0; len(diceValues) < numDice; nonce++ {
+		mac := hmac.New(sha256.New, []byte(serverSeed))
+		mac.Write([]byte(fmt.Sprintf("%s:%s:%d", issueNumber, clientSeed, nonce)))
+		for _, b := range mac.Sum(nil) {
+			if b >= 252 {
+				continue
+			}
+			diceValues = append(diceValues, int(b%6)+1)
+			if len(diceValues) == numDice {
+				break
+			}
+		}
+	}
+	return diceValues
+}